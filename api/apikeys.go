@@ -0,0 +1,85 @@
+package api
+
+import (
+	"blog_post/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary create an API key
+// @Description Endpoint to issue an API key for a machine client, sent back as the X-API-Key header. The key is only returned here; afterwards only its prefix is shown. A read key acts as a reader, a write key as an editor and an admin key as an admin.
+// @Tags API Keys
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.APIKeyRequest true "Key details"
+// @Success 201 {object} models.NewAPIKey "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 413 {object} models.Problem "Request Entity Too Large"
+// @Failure 415 {object} models.Problem "Unsupported Media Type"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /api-keys [post]
+func (h *Handler) CreateAPIKey(c *fiber.Ctx) error {
+	reqBody, err := JSONBody[models.APIKeyRequest](c)
+	if err != nil {
+		return err
+	}
+	key, err := h.Keys.CreateAPIKey(c.UserContext(), *reqBody)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusCreated).JSON(key)
+}
+
+// @Summary list API keys
+// @Description Endpoint to list every API key, oldest first, with when it was last used. Revoked and expired keys are listed too.
+// @Tags API Keys
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Produce json
+// @Success 200 {array} models.APIKey "Successful Response"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /api-keys [get]
+func (h *Handler) ListAPIKeys(c *fiber.Ctx) error {
+	keys, err := h.Keys.ListAPIKeys(c.UserContext())
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(keys)
+}
+
+// @Summary revoke an API key
+// @Description Endpoint to stop an API key from authenticating. The key stays listed; revoking it again changes nothing.
+// @Tags API Keys
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Produce json
+// @Param id path int64 true "API key ID"
+// @Success 200 {object} models.APIKey "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /api-keys/{id} [delete]
+func (h *Handler) RevokeAPIKey(c *fiber.Ctx) error {
+	keyID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	key, err := h.Keys.RevokeAPIKey(c.UserContext(), keyID)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(key)
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	h := NewHandler(db.NewRepo())
+	app := newTestApp(t)
+	app.Post("/api-keys", h.CreateAPIKey)
+	app.Get("/api-keys", h.ListAPIKeys)
+	app.Delete("/api-keys/:id", h.RevokeAPIKey)
+
+	var created models.NewAPIKey
+	t.Run("Create", func(t *testing.T) {
+		resp := app.call(http.MethodPost, "/api-keys", `{"name":"ci-importer","scope":"write","expires_at":"2999-01-01T00:00:00Z"}`, &created)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Positive(t, created.ID)
+		assert.NotEmpty(t, created.Key)
+		assert.Equal(t, models.ScopeWrite, created.Scope)
+		require.NotNil(t, created.ExpiresAt)
+	})
+	t.Run("The key is never listed", func(t *testing.T) {
+		var raw []map[string]any
+		resp := app.call(http.MethodGet, "/api-keys", "", &raw)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Len(t, raw, 1)
+		assert.Equal(t, created.Prefix, raw[0]["prefix"])
+		assert.NotContains(t, raw[0], "key")
+		assert.NotContains(t, raw[0], "key_hash")
+	})
+	t.Run("Invalid request", func(t *testing.T) {
+		var problem models.Problem
+		resp := app.call(http.MethodPost, "/api-keys", `{"name":"","scope":"root"}`, &problem)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Len(t, problem.Errors, 2)
+	})
+	t.Run("Revoke", func(t *testing.T) {
+		var key models.APIKey
+		resp := app.call(http.MethodDelete, fmt.Sprintf("/api-keys/%d", created.ID), "", &key)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotNil(t, key.RevokedAt)
+	})
+	t.Run("Unknown key", func(t *testing.T) {
+		resp := app.call(http.MethodDelete, "/api-keys/9999", "", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
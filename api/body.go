@@ -0,0 +1,122 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LocalBody is the fiber.Ctx locals key under which JSONBody keeps the
+// decoded request body, so that middlewares and handlers decode it once
+const LocalBody = "body"
+
+// DefaultBodyLimit is the largest request body accepted unless configured
+// otherwise; bigger ones are answered with 413
+const DefaultBodyLimit = 1 << 20
+
+// JSONBody returns the request body decoded into a T. The first call
+// decodes strictly: the Content-Type must be application/json (415
+// otherwise), and empty bodies, malformed JSON, trailing data, duplicate
+// keys and fields T does not have are rejected with 400. Later calls return
+// the same *T from the locals, including changes made through it.
+func JSONBody[T any](c *fiber.Ctx) (*T, error) {
+	if body, ok := c.Locals(LocalBody).(*T); ok {
+		return body, nil
+	}
+	if err := checkJSONContentType(c.Get(fiber.HeaderContentType)); err != nil {
+		return nil, err
+	}
+	body := new(T)
+	if err := decodeStrict(c.Body(), body); err != nil {
+		return nil, err
+	}
+	c.Locals(LocalBody, body)
+	return body, nil
+}
+
+// checkJSONContentType accepts application/json in UTF-8 only
+func checkJSONContentType(contentType string) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != fiber.MIMEApplicationJSON {
+		return fiber.NewError(http.StatusUnsupportedMediaType, "Content-Type must be "+fiber.MIMEApplicationJSON)
+	}
+	if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+		return fiber.NewError(http.StatusUnsupportedMediaType, "JSON bodies must be UTF-8")
+	}
+	return nil
+}
+
+// decodeStrict decodes the single JSON value in data into v, rejecting
+// duplicate keys and fields v does not have
+func decodeStrict(data []byte, v any) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return fiber.NewError(http.StatusBadRequest, "request body must not be empty")
+	}
+	if err := checkDuplicateKeys(data); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	// checkDuplicateKeys has already rejected malformed JSON
+	if err := dec.Decode(v); err != nil {
+		return documentError(err, http.StatusBadRequest)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fiber.NewError(http.StatusBadRequest, "request body must hold a single JSON value")
+	}
+	return nil
+}
+
+// checkDuplicateKeys walks data and rejects objects, at any depth, that
+// name a key twice; encoding/json would silently keep the last one. Keys
+// are compared case-insensitively, as encoding/json matches them to fields.
+func checkDuplicateKeys(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var walk func(path string) error
+	walk = func(path string) error {
+		token, err := dec.Token()
+		if err != nil {
+			return errInvalidJSON
+		}
+		switch token {
+		case json.Delim('{'):
+			seen := make(map[string]bool)
+			for dec.More() {
+				token, err := dec.Token()
+				if err != nil {
+					return errInvalidJSON
+				}
+				key := token.(string)
+				folded := strings.ToLower(key)
+				if seen[folded] {
+					return fiber.NewError(http.StatusBadRequest, "duplicate key "+strconv.Quote(path+key))
+				}
+				seen[folded] = true
+				if err := walk(path + key + "."); err != nil {
+					return err
+				}
+			}
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err := walk(path + strconv.Itoa(i) + "."); err != nil {
+					return err
+				}
+			}
+		default:
+			return nil
+		}
+		if _, err := dec.Token(); err != nil {
+			return errInvalidJSON
+		}
+		return nil
+	}
+	return walk("")
+}
+
+var errInvalidJSON = fiber.NewError(http.StatusBadRequest, "Invalid JSON format")
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"blog_post/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONBody(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/", func(c *fiber.Ctx) error {
+		body, err := JSONBody[models.BlogRequestBody](c)
+		if err != nil {
+			return err
+		}
+		body.Title = strings.ToUpper(body.Title)
+		return c.Next()
+	}, func(c *fiber.Ctx) error {
+		body, err := JSONBody[models.BlogRequestBody](c)
+		if err != nil {
+			return err
+		}
+		return c.JSON(body)
+	})
+
+	tests := []struct {
+		description  string
+		contentType  string
+		body         string
+		expectedCode int
+		detail       string
+	}{
+		{"valid", "application/json", `{"title":"go","body":"b"}`, http.StatusOK, ""},
+		{"charset", "application/json; charset=UTF-8", `{"title":"go"}`, http.StatusOK, ""},
+		{"form encoded", "application/x-www-form-urlencoded", "title=go", http.StatusUnsupportedMediaType, "Content-Type must be application/json"},
+		{"no content type", "", `{"title":"go"}`, http.StatusUnsupportedMediaType, "Content-Type must be application/json"},
+		{"other charset", "application/json; charset=latin1", `{"title":"go"}`, http.StatusUnsupportedMediaType, "JSON bodies must be UTF-8"},
+		{"empty", "application/json", " ", http.StatusBadRequest, "request body must not be empty"},
+		{"malformed", "application/json", `{"title":`, http.StatusBadRequest, "Invalid JSON format"},
+		{"duplicate key", "application/json", `{"title":"a","title":"b"}`, http.StatusBadRequest, `duplicate key "title"`},
+		{"duplicate key in another case", "application/json", `{"title":"a","TITLE":"b"}`, http.StatusBadRequest, `duplicate key "TITLE"`},
+		{"nested duplicate key", "application/json", `{"title":"a","extra":[{"x":1,"x":2}]}`, http.StatusBadRequest, `duplicate key "extra.0.x"`},
+		{"unknown field", "application/json", `{"title":"a","author":"bob"}`, http.StatusBadRequest, "validation failed: author is not a known field"},
+		{"wrong type", "application/json", `{"title":1}`, http.StatusBadRequest, "validation failed: title must be a string"},
+		{"trailing data", "application/json", `{"title":"a"} {}`, http.StatusBadRequest, "request body must hold a single JSON value"},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+			if test.contentType != "" {
+				req.Header.Set(fiber.HeaderContentType, test.contentType)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			if test.expectedCode == http.StatusOK {
+				var body models.BlogRequestBody
+				json.NewDecoder(resp.Body).Decode(&body)
+				assert.Equal(t, "GO", body.Title, "later calls reuse the decoded body")
+				return
+			}
+			var problem models.Problem
+			json.NewDecoder(resp.Body).Decode(&problem)
+			assert.Equal(t, test.detail, problem.Detail)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"blog_post/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary list categories
+// @Description Endpoint to list every category, ordered by slug. List a category's blogs with `GET /blog-posts?category=<slug>`.
+// @Tags Categories
+// @Produce json
+// @Success 200 {array} models.Category "Successful Response"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /categories [get]
+func (h *Handler) ListCategories(c *fiber.Ctx) error {
+	categories, err := h.Categories.ListCategories(c.UserContext())
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(categories)
+}
+
+// @Summary fetch a category
+// @Description Endpoint to fetch a category by id.
+// @Tags Categories
+// @Produce json
+// @Param id path int64 true "Category ID"
+// @Success 200 {object} models.Category "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /categories/{id} [get]
+func (h *Handler) GetCategory(c *fiber.Ctx) error {
+	categoryID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	category, err := h.Categories.GetCategory(c.UserContext(), categoryID)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(category)
+}
+
+// @Summary create a category
+// @Description Endpoint to add a category blogs can be filed under. The slug is derived from the name unless one is given.
+// @Tags Categories
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body models.CategoryRequest true "Category details"
+// @Success 201 {object} models.Category "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 409 {object} models.Problem "Slug already taken"
+// @Failure 413 {object} models.Problem "Request Entity Too Large"
+// @Failure 415 {object} models.Problem "Unsupported Media Type"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /categories [post]
+func (h *Handler) CreateCategory(c *fiber.Ctx) error {
+	reqBody, err := JSONBody[models.CategoryRequest](c)
+	if err != nil {
+		return err
+	}
+	category, err := h.Categories.CreateCategory(c.UserContext(), *reqBody)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusCreated).JSON(category)
+}
+
+// @Summary update a category
+// @Description Endpoint to replace a category. It keeps its slug unless the request names another one or the name changes; its blogs follow it to the new slug.
+// @Tags Categories
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int64 true "Category ID"
+// @Param request body models.CategoryRequest true "Category details"
+// @Success 200 {object} models.Category "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 409 {object} models.Problem "Slug already taken"
+// @Failure 413 {object} models.Problem "Request Entity Too Large"
+// @Failure 415 {object} models.Problem "Unsupported Media Type"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /categories/{id} [put]
+func (h *Handler) UpdateCategory(c *fiber.Ctx) error {
+	categoryID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	reqBody, err := JSONBody[models.CategoryRequest](c)
+	if err != nil {
+		return err
+	}
+	category, err := h.Categories.UpdateCategory(c.UserContext(), categoryID, *reqBody)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(category)
+}
+
+// @Summary delete a category
+// @Description Endpoint to delete a category. Its blogs are kept and simply no longer filed under it.
+// @Tags Categories
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Produce json
+// @Param id path int64 true "Category ID"
+// @Success 200 {object} models.SuccessResponse "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /categories/{id} [delete]
+func (h *Handler) DeleteCategory(c *fiber.Ctx) error {
+	categoryID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	if err := h.Categories.DeleteCategory(c.UserContext(), categoryID); err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(fiber.Map{"message": "Category deleted successfully"})
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCategories(t *testing.T) {
+	h := NewHandler(db.NewRepo())
+	app := newTestApp(t)
+	app.Get("/categories", h.ListCategories)
+	app.Get("/categories/:id", h.GetCategory)
+	app.Post("/categories", h.CreateCategory)
+	app.Put("/categories/:id", h.UpdateCategory)
+	app.Delete("/categories/:id", h.DeleteCategory)
+	app.Get("/blog-posts", h.GetAllBlogs)
+
+	var tutorials models.Category
+	t.Run("Create", func(t *testing.T) {
+		resp := app.call(http.MethodPost, "/categories", `{"name":"Tutorials","description":"Guides"}`, &tutorials)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, "tutorials", tutorials.Slug)
+
+		var problem models.Problem
+		resp = app.call(http.MethodPost, "/categories", `{"name":"Tutorials"}`, &problem)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		resp = app.call(http.MethodPost, "/categories", `{"name":""}`, &problem)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "name", problem.Errors[0].Field)
+	})
+	t.Run("Read", func(t *testing.T) {
+		var got models.Category
+		resp := app.call(http.MethodGet, fmt.Sprintf("/categories/%d", tutorials.ID), "", &got)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, tutorials.Name, got.Name)
+
+		var categories []models.Category
+		resp = app.call(http.MethodGet, "/categories", "", &categories)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Len(t, categories, 1)
+
+		resp = app.call(http.MethodGet, "/categories/999", "", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+	t.Run("Filter blogs", func(t *testing.T) {
+		req := models.BlogRequestBody{Title: "Filed", Description: "d", Body: "b", Categories: []string{"tutorials"}}
+		blog, err := h.Store.CreateBlog(context.Background(), req)
+		require.NoError(t, err)
+		CreateRandomBlog(t, h)
+
+		var page models.BlogList
+		resp := app.call(http.MethodGet, "/blog-posts?status=all&category=tutorials", "", &page)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Len(t, page.Items, 1)
+		assert.Equal(t, blog.ID, page.Items[0].ID)
+		assert.Equal(t, []string{"tutorials"}, page.Items[0].Categories)
+	})
+	t.Run("Update", func(t *testing.T) {
+		var updated models.Category
+		resp := app.call(http.MethodPut, fmt.Sprintf("/categories/%d", tutorials.ID), `{"name":"Tutorials","slug":"guides"}`, &updated)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "guides", updated.Slug)
+		assert.Empty(t, updated.Description)
+
+		var page models.BlogList
+		app.call(http.MethodGet, "/blog-posts?status=all&category=guides", "", &page)
+		assert.Len(t, page.Items, 1, "blogs follow the category to its new slug")
+	})
+	t.Run("Delete", func(t *testing.T) {
+		resp := app.call(http.MethodDelete, fmt.Sprintf("/categories/%d", tutorials.ID), "", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		resp = app.call(http.MethodDelete, fmt.Sprintf("/categories/%d", tutorials.ID), "", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		var page models.BlogList
+		app.call(http.MethodGet, "/blog-posts?status=all&category=guides", "", &page)
+		assert.Empty(t, page.Items)
+	})
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary list a blog's comments
+// @Description Endpoint to list the approved comments on a blog one thread at a time, oldest first. Every top-level comment carries its approved replies, nested to any depth; replies to comments that are not approved are not shown. Comments on blogs that are not published are only shown to callers who may update them. Follow the `next` link in the Link header (or pass next_cursor as after) for the next page.
+// @Tags Comments
+// @Produce json
+// @Param id path int64 true "Blog ID"
+// @Param limit query int false "Threads per page (1-100)" default(20)
+// @Param after query string false "Cursor: return the page after this position"
+// @Success 200 {object} models.CommentList "Successful Response"
+// @Header 200 {string} Link "RFC 8288 link to the next page"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /blog-post/{id}/comments [get]
+func (h *Handler) ListComments(c *fiber.Ctx) error {
+	blogID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	if _, err := h.readableBlog(c, blogID); err != nil {
+		return err
+	}
+	opts, err := commentListOptions(c)
+	if err != nil {
+		return err
+	}
+	list, err := h.Comments.ListComments(c.UserContext(), blogID, opts)
+	if err != nil {
+		return err
+	}
+	setPageLinks(c, list.NextCursor, "")
+	return c.Status(http.StatusOK).JSON(list)
+}
+
+// @Summary comment on a blog
+// @Description Endpoint for users to comment on a published blog, or reply to one of its approved comments with `parent_id`. New comments are pending until a moderator approves them.
+// @Tags Comments
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int64 true "Blog ID"
+// @Param request body models.CommentRequest true "Comment"
+// @Success 201 {object} models.Comment "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 409 {object} models.Problem "Blog not published"
+// @Failure 413 {object} models.Problem "Request Entity Too Large"
+// @Failure 415 {object} models.Problem "Unsupported Media Type"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /blog-post/{id}/comments [post]
+func (h *Handler) CreateComment(c *fiber.Ctx) error {
+	blogID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	if _, err := h.readableBlog(c, blogID); err != nil {
+		return err
+	}
+	reqBody, err := JSONBody[models.CommentRequest](c)
+	if err != nil {
+		return err
+	}
+	comment, err := h.Comments.CreateComment(c.UserContext(), blogID, *reqBody)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusCreated).JSON(comment)
+}
+
+// @Summary edit a comment
+// @Description Endpoint for the author of a comment to replace its body. The edited comment is pending again until a moderator approves it, and its replies are hidden meanwhile.
+// @Tags Comments
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int64 true "Blog ID"
+// @Param commentId path int64 true "Comment ID"
+// @Param request body models.CommentEditRequest true "New body"
+// @Success 200 {object} models.Comment "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 413 {object} models.Problem "Request Entity Too Large"
+// @Failure 415 {object} models.Problem "Unsupported Media Type"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /blog-post/{id}/comments/{commentId} [put]
+func (h *Handler) UpdateComment(c *fiber.Ctx) error {
+	blogID, commentID, err := commentParams(c)
+	if err != nil {
+		return err
+	}
+	if _, err := h.readableBlog(c, blogID); err != nil {
+		return err
+	}
+	reqBody, err := JSONBody[models.CommentEditRequest](c)
+	if err != nil {
+		return err
+	}
+	comment, err := h.Comments.UpdateComment(c.UserContext(), blogID, commentID, *reqBody)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(comment)
+}
+
+// @Summary delete a comment
+// @Description Endpoint to delete a comment together with all of its replies.
+// @Tags Comments
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Produce json
+// @Param id path int64 true "Blog ID"
+// @Param commentId path int64 true "Comment ID"
+// @Success 200 {object} models.SuccessResponse "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /blog-post/{id}/comments/{commentId} [delete]
+func (h *Handler) DeleteComment(c *fiber.Ctx) error {
+	blogID, commentID, err := commentParams(c)
+	if err != nil {
+		return err
+	}
+	if _, err := h.readableBlog(c, blogID); err != nil {
+		return err
+	}
+	if err := h.Comments.DeleteComment(c.UserContext(), blogID, commentID); err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(fiber.Map{"message": "Comment deleted successfully"})
+}
+
+// @Summary list the moderation queue
+// @Description Endpoint for moderators to list comments across blogs, oldest first and without nesting. Only pending comments are listed unless `status` asks for another one (or `all`). Comments on blogs in the trash are left out.
+// @Tags Comments
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Produce json
+// @Param status query string false "Only comments in this status" Enums(pending, approved, spam, all) default(pending)
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param after query string false "Cursor: return the page after this position"
+// @Success 200 {object} models.CommentList "Successful Response"
+// @Header 200 {string} Link "RFC 8288 link to the next page"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /comments [get]
+func (h *Handler) ModerationQueue(c *fiber.Ctx) error {
+	opts, err := commentListOptions(c)
+	if err != nil {
+		return err
+	}
+	status := models.CommentStatus(c.Query("status", string(models.CommentPending)))
+	if status == "all" {
+		status = ""
+	}
+	queue, err := h.Comments.ModerationQueue(c.UserContext(), status, opts)
+	if err != nil {
+		return err
+	}
+	setPageLinks(c, queue.NextCursor, "")
+	return c.Status(http.StatusOK).JSON(queue)
+}
+
+// @Summary moderate a comment
+// @Description Endpoint for moderators to approve a comment, mark it as spam or send it back to pending. Only approved comments are shown on their blog.
+// @Tags Comments
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Accept json
+// @Produce json
+// @Param commentId path int64 true "Comment ID"
+// @Param request body models.CommentStatusRequest true "New status"
+// @Success 200 {object} models.Comment "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 413 {object} models.Problem "Request Entity Too Large"
+// @Failure 415 {object} models.Problem "Unsupported Media Type"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /comments/{commentId}/status [put]
+func (h *Handler) ModerateComment(c *fiber.Ctx) error {
+	commentID, err := strconv.ParseInt(c.Params("commentId"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	reqBody, err := JSONBody[models.CommentStatusRequest](c)
+	if err != nil {
+		return err
+	}
+	comment, err := h.Comments.ModerateComment(c.UserContext(), commentID, reqBody.Status)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(comment)
+}
+
+// commentParams parses the :id and :commentId parameters of a comment route
+func commentParams(c *fiber.Ctx) (blogID, commentID int64, err error) {
+	if blogID, err = strconv.ParseInt(c.Params("id"), 10, 64); err != nil {
+		return 0, 0, fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	if commentID, err = strconv.ParseInt(c.Params("commentId"), 10, 64); err != nil {
+		return 0, 0, fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	return blogID, commentID, nil
+}
+
+// commentListOptions reads the paging parameters of comment listings
+func commentListOptions(c *fiber.Ctx) (db.CommentListOptions, error) {
+	opts := db.CommentListOptions{After: c.Query("after")}
+	var fields []models.FieldError
+	if opts.Limit, fields = queryLimit(c, fields); len(fields) > 0 {
+		return db.CommentListOptions{}, &db.ValidationError{Fields: fields}
+	}
+	return opts, nil
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComments(t *testing.T) {
+	h := NewHandler(db.NewRepo())
+	alice, err := h.Users.CreateUser(context.Background(), models.RegisterRequest{Username: "alice", Password: "password alice"})
+	require.NoError(t, err)
+	app := newTestApp(t)
+	app.Use(func(c *fiber.Ctx) error {
+		c.SetUserContext(db.WithAuthor(db.WithActor(c.UserContext(), alice.Username), alice.ID))
+		return c.Next()
+	})
+	app.Get("/blog-post/:id/comments", h.ListComments)
+	app.Post("/blog-post/:id/comments", h.CreateComment)
+	app.Put("/blog-post/:id/comments/:commentId", h.UpdateComment)
+	app.Delete("/blog-post/:id/comments/:commentId", h.DeleteComment)
+	app.Get("/comments", h.ModerationQueue)
+	app.Put("/comments/:commentId/status", h.ModerateComment)
+	blog := PublishRandomBlog(t, h)
+	comments := fmt.Sprintf("/blog-post/%d/comments", blog.ID)
+
+	var thread models.Comment
+	t.Run("Create", func(t *testing.T) {
+		resp := app.call(http.MethodPost, comments, `{"body":"First!"}`, &thread)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, alice.ID, thread.AuthorID)
+		assert.Equal(t, "alice", thread.Author)
+		assert.Equal(t, models.CommentPending, thread.Status)
+
+		var problem models.Problem
+		resp = app.call(http.MethodPost, comments, `{"body":""}`, &problem)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "body", problem.Errors[0].Field)
+		resp = app.call(http.MethodPost, comments, fmt.Sprintf(`{"body":"Reply","parent_id":%d}`, thread.ID), &problem)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "pending comments take no replies")
+		assert.Equal(t, "parent_id", problem.Errors[0].Field)
+		draft := CreateRandomBlog(t, h)
+		resp = app.call(http.MethodPost, fmt.Sprintf("/blog-post/%d/comments", draft.ID), `{"body":"Early"}`, nil)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+	t.Run("Moderate", func(t *testing.T) {
+		var queue models.CommentList
+		resp := app.call(http.MethodGet, "/comments", "", &queue)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Len(t, queue.Items, 1, "the queue lists pending comments by default")
+		assert.Equal(t, thread.ID, queue.Items[0].ID)
+
+		var moderated models.Comment
+		resp = app.call(http.MethodPut, fmt.Sprintf("/comments/%d/status", thread.ID), `{"status":"approved"}`, &moderated)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, models.CommentApproved, moderated.Status)
+		resp = app.call(http.MethodPut, fmt.Sprintf("/comments/%d/status", thread.ID), `{"status":"gone"}`, nil)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		app.call(http.MethodGet, "/comments", "", &queue)
+		assert.Empty(t, queue.Items)
+		app.call(http.MethodGet, "/comments?status=all", "", &queue)
+		assert.Len(t, queue.Items, 1)
+		resp = app.call(http.MethodGet, "/comments?status=deleted", "", nil)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+	t.Run("List threads", func(t *testing.T) {
+		var reply models.Comment
+		resp := app.call(http.MethodPost, comments, fmt.Sprintf(`{"body":"Reply","parent_id":%d}`, thread.ID), &reply)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		app.call(http.MethodPut, fmt.Sprintf("/comments/%d/status", reply.ID), `{"status":"approved"}`, nil)
+		for i := 0; i < 2; i++ {
+			var more models.Comment
+			app.call(http.MethodPost, comments, `{"body":"More"}`, &more)
+			app.call(http.MethodPut, fmt.Sprintf("/comments/%d/status", more.ID), `{"status":"approved"}`, nil)
+		}
+
+		var list models.CommentList
+		resp = app.call(http.MethodGet, comments+"?limit=2", "", &list)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 3, list.Total)
+		require.Len(t, list.Items, 2)
+		assert.Equal(t, thread.ID, list.Items[0].ID)
+		require.Len(t, list.Items[0].Replies, 1)
+		assert.Equal(t, reply.ID, list.Items[0].Replies[0].ID)
+		assert.Contains(t, resp.Header.Get(fiber.HeaderLink), `rel="next"`)
+		var last models.CommentList
+		app.call(http.MethodGet, comments+"?limit=2&after="+list.NextCursor, "", &last)
+		assert.Len(t, last.Items, 1)
+		assert.Empty(t, last.NextCursor)
+
+		resp = app.call(http.MethodGet, comments+"?limit=0", "", nil)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		resp = app.call(http.MethodGet, "/blog-post/999/comments", "", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+	t.Run("Edit", func(t *testing.T) {
+		var edited models.Comment
+		resp := app.call(http.MethodPut, fmt.Sprintf("%s/%d", comments, thread.ID), `{"body":"First, edited"}`, &edited)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "First, edited", edited.Body)
+		assert.Equal(t, models.CommentPending, edited.Status)
+
+		resp = app.call(http.MethodPut, fmt.Sprintf("%s/%d", comments, thread.ID), `{"body":"Moved","parent_id":1}`, nil)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "edits cannot move a comment")
+		resp = app.call(http.MethodPut, fmt.Sprintf("%s/999", comments), `{"body":"Missing"}`, nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+	t.Run("Delete", func(t *testing.T) {
+		resp := app.call(http.MethodDelete, fmt.Sprintf("%s/%d", comments, thread.ID), "", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		resp = app.call(http.MethodDelete, fmt.Sprintf("%s/%d", comments, thread.ID), "", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		var list models.CommentList
+		app.call(http.MethodGet, comments, "", &list)
+		assert.Equal(t, 2, list.Total)
+	})
+	t.Run("Deleted blog", func(t *testing.T) {
+		resp := app.call(http.MethodGet, comments, "", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, h.Store.DeleteBlog(context.Background(), blog.ID, db.AnyVersion))
+		resp = app.call(http.MethodGet, comments, "", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, "comments go to the trash with their blog")
+	})
+	t.Run("Unpublished blog", func(t *testing.T) {
+		draft := CreateRandomBlog(t, h)
+		comments := fmt.Sprintf("/blog-post/%d/comments", draft.ID)
+		h.Visibility = visibility{}
+		defer func() { h.Visibility = nil }()
+		for _, test := range []struct{ method, target, body string }{
+			{http.MethodGet, comments, ""},
+			{http.MethodPost, comments, `{"body":"Early"}`},
+			{http.MethodPut, comments + "/1", `{"body":"Edited"}`},
+			{http.MethodDelete, comments + "/1", ""},
+		} {
+			resp := app.call(test.method, test.target, test.body, nil)
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode, "%s %s", test.method, test.target)
+		}
+
+		h.Visibility = visibility{canRead: true}
+		resp := app.call(http.MethodGet, comments, "", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "callers who may update the blog see its comments")
+	})
+}
+
+// visibility lets a request see unpublished blogs if canRead is set
+type visibility struct{ canRead bool }
+
+func (visibility) AllowTrash(*fiber.Ctx) error       { return fiber.ErrForbidden }
+func (visibility) AllowUnpublished(*fiber.Ctx) error { return fiber.ErrForbidden }
+func (v visibility) CanRead(*fiber.Ctx, models.Blog) bool {
+	return v.canRead
+}
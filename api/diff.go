@@ -0,0 +1,192 @@
+package api
+
+import (
+	"blog_post/models"
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// Operations of a models.DiffLine
+const (
+	DiffEqual  = "equal"
+	DiffDelete = "delete"
+	DiffInsert = "insert"
+)
+
+// diffRevisions compares the content of two revisions field by field
+func diffRevisions(from, to models.Revision) models.RevisionDiff {
+	diff := models.RevisionDiff{
+		BlogID:  from.BlogID,
+		From:    from.Version,
+		To:      to.Version,
+		Changes: []models.FieldDiff{},
+	}
+	for _, f := range []struct{ name, old, new string }{
+		{"title", from.Title, to.Title},
+		{"description", from.Description, to.Description},
+		{"body", from.Body, to.Body},
+		{"body_format", string(from.BodyFormat), string(to.BodyFormat)},
+	} {
+		if f.old != f.new {
+			diff.Changes = append(diff.Changes, models.FieldDiff{Field: f.name, Lines: diffLines(f.old, f.new)})
+		}
+	}
+	return diff
+}
+
+// diffLines returns a shortest line-based edit script turning a into b.
+// Deletions come before insertions wherever both are possible.
+func diffLines(a, b string) []models.DiffLine {
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+	lines := diffSlices(x, y, nil)
+
+	// Within each run of changes, list the deletions first; swapping an
+	// adjacent insertion and deletion leaves the script valid
+	for start := 0; start < len(lines); {
+		if lines[start].Op == DiffEqual {
+			start++
+			continue
+		}
+		end := start
+		for end < len(lines) && lines[end].Op != DiffEqual {
+			end++
+		}
+		slices.SortStableFunc(lines[start:end], func(l, r models.DiffLine) int {
+			return cmp.Compare(diffOpOrder(l.Op), diffOpOrder(r.Op))
+		})
+		start = end
+	}
+	return lines
+}
+
+func diffOpOrder(op string) int {
+	if op == DiffDelete {
+		return 0
+	}
+	return 1
+}
+
+// diffMaxSteps bounds the search for a middle snake. Blog bodies run to
+// 100000 lines, and the search costs O((N+M)·D) for an edit distance D;
+// two bodies that differ this much are reported as a whole-field
+// replacement instead.
+const diffMaxSteps = 1000
+
+// diffSlices appends to lines an edit script turning x into y. It strips
+// their common prefix and suffix and splits the rest at a middle snake, in
+// linear space (Myers, "An O(ND) Difference Algorithm and Its Variations").
+func diffSlices(x, y []string, lines []models.DiffLine) []models.DiffLine {
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	for _, line := range x[:prefix] {
+		lines = append(lines, models.DiffLine{Op: DiffEqual, Text: line})
+	}
+	x, y = x[prefix:], y[prefix:]
+
+	suffix := 0
+	for suffix < len(x) && suffix < len(y) && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	common := x[len(x)-suffix:]
+	x, y = x[:len(x)-suffix], y[:len(y)-suffix]
+
+	if i, j, ok := middleSnake(x, y); ok {
+		lines = diffSlices(x[:i], y[:j], lines)
+		lines = diffSlices(x[i:], y[j:], lines)
+	} else {
+		for _, line := range x {
+			lines = append(lines, models.DiffLine{Op: DiffDelete, Text: line})
+		}
+		for _, line := range y {
+			lines = append(lines, models.DiffLine{Op: DiffInsert, Text: line})
+		}
+	}
+
+	for _, line := range common {
+		lines = append(lines, models.DiffLine{Op: DiffEqual, Text: line})
+	}
+	return lines
+}
+
+// middleSnake searches from both ends of x and y at once for the point
+// (i, j) where their furthest-reaching paths meet; a shortest edit script
+// passes through it. It reports false when x and y share no line or the
+// search takes more than diffMaxSteps.
+func middleSnake(x, y []string) (i, j int, ok bool) {
+	n, m := len(x), len(y)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+	maxD := min((n+m+1)/2, diffMaxSteps)
+	offset := maxD + 1
+	// forward[offset+k] is the furthest x reached on diagonal k = x-y from
+	// the start; backward the same from the end, with k counted backwards
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	for k := range forward {
+		forward[k], backward[k] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	delta := n - m
+	odd := delta%2 != 0
+	// Diagonals that ran off the grid are not searched again
+	fStart, fEnd, bStart, bEnd := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			var xf int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				xf = forward[offset+k+1]
+			} else {
+				xf = forward[offset+k-1] + 1
+			}
+			yf := xf - k
+			for xf < n && yf < m && x[xf] == y[yf] {
+				xf, yf = xf+1, yf+1
+			}
+			forward[offset+k] = xf
+			switch {
+			case xf > n:
+				fEnd += 2
+			case yf > m:
+				fStart += 2
+			case odd:
+				if kb := offset + delta - k; kb >= 0 && kb < len(backward) && backward[kb] != -1 {
+					if xf >= n-backward[kb] {
+						return xf, yf, true
+					}
+				}
+			}
+		}
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			var xb int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				xb = backward[offset+k+1]
+			} else {
+				xb = backward[offset+k-1] + 1
+			}
+			yb := xb - k
+			for xb < n && yb < m && x[n-xb-1] == y[m-yb-1] {
+				xb, yb = xb+1, yb+1
+			}
+			backward[offset+k] = xb
+			switch {
+			case xb > n:
+				bEnd += 2
+			case yb > m:
+				bStart += 2
+			case !odd:
+				if kf := offset + delta - k; kf >= 0 && kf < len(forward) && forward[kf] != -1 {
+					xf := forward[kf]
+					if xf >= n-xb {
+						return xf, xf - (kf - offset), true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"blog_post/models"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		description string
+		a, b        string
+		want        []models.DiffLine
+	}{
+		{"single line change", "old", "new", []models.DiffLine{
+			{Op: DiffDelete, Text: "old"},
+			{Op: DiffInsert, Text: "new"},
+		}},
+		{"insert in the middle", "one\nthree", "one\ntwo\nthree", []models.DiffLine{
+			{Op: DiffEqual, Text: "one"},
+			{Op: DiffInsert, Text: "two"},
+			{Op: DiffEqual, Text: "three"},
+		}},
+		{"delete and replace", "a\nb\nc\nd", "a\nc\nx", []models.DiffLine{
+			{Op: DiffEqual, Text: "a"},
+			{Op: DiffDelete, Text: "b"},
+			{Op: DiffEqual, Text: "c"},
+			{Op: DiffDelete, Text: "d"},
+			{Op: DiffInsert, Text: "x"},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			assert.Equal(t, test.want, diffLines(test.a, test.b))
+		})
+	}
+}
+
+func TestDiffRevisions(t *testing.T) {
+	from := models.Revision{BlogID: 7, Version: 1, Title: "Title", Description: "Old", Body: "Body"}
+	to := models.Revision{BlogID: 7, Version: 3, Title: "Title", Description: "New", Body: "Body"}
+	diff := diffRevisions(from, to)
+	assert.Equal(t, int64(7), diff.BlogID)
+	assert.Equal(t, int64(1), diff.From)
+	assert.Equal(t, int64(3), diff.To)
+	assert.Equal(t, []models.FieldDiff{{Field: "description", Lines: []models.DiffLine{
+		{Op: DiffDelete, Text: "Old"},
+		{Op: DiffInsert, Text: "New"},
+	}}}, diff.Changes, "unchanged fields are left out")
+
+	assert.Empty(t, diffRevisions(from, from).Changes)
+}
+
+// applyDiff rebuilds both sides of an edit script
+func applyDiff(lines []models.DiffLine) (a, b string) {
+	var x, y []string
+	for _, line := range lines {
+		if line.Op != DiffInsert {
+			x = append(x, line.Text)
+		}
+		if line.Op != DiffDelete {
+			y = append(y, line.Text)
+		}
+	}
+	return strings.Join(x, "\n"), strings.Join(y, "\n")
+}
+
+func TestDiffLinesShortest(t *testing.T) {
+	// lcsLength is the quadratic reference the edit script must match
+	lcsLength := func(x, y []string) int {
+		lcs := make([][]int, len(x)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(y)+1)
+		}
+		for i := len(x) - 1; i >= 0; i-- {
+			for j := len(y) - 1; j >= 0; j-- {
+				if x[i] == y[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		return lcs[0][0]
+	}
+	random := rand.New(rand.NewPCG(1, 2))
+	text := func() string {
+		lines := make([]string, random.IntN(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + random.IntN(3)))
+		}
+		return strings.Join(lines, "\n")
+	}
+	for range 500 {
+		a, b := text(), text()
+		lines := diffLines(a, b)
+		gotA, gotB := applyDiff(lines)
+		require.Equal(t, a, gotA)
+		require.Equal(t, b, gotB)
+		equal := 0
+		for _, line := range lines {
+			if line.Op == DiffEqual {
+				equal++
+			}
+		}
+		require.Equal(t, lcsLength(strings.Split(a, "\n"), strings.Split(b, "\n")), equal, "%q -> %q", a, b)
+	}
+}
+
+func TestDiffLinesLargeBody(t *testing.T) {
+	// Bodies of 100000 characters hold up to 100000 lines
+	lines := make([]string, 50000)
+	for i := range lines {
+		lines[i] = strconv.Itoa(i)
+	}
+	a := strings.Join(lines, "\n")
+	lines[100], lines[40000] = "changed", "changed"
+	b := strings.Join(lines, "\n")
+
+	diff := diffLines(a, b)
+	assert.Len(t, diff, 50002)
+	assert.Equal(t, models.DiffLine{Op: DiffDelete, Text: "100"}, diff[100])
+	assert.Equal(t, models.DiffLine{Op: DiffInsert, Text: "changed"}, diff[101])
+
+	t.Run("blank lines", func(t *testing.T) {
+		diff := diffLines(strings.Repeat("\n", 99999), strings.Repeat("\n", 50000))
+		assert.Len(t, diff, 100000)
+	})
+
+	t.Run("unrelated bodies", func(t *testing.T) {
+		other := strings.ReplaceAll(a, "1", "x")
+		diff := diffLines(a, other)
+		gotA, gotB := applyDiff(diff)
+		assert.Equal(t, a, gotA)
+		assert.Equal(t, other, gotB)
+	})
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// ProblemContentType is the media type of RFC 7807 error bodies
+const ProblemContentType = "application/problem+json"
+
+// Problem type URIs for the domain errors; anything else is "about:blank"
+// with the HTTP status text as its title
+const (
+	ProblemTypeNotFound     = "/problems/not-found"
+	ProblemTypeValidation   = "/problems/validation"
+	ProblemTypeConflict     = "/problems/conflict"
+	ProblemTypeUnauthorized = "/problems/unauthorized"
+	ProblemTypeForbidden    = "/problems/forbidden"
+
+	ProblemTypePreconditionFailed = "/problems/precondition-failed"
+)
+
+// AuthChallenge is the WWW-Authenticate challenge sent with every 401
+const AuthChallenge = `Bearer realm="blog", Basic realm="blog", charset="UTF-8"`
+
+// ErrorHandler is the app-wide fiber.ErrorHandler. It maps the db package's
+// domain errors to HTTP status codes and renders every failure, including
+// unmatched routes, as an RFC 7807 models.Problem.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	problem := NewProblem(err)
+	problem.Instance = c.OriginalURL()
+	if problem.Status == http.StatusUnauthorized {
+		c.Set(fiber.HeaderWWWAuthenticate, AuthChallenge)
+	}
+
+	if problem.Status >= http.StatusInternalServerError {
+		log.Errorf("%s %s failed: %v", c.Method(), c.Path(), err)
+	} else {
+		log.Warnf("%s %s rejected: %v", c.Method(), c.Path(), err)
+	}
+	return c.Status(problem.Status).JSON(problem, ProblemContentType)
+}
+
+// NewProblem builds the problem document describing err
+func NewProblem(err error) models.Problem {
+	problem := models.Problem{
+		Type:   "about:blank",
+		Status: http.StatusInternalServerError,
+		Detail: err.Error(),
+	}
+	var fiberErr *fiber.Error
+	var validationErr *db.ValidationError
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		problem.Type = ProblemTypeNotFound
+		problem.Title = "Resource not found"
+		problem.Status = http.StatusNotFound
+	case errors.Is(err, db.ErrValidation):
+		problem.Type = ProblemTypeValidation
+		problem.Title = "Validation failed"
+		problem.Status = http.StatusBadRequest
+		if errors.As(err, &validationErr) {
+			problem.Errors = validationErr.Fields
+		}
+	case errors.Is(err, db.ErrConflict):
+		problem.Type = ProblemTypeConflict
+		problem.Title = "Conflict"
+		problem.Status = http.StatusConflict
+	case errors.Is(err, db.ErrUnauthorized):
+		problem.Type = ProblemTypeUnauthorized
+		problem.Title = "Unauthorized"
+		problem.Status = http.StatusUnauthorized
+	case errors.Is(err, db.ErrForbidden):
+		problem.Type = ProblemTypeForbidden
+		problem.Title = "Forbidden"
+		problem.Status = http.StatusForbidden
+	case errors.Is(err, db.ErrPreconditionFailed):
+		problem.Type = ProblemTypePreconditionFailed
+		problem.Title = "Precondition failed"
+		problem.Status = http.StatusPreconditionFailed
+	case errors.As(err, &fiberErr):
+		problem.Status = fiberErr.Code
+		problem.Detail = fiberErr.Message
+	}
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+	if problem.Status >= http.StatusInternalServerError {
+		// Server failures may carry driver or file system detail; ErrorHandler
+		// logs the full error and clients get the status text only
+		problem.Detail = http.StatusText(problem.Status)
+	}
+	return problem
+}
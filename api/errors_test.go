@@ -0,0 +1,95 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorHandler(t *testing.T) {
+	tests := []struct {
+		description  string
+		err          error
+		expectedType string
+		expectedCode int
+		expectedBody string
+	}{
+		{"not found", &db.NotFoundError{Resource: "blog"}, ProblemTypeNotFound, http.StatusNotFound, "blog not found"},
+		{"wrapped not found", fmt.Errorf("lookup: %w", db.ErrNotFound), ProblemTypeNotFound, http.StatusNotFound, "lookup: not found"},
+		{"validation", &db.ValidationError{Fields: []models.FieldError{{Field: "title", Message: "is required"}}}, ProblemTypeValidation, http.StatusBadRequest, "validation failed: title is required"},
+		{"conflict", &db.ConflictError{Reason: "slug already taken"}, ProblemTypeConflict, http.StatusConflict, "slug already taken"},
+		{"unauthorized", &db.UnauthorizedError{Reason: "invalid username or password"}, ProblemTypeUnauthorized, http.StatusUnauthorized, "invalid username or password"},
+		{"forbidden", &db.ForbiddenError{Reason: "readers may not blog:create"}, ProblemTypeForbidden, http.StatusForbidden, "readers may not blog:create"},
+		{"precondition failed", &db.VersionMismatchError{Expected: 1, Actual: 2}, ProblemTypePreconditionFailed, http.StatusPreconditionFailed, "version mismatch: expected 1, current is 2"},
+		{"precondition", &db.PreconditionError{Reason: `If-Match "1", "3" does not match the current ETag "2"`}, ProblemTypePreconditionFailed, http.StatusPreconditionFailed, `If-Match "1", "3" does not match the current ETag "2"`},
+		{"fiber error", fiber.NewError(http.StatusTeapot, "short and stout"), "about:blank", http.StatusTeapot, "short and stout"},
+		{"unexpected", errors.New("disk on fire"), "about:blank", http.StatusInternalServerError, "Internal Server Error"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+			app.Get("/fail", func(c *fiber.Ctx) error { return test.err })
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/fail?x=1", nil))
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			assert.Equal(t, ProblemContentType, resp.Header.Get("Content-Type"))
+			var body models.Problem
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, test.expectedType, body.Type)
+			assert.Equal(t, test.expectedCode, body.Status)
+			assert.Equal(t, test.expectedBody, body.Detail)
+			assert.Equal(t, "/fail?x=1", body.Instance)
+			assert.NotEmpty(t, body.Title)
+			if test.expectedCode == http.StatusUnauthorized {
+				assert.Equal(t, AuthChallenge, resp.Header.Get(fiber.HeaderWWWAuthenticate))
+			}
+		})
+	}
+}
+
+func TestValidationProblem(t *testing.T) {
+	fields := []models.FieldError{
+		{Field: "title", Message: "is required"},
+		{Field: "body", Message: "is required"},
+	}
+	problem := NewProblem(fmt.Errorf("create: %w", &db.ValidationError{Fields: fields}))
+	assert.Equal(t, http.StatusBadRequest, problem.Status)
+	assert.Equal(t, "Validation failed", problem.Title)
+	assert.Equal(t, fields, problem.Errors)
+}
+
+// brokenStore fails every blog lookup the way a database driver would
+type brokenStore struct{ db.BlogStore }
+
+func (brokenStore) GetBlog(context.Context, int64) (models.Blog, error) {
+	return models.Blog{}, errors.New(`pq: relation "blogs" does not exist at /var/lib/blog/schema.sql`)
+}
+
+func TestStoreFailureProblem(t *testing.T) {
+	h := NewHandler(db.NewRepo())
+	h.Store = brokenStore{h.Store}
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/blog-post/:id", h.GetBlog)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/blog-post/1", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	raw, _ := io.ReadAll(resp.Body)
+	assert.NotContains(t, string(raw), "blogs")
+	assert.NotContains(t, string(raw), "schema.sql")
+	var body models.Problem
+	assert.NoError(t, json.Unmarshal(raw, &body))
+	assert.Equal(t, http.StatusText(http.StatusInternalServerError), body.Detail)
+}
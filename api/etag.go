@@ -0,0 +1,92 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// etag is the strong entity tag of a blog at the given version
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// setETag advertises the version of blog on the response
+func setETag(c *fiber.Ctx, blog models.Blog) {
+	c.Set(fiber.HeaderETag, etag(blog.Version))
+}
+
+// entityTags splits an If-Match or If-None-Match header into its tags
+func entityTags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// tagVersion returns the version named by a strong entity tag; weak and
+// foreign tags never match under If-Match's strong comparison
+func tagVersion(tag string) (int64, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// ifMatchVersion turns the If-Match header into the version a write must
+// find. No header or "*" means any version; the store still reports a
+// missing blog. A single tag is passed through so the repository checks it
+// atomically; a list, or a tag naming no version, is resolved against the
+// current version first so the mismatch can report it.
+func (h *Handler) ifMatchVersion(c *fiber.Ctx, id int64) (int64, error) {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" || header == "*" {
+		return db.AnyVersion, nil
+	}
+	tags := entityTags(header)
+	if len(tags) == 1 {
+		if version, ok := tagVersion(tags[0]); ok {
+			return version, nil
+		}
+	}
+	current, err := h.Store.GetBlog(c.UserContext(), id)
+	if err != nil {
+		return 0, err
+	}
+	for _, tag := range tags {
+		if version, ok := tagVersion(tag); ok && version == current.Version {
+			return version, nil
+		}
+	}
+	return 0, &db.PreconditionError{Reason: fmt.Sprintf("If-Match %s does not match the current ETag %s",
+		strings.Join(tags, ", "), etag(current.Version))}
+}
+
+// notModified reports whether the If-None-Match header matches blog, using
+// the weak comparison RFC 9110 prescribes for GET
+func notModified(c *fiber.Ctx, blog models.Blog) bool {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfNoneMatch))
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	for _, tag := range entityTags(header) {
+		if version, ok := tagVersion(strings.TrimPrefix(tag, "W/")); ok && version == blog.Version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETags(t *testing.T) {
+	h := NewHandler(db.NewRepo())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/blog-post/:id", h.GetBlog)
+	app.Put("/blog-post/:id", h.UpdateBlog)
+	app.Patch("/blog-post/:id", h.PatchBlog)
+	app.Delete("/blog-post/:id", h.DeleteBlog)
+	send := func(method string, id int64, headers map[string]string, body string) *http.Response {
+		req := httptest.NewRequest(method, fmt.Sprintf("/blog-post/%d", id), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp
+	}
+	problemType := func(resp *http.Response) string {
+		var problem models.Problem
+		json.NewDecoder(resp.Body).Decode(&problem)
+		return problem.Type
+	}
+	const update = `{"title":"New Title","description":"New Description","body":"New Body"}`
+
+	t.Run("GET returns the version as ETag", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		resp := send(http.MethodGet, created.ID, nil, "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+	})
+	t.Run("If-None-Match", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		for _, tag := range []string{`"1"`, `W/"1"`, `"7", "1"`, `*`} {
+			resp := send(http.MethodGet, created.ID, map[string]string{"If-None-Match": tag}, "")
+			assert.Equal(t, http.StatusNotModified, resp.StatusCode, tag)
+			assert.Equal(t, `"1"`, resp.Header.Get("ETag"), tag)
+		}
+		resp := send(http.MethodGet, created.ID, map[string]string{"If-None-Match": `"2"`}, "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+	t.Run("PUT with matching If-Match", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		resp := send(http.MethodPut, created.ID, map[string]string{"If-Match": `"1"`}, update)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+	})
+	t.Run("PUT with stale If-Match", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		send(http.MethodPut, created.ID, nil, update)
+		for _, tag := range []string{`"1"`, `W/"2"`, `"1", "3"`, `garbage`} {
+			resp := send(http.MethodPut, created.ID, map[string]string{"If-Match": tag}, update)
+			assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode, tag)
+		}
+		resp := send(http.MethodPut, created.ID, map[string]string{"If-Match": `"1"`}, update)
+		assert.Equal(t, ProblemTypePreconditionFailed, problemType(resp))
+		resp = send(http.MethodPut, created.ID, map[string]string{"If-Match": `"1", "3"`}, update)
+		var problem models.Problem
+		json.NewDecoder(resp.Body).Decode(&problem)
+		assert.Equal(t, ProblemTypePreconditionFailed, problem.Type)
+		assert.Equal(t, `If-Match "1", "3" does not match the current ETag "2"`, problem.Detail)
+
+		resp = send(http.MethodPut, created.ID, map[string]string{"If-Match": `"1", "2"`}, update)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "any listed tag may match")
+	})
+	t.Run("If-Match on a missing blog", func(t *testing.T) {
+		resp := send(http.MethodPut, 1000, map[string]string{"If-Match": `"1"`}, update)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+	t.Run("PATCH with If-Match", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		headers := map[string]string{"Content-Type": MergePatchContentType, "If-Match": `"2"`}
+		resp := send(http.MethodPatch, created.ID, headers, `{"title":"Patched"}`)
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+		headers["If-Match"] = `"1"`
+		resp = send(http.MethodPatch, created.ID, headers, `{"title":"Patched"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+	})
+	t.Run("DELETE with If-Match", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		resp := send(http.MethodDelete, created.ID, map[string]string{"If-Match": `"2"`}, "")
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+		resp = send(http.MethodDelete, created.ID, map[string]string{"If-Match": `"1"`}, "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"blog_post/db"
 	"blog_post/models"
+	"context"
 	"fmt"
 	"io"
 
@@ -9,15 +11,17 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func CreateRandomBlog(t *testing.T) models.Blog {
-	app := fiber.New()
-	app.Post("/blog-post", CreateBlog)
+func CreateRandomBlog(t *testing.T, h *Handler) models.Blog {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/blog-post", h.CreateBlog)
 	var createdBlog models.Blog
 	t.Run("Successful creation", func(t *testing.T) {
 		reqBody := models.BlogRequestBody{
@@ -36,35 +40,186 @@ func CreateRandomBlog(t *testing.T) models.Blog {
 	})
 	return createdBlog
 }
+
+// PublishRandomBlog creates a blog and publishes it so that it is listed
+func PublishRandomBlog(t *testing.T, h *Handler) models.Blog {
+	created := CreateRandomBlog(t, h)
+	published, err := h.Store.TransitionBlog(context.Background(), created.ID, models.StatusPublished, nil, db.AnyVersion)
+	assert.NoError(t, err)
+	return published
+}
+
+// testApp is a fiber app that answers errors the way the server does, for
+// tests to mount handlers on and send requests to
+type testApp struct {
+	*fiber.App
+	t *testing.T
+}
+
+func newTestApp(t *testing.T) *testApp {
+	return &testApp{App: fiber.New(fiber.Config{ErrorHandler: ErrorHandler}), t: t}
+}
+
+// call sends a request with body, if any, as JSON and decodes the response
+// into out unless it is nil
+func (a *testApp) call(method, target, body string, out any) *http.Response {
+	return a.send(method, target, nil, body, out)
+}
+
+// send is call with extra request headers
+func (a *testApp) send(method, target string, headers map[string]string, body string, out any) *http.Response {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	// No timeout: logins and new accounts spend a while in bcrypt
+	resp, err := a.Test(req, -1)
+	require.NoError(a.t, err)
+	if out != nil {
+		json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp
+}
 func TestGetAllBlogs(t *testing.T) {
-	app := fiber.New()
-	app.Get("/blog-posts", GetAllBlogs)
+	h := NewHandler(db.NewRepo())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/blog-posts", h.GetAllBlogs)
 	t.Run("No blogs in DB", func(t *testing.T) {
-		expectedBlogs := []models.Blog{}
 		req := httptest.NewRequest(http.MethodGet, "/blog-posts", nil)
 		resp, _ := app.Test(req)
-		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
-		var actualBlogs []models.Blog
-		json.NewDecoder(resp.Body).Decode(&actualBlogs)
-		assert.Equal(t, len(expectedBlogs), len(actualBlogs))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.JSONEq(t, `{"items":[],"total":0}`, string(body))
 	})
 	t.Run("Successful retrieval", func(t *testing.T) {
-		expectedBlogs := CreateRandomBlog(t)
+		expectedBlogs := PublishRandomBlog(t, h)
+		CreateRandomBlog(t, h)
 		req := httptest.NewRequest("GET", "/blog-posts", nil)
 		resp, _ := app.Test(req)
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
-		var actualBlogs []models.Blog
+		var actualBlogs models.BlogList
 		json.NewDecoder(resp.Body).Decode(&actualBlogs)
-		assert.Equal(t, expectedBlogs.ID, actualBlogs[0].ID)
-		assert.Equal(t, expectedBlogs.Title, actualBlogs[0].Title)
-		assert.Equal(t, expectedBlogs.Description, actualBlogs[0].Description)
-		assert.Equal(t, expectedBlogs.Body, actualBlogs[0].Body)
+		assert.Equal(t, 1, actualBlogs.Total)
+		assert.Equal(t, expectedBlogs.ID, actualBlogs.Items[0].ID)
+		assert.Equal(t, expectedBlogs.Title, actualBlogs.Items[0].Title)
+		assert.Equal(t, expectedBlogs.Description, actualBlogs.Items[0].Description)
+		assert.Equal(t, expectedBlogs.Body, actualBlogs.Items[0].Body)
+	})
+	t.Run("Filter by status", func(t *testing.T) {
+		for query, total := range map[string]int{"?status=draft": 1, "?status=all": 2, "?status=archived": 0} {
+			resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/blog-posts"+query, nil))
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			var page models.BlogList
+			json.NewDecoder(resp.Body).Decode(&page)
+			assert.Equal(t, total, page.Total, query)
+		}
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/blog-posts?status=hidden", nil))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestGetAllBlogsPagination(t *testing.T) {
+	h := NewHandler(db.NewRepo())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/blog-posts", h.GetAllBlogs)
+	var created []models.Blog
+	for i := 0; i < 3; i++ {
+		created = append(created, PublishRandomBlog(t, h))
+	}
+	t.Run("Newest first by default", func(t *testing.T) {
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/blog-posts", nil))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var page models.BlogList
+		json.NewDecoder(resp.Body).Decode(&page)
+		assert.Equal(t, created[2].ID, page.Items[0].ID)
+		assert.Empty(t, resp.Header.Get("Link"))
+	})
+	t.Run("Follow next link", func(t *testing.T) {
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/blog-posts?limit=2&order=asc", nil))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var page models.BlogList
+		json.NewDecoder(resp.Body).Decode(&page)
+		assert.Equal(t, 3, page.Total)
+		assert.Equal(t, []int64{created[0].ID, created[1].ID}, []int64{page.Items[0].ID, page.Items[1].ID})
+		link := resp.Header.Get("Link")
+		assert.Contains(t, link, `rel="next"`)
+		assert.NotContains(t, link, `rel="prev"`)
+		assert.Contains(t, link, "after="+page.NextCursor)
+		assert.Contains(t, link, "order=asc")
+
+		next := link[strings.Index(link, "/blog-posts"):strings.Index(link, ">")]
+		resp, _ = app.Test(httptest.NewRequest(http.MethodGet, next, nil))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		json.NewDecoder(resp.Body).Decode(&page)
+		assert.Len(t, page.Items, 1)
+		assert.Equal(t, created[2].ID, page.Items[0].ID)
+		link = resp.Header.Get("Link")
+		assert.Contains(t, link, `rel="prev"`)
+		assert.NotContains(t, link, `rel="next"`)
+	})
+	t.Run("Invalid query parameters", func(t *testing.T) {
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/blog-posts?limit=0&order=sideways&sort=body", nil))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		var problem models.Problem
+		json.NewDecoder(resp.Body).Decode(&problem)
+		assert.Equal(t, []models.FieldError{
+			{Field: "limit", Message: "must be an integer between 1 and 100"},
+			{Field: "order", Message: "must be asc or desc"},
+		}, problem.Errors)
+
+		resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/blog-posts?sort=body", nil))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/blog-posts?after=garbage", nil))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestSearchBlogs(t *testing.T) {
+	h := NewHandler(db.NewRepo())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/blog-posts", h.GetAllBlogs)
+	for _, req := range []models.BlogRequestBody{
+		{Title: "Go generics", Description: "Type parameters", Body: "Generics landed in Go 1.18."},
+		{Title: "Go modules", Description: "Dependency management", Body: "Modules replaced GOPATH; generics came later."},
+		{Title: "Rust traits", Description: "Polymorphism", Body: "Traits are not generics in the Go sense."},
+	} {
+		blog, err := h.Store.CreateBlog(context.Background(), req)
+		assert.NoError(t, err)
+		_, err = h.Store.TransitionBlog(context.Background(), blog.ID, models.StatusPublished, nil, db.AnyVersion)
+		assert.NoError(t, err)
+	}
+	_, err := h.Store.CreateBlog(context.Background(), models.BlogRequestBody{
+		Title: "Go generics draft", Description: "Unpublished", Body: "Not ready yet.",
+	})
+	assert.NoError(t, err)
+	t.Run("Ranked results", func(t *testing.T) {
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/blog-posts?q=go+generics&limit=2", nil))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var results models.SearchResults
+		json.NewDecoder(resp.Body).Decode(&results)
+		assert.Equal(t, 3, results.Total)
+		assert.Len(t, results.Items, 2)
+		assert.Equal(t, "Go generics", results.Items[0].Title)
+		assert.Equal(t, "<mark>Go</mark> <mark>generics</mark>", results.Items[0].Highlights["title"])
+		assert.Contains(t, resp.Header.Get("Link"), `rel="next"`)
+	})
+	t.Run("Sorting is not allowed", func(t *testing.T) {
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/blog-posts?q=go&sort=title", nil))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+	t.Run("Query without words", func(t *testing.T) {
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/blog-posts?q=%22%22", nil))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 	})
 }
 
 func TestCreateBlog(t *testing.T) {
-	app := fiber.New()
-	app.Post("/blog-post", CreateBlog)
+	h := NewHandler(db.NewRepo())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/blog-post", h.CreateBlog)
 	var createdBlog models.Blog
 	t.Run("Successful creation", func(t *testing.T) {
 		reqBody := models.BlogRequestBody{
@@ -103,22 +258,28 @@ func TestCreateBlog(t *testing.T) {
 		req := httptest.NewRequest("POST", "/blog-post", bytes.NewReader([]byte(body)))
 		req.Header.Set("Content-Type", "application/json")
 		resp, _ := app.Test(req)
-		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 		response, _ := io.ReadAll(resp.Body)
-		var result map[string]string
+		var result models.Problem
 		json.Unmarshal(response, &result)
-		assert.Contains(t, result["error"], "missing required field")
+		assert.Equal(t, ProblemContentType, resp.Header.Get("Content-Type"))
+		assert.Equal(t, ProblemTypeValidation, result.Type)
+		assert.Equal(t, []models.FieldError{
+			{Field: "title", Message: "is required"},
+			{Field: "description", Message: "is required"},
+		}, result.Errors)
 	})
 }
 
 func TestGetBlog(t *testing.T) {
-	app := fiber.New()
-	app.Get("/blog-post/:id", GetBlog)
+	h := NewHandler(db.NewRepo())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/blog-post/:id", h.GetBlog)
 	t.Run("No blogs in DB", func(t *testing.T) {
 		expectedBlogs := []models.Blog{}
 		req := httptest.NewRequest("GET", fmt.Sprintf("/blog-post/%d", 1000), nil)
 		resp, _ := app.Test(req)
-		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
 		var actualBlogs []models.Blog
 		json.NewDecoder(resp.Body).Decode(&actualBlogs)
 		assert.Equal(t, len(expectedBlogs), len(actualBlogs))
@@ -133,7 +294,7 @@ func TestGetBlog(t *testing.T) {
 		assert.Equal(t, len(expectedBlogs), len(actualBlogs))
 	})
 	t.Run("Successful retrieval", func(t *testing.T) {
-		expectedBlogs := CreateRandomBlog(t)
+		expectedBlogs := CreateRandomBlog(t, h)
 		u, _ := json.Marshal(expectedBlogs)
 		fmt.Println(string(u))
 		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/blog-post/%d", expectedBlogs.ID), nil)
@@ -148,9 +309,41 @@ func TestGetBlog(t *testing.T) {
 	})
 }
 
+func TestGetBlogBySlug(t *testing.T) {
+	h := NewHandler(db.NewRepo())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/blog-post/by-slug/:slug", h.GetBlogBySlug)
+	created := CreateRandomBlog(t, h)
+	t.Run("Successful retrieval", func(t *testing.T) {
+		assert.Equal(t, "test-title", created.Slug)
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/blog-post/by-slug/test-title", nil))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+		var blog models.Blog
+		json.NewDecoder(resp.Body).Decode(&blog)
+		assert.Equal(t, created.ID, blog.ID)
+	})
+	t.Run("Old slug redirects", func(t *testing.T) {
+		_, err := h.Store.UpdateBlog(context.Background(), created.ID, models.BlogRequestBody{
+			Title:       "Renamed Title",
+			Description: created.Description,
+			Body:        created.Body,
+		}, db.AnyVersion)
+		assert.NoError(t, err)
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/blog-post/by-slug/test-title", nil))
+		assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+		assert.Equal(t, "/blog-post/by-slug/renamed-title", resp.Header.Get("Location"))
+	})
+	t.Run("Unknown slug", func(t *testing.T) {
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/blog-post/by-slug/nothing-here", nil))
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
 func TestDeleteBlog(t *testing.T) {
-	app := fiber.New()
-	app.Delete("/blog-post/:id", DeleteBlog)
+	h := NewHandler(db.NewRepo())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Delete("/blog-post/:id", h.DeleteBlog)
 	t.Run("Invalid Blog ID", func(t *testing.T) {
 		expectedBlogs := []models.Blog{}
 		req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/blog-post/%s", "a"), nil)
@@ -164,13 +357,13 @@ func TestDeleteBlog(t *testing.T) {
 		expectedBlogs := []models.Blog{}
 		req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/blog-post/%d", 1000), nil)
 		resp, _ := app.Test(req)
-		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
 		var actualBlogs []models.Blog
 		json.NewDecoder(resp.Body).Decode(&actualBlogs)
 		assert.Equal(t, len(expectedBlogs), len(actualBlogs))
 	})
 	t.Run("Successful Deleteion", func(t *testing.T) {
-		expectedBlogs := CreateRandomBlog(t)
+		expectedBlogs := CreateRandomBlog(t, h)
 		req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/blog-post/%d", expectedBlogs.ID), nil)
 		resp, _ := app.Test(req)
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
@@ -181,8 +374,9 @@ func TestDeleteBlog(t *testing.T) {
 }
 
 func TestUpdateBlog(t *testing.T) {
-	app := fiber.New()
-	app.Put("/blog-post/:id", UpdateBlog)
+	h := NewHandler(db.NewRepo())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Put("/blog-post/:id", h.UpdateBlog)
 	t.Run("Invalid Blog ID", func(t *testing.T) {
 		expectedBlogs := []models.Blog{}
 		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/blog-post/%s", "a"), nil)
@@ -194,7 +388,7 @@ func TestUpdateBlog(t *testing.T) {
 	})
 	var createdBlog models.Blog
 	t.Run("Successful Updation", func(t *testing.T) {
-		expectedBlogs := CreateRandomBlog(t)
+		expectedBlogs := CreateRandomBlog(t, h)
 		reqBody := models.BlogRequestBody{
 			Title:       "Test Updated Title",
 			Description: "Test Updated Description",
@@ -219,6 +413,28 @@ func TestUpdateBlog(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 	})
+	t.Run("Blog not found", func(t *testing.T) {
+		reqBody := models.BlogRequestBody{
+			Title:       "Test Updated Title",
+			Description: "Test Updated Description",
+			Body:        "Test Updated Body",
+		}
+		body, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/blog-post/%d", 1000), bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		response, _ := io.ReadAll(resp.Body)
+		var result models.Problem
+		json.Unmarshal(response, &result)
+		assert.Equal(t, models.Problem{
+			Type:     ProblemTypeNotFound,
+			Title:    "Resource not found",
+			Status:   http.StatusNotFound,
+			Detail:   "blog not found",
+			Instance: "/blog-post/1000",
+		}, result)
+	})
 	t.Run("Empty request body", func(t *testing.T) {
 		reqBody := models.BlogRequestBody{
 			Title:       "",
@@ -229,10 +445,15 @@ func TestUpdateBlog(t *testing.T) {
 		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/blog-post/%d", 1000), bytes.NewReader([]byte(body)))
 		req.Header.Set("Content-Type", "application/json")
 		resp, _ := app.Test(req)
-		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 		response, _ := io.ReadAll(resp.Body)
-		var result map[string]string
+		var result models.Problem
 		json.Unmarshal(response, &result)
-		assert.Contains(t, result["error"], "missing required field")
+		assert.Equal(t, ProblemContentType, resp.Header.Get("Content-Type"))
+		assert.Equal(t, ProblemTypeValidation, result.Type)
+		assert.Equal(t, []models.FieldError{
+			{Field: "title", Message: "is required"},
+			{Field: "description", Message: "is required"},
+		}, result.Errors)
 	})
 }
@@ -4,130 +4,275 @@ import (
 	"blog_post/db"
 	"blog_post/models"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/log"
 )
 
+// Handler serves the blog, category, comment, user and API key endpoints
+// on top of a store. Visibility restricts what callers may read; when nil,
+// as in tests of the handlers alone, nothing is hidden. Tokens hands out the
+// credentials of logged in users; without it logging in answers 501.
+type Handler struct {
+	Store      db.BlogStore
+	Categories db.CategoryStore
+	Comments   db.CommentStore
+	Users      db.UserStore
+	Keys       db.APIKeyStore
+	Visibility Visibility
+	Tokens     TokenIssuer
+}
+
+// TokenIssuer hands out bearer tokens that authenticate as a user. The JWT
+// middleware's settings implement it, so the tokens are ones it accepts.
+type TokenIssuer interface {
+	IssueToken(user models.User) (models.LoginResponse, error)
+}
+
+// NewHandler returns a Handler backed by the given store
+func NewHandler(store db.Store) *Handler {
+	return &Handler{Store: store, Categories: store, Comments: store, Users: store, Keys: store}
+}
+
 // @Summary lists all blogs
-// @Description Endpoint to list all blog posts
+// @Description Endpoint to list blog posts one page at a time. Follow the `next`/`prev` links in the Link header (or pass next_cursor/prev_cursor as after/before) to walk the collection.
+// @Description
+// @Description Only published blogs are listed unless `status` asks for another one (or `all`), which only callers who may update any blog can do. With `deleted=true` the trash is listed instead of live blogs, in every status unless `status` is given; only callers who may restore or delete any blog can list it. `tag` and `category` narrow the listing to blogs with that tag (normalized like stored tags) and filed under the category with that slug.
+// @Description
+// @Description With `q` the endpoint runs a full-text search over title, description and body instead and returns models.SearchResults, best match first. Words must all match; `"quoted words"` match a phrase and `word*` matches a prefix. `sort`, `order`, `before`, `deleted`, `tag` and `category` cannot be combined with `q`.
 // @Tags Blogs
 // @Produce json
-// @Success 200 {array} models.Blog "Successful Response"
-// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
-// @Failure 400 {object} models.ErrorResponse "Bad Request"
+// @Param q query string false "Full-text search query"
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param after query string false "Cursor: return the page after this position"
+// @Param before query string false "Cursor: return the page before this position"
+// @Param sort query string false "Sort field" Enums(created_at, updated_at, title) default(created_at)
+// @Param order query string false "Sort direction" Enums(asc, desc) default(desc)
+// @Param status query string false "Only blogs in this status" Enums(draft, scheduled, published, archived, all) default(published)
+// @Param deleted query bool false "List the trash instead" default(false)
+// @Param tag query string false "Only blogs with this tag"
+// @Param category query string false "Only blogs in the category with this slug"
+// @Success 200 {object} models.BlogList "Successful Response"
+// @Header 200 {string} Link "RFC 8288 links to the next and previous pages"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
 // @Router /blog-posts [get]
-func GetAllBlogs(c *fiber.Ctx) error {
-	blogs, err := db.DB.GetAllBlogs()
+func (h *Handler) GetAllBlogs(c *fiber.Ctx) error {
+	if c.Query("q") != "" {
+		return h.searchBlogs(c)
+	}
+	opts, err := listOptions(c)
+	if err != nil {
+		return err
+	}
+	if opts.Deleted {
+		if err := h.allowTrash(c); err != nil {
+			return err
+		}
+	}
+	if err := h.allowStatus(c, opts.Status); err != nil {
+		return err
+	}
+	page, err := h.Store.GetAllBlogs(c.UserContext(), opts)
+	if err != nil {
+		return err
+	}
+	setPageLinks(c, page.NextCursor, page.PrevCursor)
+	return c.Status(http.StatusOK).JSON(page)
+}
+
+// searchBlogs serves GET /blog-posts?q=...
+func (h *Handler) searchBlogs(c *fiber.Ctx) error {
+	opts, err := searchOptions(c)
+	if err != nil {
+		return err
+	}
+	if err := h.allowStatus(c, opts.Status); err != nil {
+		return err
+	}
+	results, err := h.Store.SearchBlogs(c.UserContext(), c.Query("q"), opts)
 	if err != nil {
-		log.Errorf("GetAllBlogs failed: %v", err)
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
-	return c.Status(http.StatusOK).JSON(blogs)
+	setPageLinks(c, results.NextCursor, "")
+	return c.Status(http.StatusOK).JSON(results)
 }
 
 // @Summary fetch a blog
-// @Description Endpoint to fetch a blog by id
+// @Description Endpoint to fetch a blog by id. Blogs that are not published are only shown to callers who may update them. The response carries the blog's version as an ETag; send it back in If-None-Match to get 304 when nothing changed.
 // @Tags Blog
 // @Produce json
 // @Param id path int64 true "Blog ID"
+// @Param If-None-Match header string false "ETag from a previous response"
 // @Success 200 {object} models.Blog "Successful Response"
-// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
-// @Failure 400 {object} models.ErrorResponse "Bad Request"
-// @Failure 404 {object} string "Not Found"
+// @Header 200 {string} ETag "Blog version"
+// @Success 304 "Not Modified"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 404 {object} models.Problem "Not Found"
 // @Router /blog-post/{id} [get]
-func GetBlog(c *fiber.Ctx) error {
+func (h *Handler) GetBlog(c *fiber.Ctx) error {
 	id := c.Params("id")
 	blogID, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
-		log.Errorf("GetBlog failed: %v", err)
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return fiber.NewError(http.StatusBadRequest, err.Error())
 	}
-	blog, err := db.DB.GetBlog(blogID)
+	blog, err := h.readableBlog(c, blogID)
 	if err != nil {
-		log.Errorf("GetBlog failed: %v", err)
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
+	}
+	setETag(c, blog)
+	if notModified(c, blog) {
+		return c.SendStatus(http.StatusNotModified)
+	}
+	return c.Status(http.StatusOK).JSON(blog)
+}
+
+// @Summary fetch a blog by slug
+// @Description Endpoint to fetch a blog by its URL slug. Blogs that are not published are only shown to callers who may update them. A slug the blog had before a title change answers 301 with the current slug's URL in Location.
+// @Tags Blog
+// @Produce json
+// @Param slug path string true "Blog slug"
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Success 200 {object} models.Blog "Successful Response"
+// @Header 200 {string} ETag "Blog version"
+// @Success 301 "Moved Permanently to the current slug"
+// @Header 301 {string} Location "URL of the current slug"
+// @Success 304 "Not Modified"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /blog-post/by-slug/{slug} [get]
+func (h *Handler) GetBlogBySlug(c *fiber.Ctx) error {
+	slug := c.Params("slug")
+	blog, err := h.Store.GetBlogBySlug(c.UserContext(), slug)
+	if err != nil {
+		return err
+	}
+	if !h.canRead(c, blog) {
+		return &db.NotFoundError{Resource: "blog"}
+	}
+	if blog.Slug != slug {
+		location := strings.TrimSuffix(c.Path(), slug) + url.PathEscape(blog.Slug)
+		return c.Redirect(location, http.StatusMovedPermanently)
+	}
+	setETag(c, blog)
+	if notModified(c, blog) {
+		return c.SendStatus(http.StatusNotModified)
 	}
 	return c.Status(http.StatusOK).JSON(blog)
 }
 
 // @Summary create a blog
-// @Description Endpoint to create a blog
+// @Description Endpoint to create a blog. New blogs are drafts until they are published or scheduled. The slug is derived from the title unless one is given; a requested slug that is taken answers 409.
+// @Description
+// @Description Requires HTTP Basic credentials, a bearer JWT or an API key; when the caller is a user it becomes the blog's author_id.
 // @Tags Blog
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Produce json
 // @Accept json
 // @Param request body models.BlogRequestBody true "Blog Request Body"
 // @Success 201 {object} models.Blog "Successful Response"
-// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
-// @Failure 400 {object} models.ErrorResponse "Bad Request"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 409 {object} models.Problem "Slug already taken"
+// @Failure 413 {object} models.Problem "Request Entity Too Large"
+// @Failure 415 {object} models.Problem "Unsupported Media Type"
 // @Router /blog-post [post]
-func CreateBlog(c *fiber.Ctx) error {
-	var reqBody models.BlogRequestBody
-	if err := c.BodyParser(&reqBody); err != nil {
-		log.Errorf("CreateBlog failed: %v", err)
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+func (h *Handler) CreateBlog(c *fiber.Ctx) error {
+	reqBody, err := JSONBody[models.BlogRequestBody](c)
+	if err != nil {
+		return err
 	}
-	blog, err := db.DB.CreateBlog(reqBody)
+	blog, err := h.Store.CreateBlog(c.UserContext(), *reqBody)
 	if err != nil {
-		log.Errorf("CreateBlog failed: %v", err)
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
+	setETag(c, blog)
 	return c.Status(http.StatusCreated).JSON(blog)
 }
 
 // @Summary update a blog
-// @Description Endpoint to update a blog by id
+// @Description Endpoint to update a blog by id. Send the blog's ETag in If-Match to update only if nobody changed it since. Changing the title derives a new slug unless one is given; the old slug then redirects to the new one.
 // @Tags Blog
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Produce json
 // @Accept json
 // @Param id path int64 true "Blog ID"
+// @Param If-Match header string false "ETag the blog must still have"
 // @Param request body models.BlogRequestBody true "Blog Request Body"
 // @Success 200 {object} models.Blog "Successful Response"
-// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
-// @Failure 400 {object} models.ErrorResponse "Bad Request"
-// @Failure 404 {object} string "Not Found"
+// @Header 200 {string} ETag "Blog version"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 409 {object} models.Problem "Slug already taken"
+// @Failure 412 {object} models.Problem "Precondition Failed"
+// @Failure 413 {object} models.Problem "Request Entity Too Large"
+// @Failure 415 {object} models.Problem "Unsupported Media Type"
 // @Router /blog-post/{id} [put]
-func UpdateBlog(c *fiber.Ctx) error {
-	var reqBody models.BlogRequestBody
+func (h *Handler) UpdateBlog(c *fiber.Ctx) error {
 	id := c.Params("id")
 	blogID, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
-		log.Errorf("UpdateBlog failed: %v", err)
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return fiber.NewError(http.StatusBadRequest, err.Error())
 	}
-	if err := c.BodyParser(&reqBody); err != nil {
-		log.Errorf("UpdateBlog failed: %v", err)
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	reqBody, err := JSONBody[models.BlogRequestBody](c)
+	if err != nil {
+		return err
 	}
-	blog, err := db.DB.UpdateBlog(blogID, reqBody)
+	ifVersion, err := h.ifMatchVersion(c, blogID)
 	if err != nil {
-		log.Errorf("UpdateBlog failed: %v", err)
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
+	blog, err := h.Store.UpdateBlog(c.UserContext(), blogID, *reqBody, ifVersion)
+	if err != nil {
+		return err
+	}
+	setETag(c, blog)
 	return c.Status(http.StatusOK).JSON(blog)
 }
 
 // @Summary delete a blog
-// @Description Endpoint to delete a blog by id
+// @Description Endpoint to move a blog to the trash by id. Trashed blogs can be restored until they are purged, by hand or once the retention period is over. Send the blog's ETag in If-Match to delete only if nobody changed it since.
 // @Tags Blog
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Produce json
 // @Param id path int64 true "Blog ID"
+// @Param If-Match header string false "ETag the blog must still have"
 // @Success 200 {object} models.SuccessResponse "Successful Response"
-// @Failure 500 {object} models.ErrorResponse "Internal Server Error"
-// @Failure 400 {object} models.ErrorResponse "Bad Request"
-// @Failure 404 {object} string "Not Found"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 412 {object} models.Problem "Precondition Failed"
 // @Router /blog-post/{id} [delete]
-func DeleteBlog(c *fiber.Ctx) error {
+func (h *Handler) DeleteBlog(c *fiber.Ctx) error {
 	id := c.Params("id")
 	blogID, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
-		log.Errorf("DeleteBlog failed: %v", err)
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	ifVersion, err := h.ifMatchVersion(c, blogID)
+	if err != nil {
+		return err
 	}
-	if err := db.DB.DeleteBlog(blogID); err != nil {
-		log.Errorf("DeleteBlog failed: %v", err)
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	if err := h.Store.DeleteBlog(c.UserContext(), blogID, ifVersion); err != nil {
+		return err
 	}
 	return c.Status(http.StatusOK).JSON(fiber.Map{"message": "Blog deleted successfully"})
 }
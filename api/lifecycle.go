@@ -0,0 +1,133 @@
+package api
+
+import (
+	"blog_post/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary publish a blog
+// @Description Endpoint to publish a draft, scheduled or archived blog right away
+// @Tags Lifecycle
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Produce json
+// @Param id path int64 true "Blog ID"
+// @Param If-Match header string false "ETag the blog must still have"
+// @Success 200 {object} models.Blog "Successful Response"
+// @Header 200 {string} ETag "Blog version"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 409 {object} models.Problem "Transition not allowed"
+// @Failure 412 {object} models.Problem "Precondition Failed"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /blog-post/{id}/publish [post]
+func (h *Handler) PublishBlog(c *fiber.Ctx) error {
+	return h.transition(c, models.StatusPublished, nil)
+}
+
+// @Summary schedule a blog
+// @Description Endpoint to publish a draft automatically at a future time. Scheduled blogs can be rescheduled.
+// @Tags Lifecycle
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int64 true "Blog ID"
+// @Param If-Match header string false "ETag the blog must still have"
+// @Param request body models.ScheduleRequest true "When to publish"
+// @Success 200 {object} models.Blog "Successful Response"
+// @Header 200 {string} ETag "Blog version"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 409 {object} models.Problem "Transition not allowed"
+// @Failure 412 {object} models.Problem "Precondition Failed"
+// @Failure 413 {object} models.Problem "Request Entity Too Large"
+// @Failure 415 {object} models.Problem "Unsupported Media Type"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /blog-post/{id}/schedule [post]
+func (h *Handler) ScheduleBlog(c *fiber.Ctx) error {
+	reqBody, err := JSONBody[models.ScheduleRequest](c)
+	if err != nil {
+		return err
+	}
+	var publishAt *time.Time
+	if !reqBody.PublishAt.IsZero() {
+		publishAt = &reqBody.PublishAt
+	}
+	return h.transition(c, models.StatusScheduled, publishAt)
+}
+
+// @Summary unpublish a blog
+// @Description Endpoint to turn a scheduled, published or archived blog back into a draft
+// @Tags Lifecycle
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Produce json
+// @Param id path int64 true "Blog ID"
+// @Param If-Match header string false "ETag the blog must still have"
+// @Success 200 {object} models.Blog "Successful Response"
+// @Header 200 {string} ETag "Blog version"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 409 {object} models.Problem "Transition not allowed"
+// @Failure 412 {object} models.Problem "Precondition Failed"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /blog-post/{id}/unpublish [post]
+func (h *Handler) UnpublishBlog(c *fiber.Ctx) error {
+	return h.transition(c, models.StatusDraft, nil)
+}
+
+// @Summary archive a blog
+// @Description Endpoint to take a blog out of circulation without deleting it
+// @Tags Lifecycle
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Produce json
+// @Param id path int64 true "Blog ID"
+// @Param If-Match header string false "ETag the blog must still have"
+// @Success 200 {object} models.Blog "Successful Response"
+// @Header 200 {string} ETag "Blog version"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 409 {object} models.Problem "Transition not allowed"
+// @Failure 412 {object} models.Problem "Precondition Failed"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /blog-post/{id}/archive [post]
+func (h *Handler) ArchiveBlog(c *fiber.Ctx) error {
+	return h.transition(c, models.StatusArchived, nil)
+}
+
+// transition moves the blog named in the path to status to; the store
+// enforces which moves are allowed
+func (h *Handler) transition(c *fiber.Ctx, to models.Status, publishAt *time.Time) error {
+	blogID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	ifVersion, err := h.ifMatchVersion(c, blogID)
+	if err != nil {
+		return err
+	}
+	blog, err := h.Store.TransitionBlog(c.UserContext(), blogID, to, publishAt, ifVersion)
+	if err != nil {
+		return err
+	}
+	setETag(c, blog)
+	return c.Status(http.StatusOK).JSON(blog)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycle(t *testing.T) {
+	h := NewHandler(db.NewRepo())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/blog-post/:id/publish", h.PublishBlog)
+	app.Post("/blog-post/:id/schedule", h.ScheduleBlog)
+	app.Post("/blog-post/:id/unpublish", h.UnpublishBlog)
+	app.Post("/blog-post/:id/archive", h.ArchiveBlog)
+	call := func(target string, headers map[string]string, body string) (*http.Response, models.Blog, models.Problem) {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var blog models.Blog
+		var problem models.Problem
+		if resp.StatusCode == http.StatusOK {
+			json.NewDecoder(resp.Body).Decode(&blog)
+		} else {
+			json.NewDecoder(resp.Body).Decode(&problem)
+		}
+		return resp, blog, problem
+	}
+
+	t.Run("Schedule, publish, archive, unpublish", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		assert.Equal(t, models.StatusDraft, created.Status)
+		url := fmt.Sprintf("/blog-post/%d", created.ID)
+
+		at := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		resp, blog, _ := call(url+"/schedule", nil, `{"publish_at":"`+at+`"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, models.StatusScheduled, blog.Status)
+		assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+
+		resp, blog, _ = call(url+"/publish", map[string]string{"If-Match": `"2"`}, "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, models.StatusPublished, blog.Status)
+
+		resp, blog, _ = call(url+"/archive", nil, "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, models.StatusArchived, blog.Status)
+
+		resp, blog, _ = call(url+"/unpublish", nil, "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, models.StatusDraft, blog.Status)
+	})
+	t.Run("Transition not allowed", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		resp, _, problem := call(fmt.Sprintf("/blog-post/%d/unpublish", created.ID), nil, "")
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Equal(t, "cannot move a draft blog to draft", problem.Detail)
+	})
+	t.Run("Invalid schedule", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		url := fmt.Sprintf("/blog-post/%d/schedule", created.ID)
+		resp, _, problem := call(url, nil, `{}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, []models.FieldError{{Field: "publish_at", Message: "is required"}}, problem.Errors)
+
+		resp, _, problem = call(url, nil, `{"publish_at":"2001-01-01T00:00:00Z"}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, []models.FieldError{{Field: "publish_at", Message: "must be in the future"}}, problem.Errors)
+
+		resp, _, _ = call(url, nil, `{"publish_at":"tomorrow"}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+	t.Run("Stale If-Match", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		resp, _, _ := call(fmt.Sprintf("/blog-post/%d/publish", created.ID), map[string]string{"If-Match": `"5"`}, "")
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	})
+}
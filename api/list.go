@@ -0,0 +1,126 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// listOptions reads the paging, sorting, status, trash, tag and category
+// query parameters of a listing request. Blogs are newest first unless the client asks
+// otherwise, and only published ones are listed unless the client asks for
+// another status or the trash.
+func listOptions(c *fiber.Ctx) (db.ListOptions, error) {
+	opts := db.ListOptions{
+		After:    c.Query("after"),
+		Before:   c.Query("before"),
+		Sort:     db.SortField(c.Query("sort", string(db.SortCreatedAt))),
+		Desc:     true,
+		Tag:      c.Query("tag"),
+		Category: c.Query("category"),
+	}
+	var fields []models.FieldError
+	opts.Limit, fields = queryLimit(c, fields)
+	if raw := c.Query("deleted"); raw != "" {
+		deleted, err := strconv.ParseBool(raw)
+		if err != nil {
+			fields = append(fields, models.FieldError{Field: "deleted", Message: "must be true or false"})
+		}
+		opts.Deleted = deleted
+	}
+	defaultStatus := models.StatusPublished
+	if opts.Deleted {
+		defaultStatus = ""
+	}
+	opts.Status = queryStatus(c, defaultStatus)
+	switch strings.ToLower(c.Query("order", "desc")) {
+	case "desc":
+	case "asc":
+		opts.Desc = false
+	default:
+		fields = append(fields, models.FieldError{Field: "order", Message: "must be asc or desc"})
+	}
+	if len(fields) > 0 {
+		return db.ListOptions{}, &db.ValidationError{Fields: fields}
+	}
+	return opts, nil
+}
+
+// searchOptions reads the paging and status query parameters of a search
+// request; results are ordered by relevance so sorting parameters are
+// rejected. Like listings, searches only see published blogs by default.
+func searchOptions(c *fiber.Ctx) (db.SearchOptions, error) {
+	opts := db.SearchOptions{After: c.Query("after"), Status: queryStatus(c, models.StatusPublished)}
+	var fields []models.FieldError
+	opts.Limit, fields = queryLimit(c, fields)
+	for _, param := range []string{"sort", "order", "before", "deleted", "tag", "category"} {
+		if c.Query(param) != "" {
+			fields = append(fields, models.FieldError{Field: param, Message: "cannot be combined with q"})
+		}
+	}
+	if len(fields) > 0 {
+		return db.SearchOptions{}, &db.ValidationError{Fields: fields}
+	}
+	return opts, nil
+}
+
+// queryStatus reads the status filter; "all" lifts it. Unknown statuses
+// are passed through for the store to reject.
+func queryStatus(c *fiber.Ctx, fallback models.Status) models.Status {
+	switch raw := c.Query("status"); raw {
+	case "":
+		return fallback
+	case "all":
+		return ""
+	default:
+		return models.Status(raw)
+	}
+}
+
+// queryLimit parses the optional limit parameter, appending to fields when
+// it is out of range
+func queryLimit(c *fiber.Ctx, fields []models.FieldError) (int, []models.FieldError) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return 0, fields
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > db.MaxLimit {
+		fields = append(fields, models.FieldError{
+			Field:   "limit",
+			Message: "must be an integer between 1 and " + strconv.Itoa(db.MaxLimit),
+		})
+	}
+	return limit, fields
+}
+
+// setPageLinks advertises the neighbouring pages in an RFC 8288 Link header,
+// keeping every other query parameter of the current request
+func setPageLinks(c *fiber.Ctx, next, prev string) {
+	query, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	link := func(param, cursor, rel string) string {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Del("after")
+		q.Del("before")
+		q.Set(param, cursor)
+		return `<` + c.BaseURL() + c.Path() + `?` + q.Encode() + `>; rel="` + rel + `"`
+	}
+
+	var links []string
+	if next != "" {
+		links = append(links, link("after", next, "next"))
+	}
+	if prev != "" {
+		links = append(links, link("before", prev, "prev"))
+	}
+	if len(links) > 0 {
+		c.Set(fiber.HeaderLink, strings.Join(links, ", "))
+	}
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Media types accepted by PatchBlog
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+// @Summary partially update a blog
+// @Description Endpoint to update some fields of a blog by id. Send either an RFC 7386 JSON Merge Patch (`application/merge-patch+json`) or an RFC 6902 JSON Patch (`application/json-patch+json`) against the document `{"title", "description", "body", "body_format", "tags", "categories"}`; the patched document must still be a valid blog. Add a `slug` member to choose the slug; otherwise a changed title derives a new one.
+// @Tags Blog
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Produce json
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
+// @Param id path int64 true "Blog ID"
+// @Param If-Match header string false "ETag the blog must still have"
+// @Param request body object true "Merge patch object or JSON Patch operation array"
+// @Success 200 {object} models.Blog "Successful Response"
+// @Header 200 {string} ETag "Blog version"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 409 {object} models.Problem "JSON Patch test operation failed or slug already taken"
+// @Failure 412 {object} models.Problem "Precondition Failed"
+// @Failure 413 {object} models.Problem "Request Entity Too Large"
+// @Failure 415 {object} models.Problem "Unsupported Media Type"
+// @Failure 422 {object} models.Problem "Patch cannot be applied"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /blog-post/{id} [patch]
+func (h *Handler) PatchBlog(c *fiber.Ctx) error {
+	blogID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+	if mediaType != MergePatchContentType && mediaType != JSONPatchContentType {
+		c.Set("Accept-Patch", MergePatchContentType+", "+JSONPatchContentType)
+		return fiber.NewError(http.StatusUnsupportedMediaType,
+			"Content-Type must be "+MergePatchContentType+" or "+JSONPatchContentType)
+	}
+
+	ifVersion, err := h.ifMatchVersion(c, blogID)
+	if err != nil {
+		return err
+	}
+	current, err := h.Store.GetBlog(c.UserContext(), blogID)
+	if err != nil {
+		return err
+	}
+	if ifVersion != db.AnyVersion && ifVersion != current.Version {
+		return &db.VersionMismatchError{Expected: ifVersion, Actual: current.Version}
+	}
+	if err := checkDuplicateKeys(c.Body()); err != nil {
+		return err
+	}
+	reqBody, err := applyPatch(mediaType, current, c.Body())
+	if err != nil {
+		return err
+	}
+	if err := db.ValidateBlog(&reqBody); err != nil {
+		return err
+	}
+	// The patch was computed from current, so the write must not land on
+	// anything newer even when the client sent no If-Match
+	blog, err := h.Store.UpdateBlog(c.UserContext(), blogID, reqBody, current.Version)
+	if err != nil {
+		return err
+	}
+	setETag(c, blog)
+	return c.Status(http.StatusOK).JSON(blog)
+}
+
+// applyPatch applies a merge patch or JSON Patch to the editable fields of
+// blog and decodes the result, rejecting fields that do not exist
+func applyPatch(mediaType string, blog models.Blog, patch []byte) (models.BlogRequestBody, error) {
+	doc, err := json.Marshal(models.BlogRequestBody{
+		Title:       blog.Title,
+		Description: blog.Description,
+		Body:        blog.Body,
+		BodyFormat:  blog.BodyFormat,
+		Tags:        blog.Tags,
+		Categories:  blog.Categories,
+	})
+	if err != nil {
+		return models.BlogRequestBody{}, err
+	}
+
+	var patched []byte
+	if mediaType == MergePatchContentType {
+		var obj map[string]any
+		if err := json.Unmarshal(patch, &obj); err != nil || obj == nil {
+			return models.BlogRequestBody{}, fiber.NewError(http.StatusBadRequest, "merge patch must be a JSON object")
+		}
+		patched, err = jsonpatch.MergePatch(doc, patch)
+	} else {
+		var ops jsonpatch.Patch
+		ops, err = jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return models.BlogRequestBody{}, fiber.NewError(http.StatusBadRequest, "invalid JSON Patch: "+err.Error())
+		}
+		patched, err = ops.Apply(doc)
+	}
+	switch {
+	case errors.Is(err, jsonpatch.ErrTestFailed):
+		return models.BlogRequestBody{}, fiber.NewError(http.StatusConflict, err.Error())
+	case err != nil:
+		return models.BlogRequestBody{}, fiber.NewError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	var reqBody models.BlogRequestBody
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&reqBody); err != nil {
+		return models.BlogRequestBody{}, documentError(err, http.StatusUnprocessableEntity)
+	}
+	return reqBody, nil
+}
+
+// documentError explains why a well-formed JSON document, such as a patched
+// blog or a request body, does not decode into the expected type. Errors
+// that name no field are answered with status.
+func documentError(err error, status int) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return &db.ValidationError{Fields: []models.FieldError{
+			{Field: typeErr.Field, Message: "must be a " + typeErr.Type.String()},
+		}}
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return &db.ValidationError{Fields: []models.FieldError{
+			{Field: strings.Trim(field, `"`), Message: "is not a known field"},
+		}}
+	}
+	return fiber.NewError(status, err.Error())
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatchBlog(t *testing.T) {
+	h := NewHandler(db.NewRepo())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Patch("/blog-post/:id", h.PatchBlog)
+	patch := func(id int64, contentType, body string) (*http.Response, models.Blog, models.Problem) {
+		req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/blog-post/%d", id), strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var blog models.Blog
+		var problem models.Problem
+		if resp.StatusCode == http.StatusOK {
+			json.NewDecoder(resp.Body).Decode(&blog)
+		} else {
+			json.NewDecoder(resp.Body).Decode(&problem)
+		}
+		return resp, blog, problem
+	}
+
+	t.Run("Merge patch", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		resp, blog, _ := patch(created.ID, MergePatchContentType, `{"title":"Patched Title"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "Patched Title", blog.Title)
+		assert.Equal(t, created.Description, blog.Description)
+		assert.Equal(t, created.Body, blog.Body)
+		assert.Equal(t, "patched-title", blog.Slug)
+	})
+	t.Run("Slug", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		resp, blog, _ := patch(created.ID, MergePatchContentType, `{"slug":"chosen-slug"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "chosen-slug", blog.Slug)
+
+		resp, blog, _ = patch(created.ID, MergePatchContentType, `{"body":"Only the body"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "chosen-slug", blog.Slug, "the slug survives patches that keep the title")
+
+		resp, _, problem := patch(created.ID, JSONPatchContentType, `[{"op":"add","path":"/slug","value":"Bad Slug"}]`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "slug", problem.Errors[0].Field)
+	})
+	t.Run("Body format", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		resp, blog, _ := patch(created.ID, MergePatchContentType, `{"body":"<em>hi</em>","body_format":"html"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, models.FormatHTML, blog.BodyFormat)
+		assert.Equal(t, "<em>hi</em>", blog.BodyHTML)
+
+		resp, blog, _ = patch(created.ID, MergePatchContentType, `{"title":"Same Format"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, models.FormatHTML, blog.BodyFormat, "the format survives patches that leave it out")
+		assert.Equal(t, "<em>hi</em>", blog.BodyHTML)
+	})
+	t.Run("Tags", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		resp, blog, _ := patch(created.ID, JSONPatchContentType, `[{"op":"add","path":"/tags/-","value":"Go"}]`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, []string{"go"}, blog.Tags)
+
+		resp, blog, _ = patch(created.ID, MergePatchContentType, `{"title":"Keeps Tags"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, []string{"go"}, blog.Tags, "tags survive patches that leave them out")
+	})
+	t.Run("JSON patch", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		resp, blog, _ := patch(created.ID, JSONPatchContentType+"; charset=utf-8", `[
+			{"op":"test","path":"/title","value":"Test Title"},
+			{"op":"replace","path":"/body","value":"Patched Body"},
+			{"op":"copy","from":"/body","path":"/description"}
+		]`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, created.Title, blog.Title)
+		assert.Equal(t, "Patched Body", blog.Description)
+		assert.Equal(t, "Patched Body", blog.Body)
+	})
+	t.Run("Failed test operation", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		resp, _, _ := patch(created.ID, JSONPatchContentType, `[
+			{"op":"test","path":"/title","value":"Something Else"},
+			{"op":"replace","path":"/body","value":"Patched Body"}
+		]`)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+	t.Run("Patch removes a required field", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		resp, _, problem := patch(created.ID, MergePatchContentType, `{"description":null}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, []models.FieldError{{Field: "description", Message: "is required"}}, problem.Errors)
+	})
+	t.Run("Patch adds an unknown field", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		resp, _, problem := patch(created.ID, JSONPatchContentType, `[{"op":"add","path":"/author","value":"me"}]`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, []models.FieldError{{Field: "author", Message: "is not a known field"}}, problem.Errors)
+	})
+	t.Run("Patch sets a field to the wrong type", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		resp, _, problem := patch(created.ID, MergePatchContentType, `{"title":42}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, []models.FieldError{{Field: "title", Message: "must be a string"}}, problem.Errors)
+	})
+	t.Run("Patch path does not exist", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		resp, _, _ := patch(created.ID, JSONPatchContentType, `[{"op":"remove","path":"/missing"}]`)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	})
+	t.Run("Malformed patches", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		resp, _, _ := patch(created.ID, JSONPatchContentType, `{"title":"not an array"}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		resp, _, _ = patch(created.ID, MergePatchContentType, `["not an object"]`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		resp, _, problem := patch(created.ID, MergePatchContentType, `{"title":"One","title":"Two"}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, `duplicate key "title"`, problem.Detail)
+	})
+	t.Run("Unsupported media type", func(t *testing.T) {
+		created := CreateRandomBlog(t, h)
+		resp, _, _ := patch(created.ID, "application/json", `{"title":"Patched Title"}`)
+		assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Accept-Patch"), MergePatchContentType)
+	})
+	t.Run("Blog not found", func(t *testing.T) {
+		resp, _, _ := patch(1000, MergePatchContentType, `{"title":"Patched Title"}`)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary list revisions of a blog
+// @Description Endpoint to list every revision of a blog, oldest first. Each create, update, patch and restore records one. The revisions of blogs that are not published are only shown to callers who may update them.
+// @Tags Revisions
+// @Produce json
+// @Param id path int64 true "Blog ID"
+// @Success 200 {object} models.RevisionList "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /blog-post/{id}/revisions [get]
+func (h *Handler) ListRevisions(c *fiber.Ctx) error {
+	blogID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	if _, err := h.readableBlog(c, blogID); err != nil {
+		return err
+	}
+	revisions, err := h.Store.ListRevisions(c.UserContext(), blogID)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(models.RevisionList{Items: revisions, Total: len(revisions)})
+}
+
+// @Summary fetch a revision of a blog
+// @Description Endpoint to fetch the revision that produced a given version of a blog. Revisions of blogs that are not published are only shown to callers who may update them.
+// @Tags Revisions
+// @Produce json
+// @Param id path int64 true "Blog ID"
+// @Param version path int64 true "Revision (blog version)"
+// @Success 200 {object} models.Revision "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /blog-post/{id}/revisions/{version} [get]
+func (h *Handler) GetRevision(c *fiber.Ctx) error {
+	blogID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	version, err := strconv.ParseInt(c.Params("version"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	if _, err := h.readableBlog(c, blogID); err != nil {
+		return err
+	}
+	revision, err := h.Store.GetRevision(c.UserContext(), blogID, version)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(revision)
+}
+
+// @Summary diff two revisions of a blog
+// @Description Endpoint to compare two revisions line by line. Only changed fields are listed; `to` defaults to the latest revision. Blogs that are not published are only compared for callers who may update them.
+// @Tags Revisions
+// @Produce json
+// @Param id path int64 true "Blog ID"
+// @Param from query int64 true "Older revision"
+// @Param to query int64 false "Newer revision"
+// @Success 200 {object} models.RevisionDiff "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /blog-post/{id}/revisions/diff [get]
+func (h *Handler) DiffRevisions(c *fiber.Ctx) error {
+	blogID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	fromVersion, err := strconv.ParseInt(c.Query("from"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, "from must be a revision number")
+	}
+	blog, err := h.readableBlog(c, blogID)
+	if err != nil {
+		return err
+	}
+	from, err := h.Store.GetRevision(c.UserContext(), blogID, fromVersion)
+	if err != nil {
+		return err
+	}
+
+	var to models.Revision
+	if c.Query("to") == "" {
+		to, err = h.Store.GetRevision(c.UserContext(), blogID, blog.Version)
+		if err != nil {
+			return err
+		}
+	} else {
+		toVersion, err := strconv.ParseInt(c.Query("to"), 10, 64)
+		if err != nil {
+			return fiber.NewError(http.StatusBadRequest, "to must be a revision number")
+		}
+		if to, err = h.Store.GetRevision(c.UserContext(), blogID, toVersion); err != nil {
+			return err
+		}
+	}
+	return c.Status(http.StatusOK).JSON(diffRevisions(from, to))
+}
+
+// @Summary restore a revision of a blog
+// @Description Endpoint to bring back the content of an earlier revision. The restore is an ordinary update, so it bumps the version and records a new revision; history is never rewritten. Revisions do not hold tags or categories, so the blog keeps its current ones.
+// @Tags Revisions
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Produce json
+// @Param id path int64 true "Blog ID"
+// @Param version path int64 true "Revision to restore"
+// @Param If-Match header string false "ETag the blog must still have"
+// @Success 200 {object} models.Blog "Successful Response"
+// @Header 200 {string} ETag "Blog version"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 412 {object} models.Problem "Precondition Failed"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /blog-post/{id}/revisions/{version}/restore [post]
+func (h *Handler) RestoreRevision(c *fiber.Ctx) error {
+	blogID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	version, err := strconv.ParseInt(c.Params("version"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	ifVersion, err := h.ifMatchVersion(c, blogID)
+	if err != nil {
+		return err
+	}
+	revision, err := h.Store.GetRevision(c.UserContext(), blogID, version)
+	if err != nil {
+		return err
+	}
+	blog, err := h.restore(c, revision, ifVersion)
+	if err != nil {
+		return err
+	}
+	setETag(c, blog)
+	return c.Status(http.StatusOK).JSON(blog)
+}
+
+// restore writes revision over its blog, carrying over the blog's current
+// tags and categories. The write is pinned to the version those were read
+// at, so a concurrent change is never reverted to a stale snapshot: without
+// If-Match the restore is retried against the new version, with it the
+// mismatch is reported.
+func (h *Handler) restore(c *fiber.Ctx, revision models.Revision, ifVersion int64) (models.Blog, error) {
+	const attempts = 3
+	var err error
+	for i := 0; i < attempts; i++ {
+		var current, blog models.Blog
+		if current, err = h.Store.GetBlog(c.UserContext(), revision.BlogID); err != nil {
+			return models.Blog{}, err
+		}
+		expected := ifVersion
+		if expected == db.AnyVersion {
+			expected = current.Version
+		}
+		blog, err = h.Store.UpdateBlog(c.UserContext(), revision.BlogID, models.BlogRequestBody{
+			Title:       revision.Title,
+			Description: revision.Description,
+			Body:        revision.Body,
+			BodyFormat:  revision.BodyFormat,
+			Tags:        current.Tags,
+			Categories:  current.Categories,
+		}, expected)
+		if err == nil || ifVersion != db.AnyVersion || !errors.Is(err, db.ErrPreconditionFailed) {
+			return blog, err
+		}
+	}
+	return models.Blog{}, err
+}
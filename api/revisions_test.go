@@ -0,0 +1,149 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevisions(t *testing.T) {
+	store := db.NewRepo()
+	h := NewHandler(store)
+	app := newTestApp(t)
+	app.Get("/blog-post/:id/revisions", h.ListRevisions)
+	app.Get("/blog-post/:id/revisions/diff", h.DiffRevisions)
+	app.Get("/blog-post/:id/revisions/:version<min(1)>", h.GetRevision)
+	app.Post("/blog-post/:id/revisions/:version/restore", h.RestoreRevision)
+
+	created := CreateRandomBlog(t, h)
+	_, err := store.UpdateBlog(db.WithActor(context.Background(), "editor"), created.ID, models.BlogRequestBody{
+		Title:       created.Title,
+		Description: created.Description,
+		Body:        "Test Body\nSecond line",
+		Tags:        []string{"go"},
+	}, db.AnyVersion)
+	require.NoError(t, err)
+	base := fmt.Sprintf("/blog-post/%d/revisions", created.ID)
+
+	t.Run("List", func(t *testing.T) {
+		var list models.RevisionList
+		resp := app.call(http.MethodGet, base, "", &list)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 2, list.Total)
+		if assert.Len(t, list.Items, 2) {
+			assert.Equal(t, []int64{1, 2}, []int64{list.Items[0].Version, list.Items[1].Version})
+			assert.Equal(t, "editor", list.Items[1].Author)
+		}
+	})
+	t.Run("Get", func(t *testing.T) {
+		var rev models.Revision
+		resp := app.call(http.MethodGet, base+"/1", "", &rev)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "Test Body", rev.Body)
+
+		var problem models.Problem
+		resp = app.call(http.MethodGet, base+"/9", "", &problem)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, "revision not found", problem.Detail)
+	})
+	t.Run("Diff", func(t *testing.T) {
+		var diff models.RevisionDiff
+		resp := app.call(http.MethodGet, base+"/diff?from=1", "", &diff)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int64(2), diff.To, "to defaults to the latest revision")
+		assert.Equal(t, []models.FieldDiff{{Field: "body", Lines: []models.DiffLine{
+			{Op: DiffEqual, Text: "Test Body"},
+			{Op: DiffInsert, Text: "Second line"},
+		}}}, diff.Changes)
+
+		resp = app.call(http.MethodGet, base+"/diff?from=2&to=1", "", &diff)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, DiffDelete, diff.Changes[0].Lines[1].Op)
+
+		resp = app.call(http.MethodGet, base+"/diff", "", nil)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		resp = app.call(http.MethodGet, base+"/diff?from=1&to=9", "", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+	t.Run("Restore", func(t *testing.T) {
+		resp := app.send(http.MethodPost, base+"/1/restore", map[string]string{"If-Match": `"1"`}, "", nil)
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+		var blog models.Blog
+		resp = app.send(http.MethodPost, base+"/1/restore", map[string]string{"If-Match": `"2"`}, "", &blog)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"3"`, resp.Header.Get("ETag"))
+		assert.Equal(t, "Test Body", blog.Body)
+		assert.Equal(t, int64(3), blog.Version, "a restore is recorded as a new revision")
+		assert.Equal(t, []string{"go"}, blog.Tags, "revisions do not hold tags, so the current ones are kept")
+
+		revisions, err := store.ListRevisions(context.Background(), created.ID)
+		require.NoError(t, err)
+		assert.Len(t, revisions, 3)
+
+		resp = app.call(http.MethodPost, base+"/9/restore", "", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+	t.Run("Blog not found", func(t *testing.T) {
+		resp := app.call(http.MethodGet, "/blog-post/1000/revisions", "", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+// racingStore runs race once, right after the next GetBlog has read the
+// blog, to slip a concurrent write in between a read and the write using it
+type racingStore struct {
+	db.BlogStore
+	race func()
+}
+
+func (s *racingStore) GetBlog(ctx context.Context, id int64) (models.Blog, error) {
+	blog, err := s.BlogStore.GetBlog(ctx, id)
+	if race := s.race; race != nil {
+		s.race = nil
+		race()
+	}
+	return blog, err
+}
+
+func TestRestoreRevisionRace(t *testing.T) {
+	store := db.NewRepo()
+	h := NewHandler(store)
+	racing := &racingStore{BlogStore: store}
+	h.Store = racing
+	app := newTestApp(t)
+	app.Post("/blog-post/:id/revisions/:version/restore", h.RestoreRevision)
+
+	created := CreateRandomBlog(t, h)
+	retag := func(tags ...string) func() {
+		return func() {
+			_, err := store.UpdateBlog(context.Background(), created.ID, models.BlogRequestBody{
+				Title: created.Title, Description: created.Description, Body: "Test Body\nSecond line", Tags: tags,
+			}, db.AnyVersion)
+			require.NoError(t, err)
+		}
+	}
+	retag("go")()
+	restore := func(headers map[string]string) (*http.Response, models.Blog) {
+		var blog models.Blog
+		resp := app.send(http.MethodPost, fmt.Sprintf("/blog-post/%d/revisions/1/restore", created.ID), headers, "", &blog)
+		return resp, blog
+	}
+
+	racing.race = retag("rust")
+	resp, blog := restore(nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "Test Body", blog.Body)
+	assert.Equal(t, []string{"rust"}, blog.Tags, "the concurrent tag change survives the restore")
+	assert.Equal(t, int64(4), blog.Version)
+
+	racing.race = retag("zig")
+	resp, _ = restore(map[string]string{"If-Match": `"4"`})
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode, "with If-Match the mismatch is reported")
+}
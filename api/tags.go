@@ -0,0 +1,31 @@
+package api
+
+import (
+	"blog_post/models"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary tag cloud
+// @Description Endpoint to count the blogs carrying each tag, most used tag first. Only published blogs count unless `status` asks for another one (or `all`), which only callers who may update any blog can do; trashed blogs never do. List a tag's blogs with `GET /blog-posts?tag=<tag>`.
+// @Tags Blogs
+// @Produce json
+// @Param status query string false "Only count blogs in this status" Enums(draft, scheduled, published, archived, all) default(published)
+// @Success 200 {array} models.TagCount "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /tags [get]
+func (h *Handler) TagCloud(c *fiber.Ctx) error {
+	status := queryStatus(c, models.StatusPublished)
+	if err := h.allowStatus(c, status); err != nil {
+		return err
+	}
+	cloud, err := h.Store.TagCounts(c.UserContext(), status)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(cloud)
+}
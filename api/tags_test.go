@@ -0,0 +1,66 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTags(t *testing.T) {
+	h := NewHandler(db.NewRepo())
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/tags", h.TagCloud)
+	app.Get("/blog-posts", h.GetAllBlogs)
+	get := func(target string, out any) *http.Response {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+		require.NoError(t, err)
+		if out != nil {
+			json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp
+	}
+	ctx := context.Background()
+	for _, tags := range [][]string{{"Go", "testing"}, {"go"}, {"rust"}} {
+		blog, err := h.Store.CreateBlog(ctx, models.BlogRequestBody{Title: "Tagged", Description: "d", Body: "b", Tags: tags})
+		require.NoError(t, err)
+		_, err = h.Store.TransitionBlog(ctx, blog.ID, models.StatusPublished, nil, db.AnyVersion)
+		require.NoError(t, err)
+	}
+	_, err := h.Store.CreateBlog(ctx, models.BlogRequestBody{Title: "Draft", Description: "d", Body: "b", Tags: []string{"draft"}})
+	require.NoError(t, err)
+
+	t.Run("Tag cloud", func(t *testing.T) {
+		var cloud []models.TagCount
+		resp := get("/tags", &cloud)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, []models.TagCount{{Tag: "go", Count: 2}, {Tag: "rust", Count: 1}, {Tag: "testing", Count: 1}}, cloud,
+			"only published blogs count by default")
+
+		resp = get("/tags?status=all", &cloud)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Len(t, cloud, 4)
+		resp = get("/tags?status=bogus", nil)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+	t.Run("Filter blogs", func(t *testing.T) {
+		var page models.BlogList
+		resp := get("/blog-posts?tag=GO", &page)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 2, page.Total)
+
+		var problem models.Problem
+		resp = get("/blog-posts?tag=%21%21", &problem)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "tag", problem.Errors[0].Field)
+		resp = get("/blog-posts?q=tagged&tag=go", &problem)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "searches cannot be filtered by tag")
+	})
+}
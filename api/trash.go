@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary restore a deleted blog
+// @Description Endpoint to take a blog back out of the trash. List the trash with `GET /blog-posts?deleted=true`.
+// @Tags Trash
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Produce json
+// @Param id path int64 true "Blog ID"
+// @Success 200 {object} models.Blog "Successful Response"
+// @Header 200 {string} ETag "Blog version"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 409 {object} models.Problem "Blog is not in the trash"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /blog-post/{id}/restore [post]
+func (h *Handler) RestoreBlog(c *fiber.Ctx) error {
+	blogID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	blog, err := h.Store.RestoreBlog(c.UserContext(), blogID)
+	if err != nil {
+		return err
+	}
+	setETag(c, blog)
+	return c.Status(http.StatusOK).JSON(blog)
+}
+
+// @Summary permanently delete a blog
+// @Description Endpoint to remove a blog from the trash for good, together with its revisions. Only deleted blogs can be purged.
+// @Tags Trash
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Produce json
+// @Param id path int64 true "Blog ID"
+// @Success 200 {object} models.SuccessResponse "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 409 {object} models.Problem "Blog is not in the trash"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /blog-post/{id}/purge [delete]
+func (h *Handler) PurgeBlog(c *fiber.Ctx) error {
+	blogID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	if err := h.Store.PurgeBlog(c.UserContext(), blogID); err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(fiber.Map{"message": "Blog purged successfully"})
+}
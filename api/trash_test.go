@@ -0,0 +1,70 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrash(t *testing.T) {
+	h := NewHandler(db.NewRepo())
+	app := newTestApp(t)
+	app.Get("/blog-posts", h.GetAllBlogs)
+	app.Get("/blog-post/:id", h.GetBlog)
+	app.Delete("/blog-post/:id", h.DeleteBlog)
+	app.Post("/blog-post/:id/restore", h.RestoreBlog)
+	app.Delete("/blog-post/:id/purge", h.PurgeBlog)
+	list := func(query string) models.BlogList {
+		var page models.BlogList
+		resp := app.call(http.MethodGet, "/blog-posts"+query, "", &page)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return page
+	}
+
+	created := CreateRandomBlog(t, h)
+	url := fmt.Sprintf("/blog-post/%d", created.ID)
+	require.Equal(t, http.StatusOK, app.call(http.MethodDelete, url, "", nil).StatusCode)
+
+	t.Run("Hidden from GET and listing", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, app.call(http.MethodGet, url, "", nil).StatusCode)
+		assert.Zero(t, list("").Total)
+	})
+	t.Run("Listed in the trash", func(t *testing.T) {
+		trash := list("?deleted=true")
+		assert.Equal(t, 1, trash.Total)
+		if assert.Len(t, trash.Items, 1) {
+			assert.NotNil(t, trash.Items[0].DeletedAt)
+		}
+	})
+	t.Run("Invalid deleted parameter", func(t *testing.T) {
+		resp := app.call(http.MethodGet, "/blog-posts?deleted=maybe", "", nil)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		resp = app.call(http.MethodGet, "/blog-posts?q=test&deleted=true", "", nil)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+	t.Run("Restore", func(t *testing.T) {
+		var blog models.Blog
+		resp := app.call(http.MethodPost, url+"/restore", "", &blog)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Nil(t, blog.DeletedAt)
+		assert.Equal(t, http.StatusOK, app.call(http.MethodGet, url, "", nil).StatusCode)
+
+		var problem models.Problem
+		resp = app.call(http.MethodPost, url+"/restore", "", &problem)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Equal(t, "blog is not in the trash", problem.Detail)
+	})
+	t.Run("Purge", func(t *testing.T) {
+		assert.Equal(t, http.StatusConflict, app.call(http.MethodDelete, url+"/purge", "", nil).StatusCode,
+			"live blogs must be deleted first")
+		require.Equal(t, http.StatusOK, app.call(http.MethodDelete, url, "", nil).StatusCode)
+		assert.Equal(t, http.StatusOK, app.call(http.MethodDelete, url+"/purge", "", nil).StatusCode)
+		assert.Equal(t, http.StatusNotFound, app.call(http.MethodPost, url+"/restore", "", nil).StatusCode)
+		assert.Zero(t, list("?deleted=true").Total)
+	})
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// @Summary register a user
+// @Description Endpoint to create a user account. Usernames are case-insensitive and stored in lowercase; the password is only kept as a bcrypt hash. Blogs created with the account's credentials (HTTP Basic, or a JWT whose subject is the user ID) name it as their author.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param request body models.RegisterRequest true "Account details"
+// @Success 201 {object} models.User "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 409 {object} models.Problem "Username already taken"
+// @Failure 413 {object} models.Problem "Request Entity Too Large"
+// @Failure 415 {object} models.Problem "Unsupported Media Type"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /users [post]
+func (h *Handler) Register(c *fiber.Ctx) error {
+	reqBody, err := JSONBody[models.RegisterRequest](c)
+	if err != nil {
+		return err
+	}
+	user, err := h.Users.CreateUser(c.UserContext(), *reqBody)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusCreated).JSON(user)
+}
+
+// @Summary log in
+// @Description Endpoint to exchange a username and password for a bearer token, sent as "Authorization: Bearer <access_token>" on later requests until it expires. A failure does not say which of the two was wrong. Tokens are signed with JWT_SECRET; without it the endpoint answers 501 and HTTP Basic credentials are the way to authenticate.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param request body models.LoginRequest true "Credentials"
+// @Success 200 {object} models.LoginResponse "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Invalid username or password"
+// @Failure 413 {object} models.Problem "Request Entity Too Large"
+// @Failure 415 {object} models.Problem "Unsupported Media Type"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Failure 501 {object} models.Problem "No secret to sign tokens with"
+// @Router /login [post]
+func (h *Handler) Login(c *fiber.Ctx) error {
+	reqBody, err := JSONBody[models.LoginRequest](c)
+	if err != nil {
+		return err
+	}
+	if err := db.ValidateLogin(reqBody); err != nil {
+		return err
+	}
+	if h.Tokens == nil {
+		return fiber.NewError(http.StatusNotImplemented, "logging in is not configured")
+	}
+	user, err := h.Users.Authenticate(c.UserContext(), reqBody.Username, reqBody.Password)
+	if err != nil {
+		return err
+	}
+	token, err := h.Tokens.IssueToken(user)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(token)
+}
+
+// @Summary fetch a user
+// @Description Endpoint to fetch a user's public profile by id, e.g. the author_id of a blog.
+// @Tags Users
+// @Produce json
+// @Param id path int64 true "User ID"
+// @Success 200 {object} models.User "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /users/{id} [get]
+func (h *Handler) GetUser(c *fiber.Ctx) error {
+	userID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	user, err := h.Users.GetUser(c.UserContext(), userID)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(user)
+}
+
+// @Summary change a user's role
+// @Description Endpoint to grant a user another role. New accounts are readers; see the access policy for what each role may do.
+// @Tags Users
+// @Security BasicAuth
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int64 true "User ID"
+// @Param request body models.RoleRequest true "New role"
+// @Success 200 {object} models.User "Successful Response"
+// @Failure 400 {object} models.Problem "Bad Request"
+// @Failure 401 {object} models.Problem "Unauthorized"
+// @Failure 403 {object} models.Problem "Forbidden"
+// @Failure 404 {object} models.Problem "Not Found"
+// @Failure 413 {object} models.Problem "Request Entity Too Large"
+// @Failure 415 {object} models.Problem "Unsupported Media Type"
+// @Failure 500 {object} models.Problem "Internal Server Error"
+// @Router /users/{id}/role [put]
+func (h *Handler) SetUserRole(c *fiber.Ctx) error {
+	userID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	reqBody, err := JSONBody[models.RoleRequest](c)
+	if err != nil {
+		return err
+	}
+	user, err := h.Users.SetUserRole(c.UserContext(), userID, reqBody.Role)
+	if err != nil {
+		return err
+	}
+	return c.Status(http.StatusOK).JSON(user)
+}
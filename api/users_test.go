@@ -0,0 +1,112 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsers(t *testing.T) {
+	h := NewHandler(db.NewRepo())
+	h.Tokens = tokens{}
+	app := newTestApp(t)
+	app.Post("/users", h.Register)
+	app.Get("/users/:id", h.GetUser)
+	app.Post("/login", h.Login)
+	app.Put("/users/:id/role", h.SetUserRole)
+
+	var alice models.User
+	t.Run("Register", func(t *testing.T) {
+		resp := app.call(http.MethodPost, "/users", `{"username":"Alice","password":"correct horse","name":"Alice Liddell"}`, &alice)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Positive(t, alice.ID)
+		assert.Equal(t, "alice", alice.Username)
+		assert.Equal(t, "Alice Liddell", alice.Name)
+	})
+	t.Run("Password is never returned", func(t *testing.T) {
+		resp := app.call(http.MethodGet, fmt.Sprintf("/users/%d", alice.ID), "", nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var raw map[string]any
+		json.NewDecoder(resp.Body).Decode(&raw)
+		assert.Equal(t, "alice", raw["username"])
+		assert.NotContains(t, raw, "password")
+		assert.NotContains(t, raw, "password_hash")
+	})
+	t.Run("Username taken", func(t *testing.T) {
+		var problem models.Problem
+		resp := app.call(http.MethodPost, "/users", `{"username":"ALICE","password":"another password"}`, &problem)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Equal(t, ProblemTypeConflict, problem.Type)
+	})
+	t.Run("Invalid registration", func(t *testing.T) {
+		var problem models.Problem
+		resp := app.call(http.MethodPost, "/users", `{"username":"x","password":"short"}`, &problem)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Len(t, problem.Errors, 2)
+	})
+	t.Run("Login", func(t *testing.T) {
+		var login models.LoginResponse
+		resp := app.call(http.MethodPost, "/login", `{"username":"alice","password":"correct horse"}`, &login)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "token for "+alice.Username, login.AccessToken)
+		assert.Equal(t, alice.ID, login.User.ID)
+	})
+	t.Run("Login without tokens", func(t *testing.T) {
+		h.Tokens = nil
+		defer func() { h.Tokens = tokens{} }()
+		resp := app.call(http.MethodPost, "/login", `{"username":"alice","password":"correct horse"}`, nil)
+		assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+	})
+	t.Run("Login with wrong credentials", func(t *testing.T) {
+		for _, body := range []string{
+			`{"username":"alice","password":"wrong horse"}`,
+			`{"username":"nobody","password":"correct horse"}`,
+		} {
+			var problem models.Problem
+			resp := app.call(http.MethodPost, "/login", body, &problem)
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+			assert.Equal(t, ProblemTypeUnauthorized, problem.Type)
+			assert.Equal(t, "invalid username or password", problem.Detail)
+			assert.Equal(t, AuthChallenge, resp.Header.Get(fiber.HeaderWWWAuthenticate))
+		}
+	})
+	t.Run("Login without credentials", func(t *testing.T) {
+		var problem models.Problem
+		resp := app.call(http.MethodPost, "/login", `{"username":" ","password":""}`, &problem)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, []models.FieldError{
+			{Field: "username", Message: "is required"},
+			{Field: "password", Message: "is required"},
+		}, problem.Errors)
+	})
+	t.Run("Set role", func(t *testing.T) {
+		assert.Equal(t, models.RoleReader, alice.Role, "new accounts are readers")
+		var user models.User
+		resp := app.call(http.MethodPut, fmt.Sprintf("/users/%d/role", alice.ID), `{"role":"editor"}`, &user)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, models.RoleEditor, user.Role)
+
+		var problem models.Problem
+		resp = app.call(http.MethodPut, fmt.Sprintf("/users/%d/role", alice.ID), `{"role":"overlord"}`, &problem)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "role", problem.Errors[0].Field)
+	})
+	t.Run("Unknown user", func(t *testing.T) {
+		resp := app.call(http.MethodGet, "/users/9999", "", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+// tokens is a TokenIssuer that hands out made-up tokens
+type tokens struct{}
+
+func (tokens) IssueToken(user models.User) (models.LoginResponse, error) {
+	return models.LoginResponse{AccessToken: "token for " + user.Username, TokenType: "Bearer", User: user}, nil
+}
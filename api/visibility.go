@@ -0,0 +1,58 @@
+package api
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Visibility decides what a request may read beyond live, published blogs.
+// The authorizer enforcing the write policy implements it, so seeing
+// content follows the same rules as changing it.
+type Visibility interface {
+	// AllowTrash returns nil if the request may list blogs in the trash,
+	// and an unauthorized or forbidden error otherwise
+	AllowTrash(c *fiber.Ctx) error
+	// AllowUnpublished returns nil if the request may list blogs in any
+	// status, and an unauthorized or forbidden error otherwise
+	AllowUnpublished(c *fiber.Ctx) error
+	// CanRead reports whether the request may see blog, which is not
+	// published
+	CanRead(c *fiber.Ctx, blog models.Blog) bool
+}
+
+// allowTrash checks that the request may list the trash; a Handler without
+// Visibility shows everything
+func (h *Handler) allowTrash(c *fiber.Ctx) error {
+	if h.Visibility == nil {
+		return nil
+	}
+	return h.Visibility.AllowTrash(c)
+}
+
+// allowStatus checks that the request may list blogs in status, where ""
+// stands for every status
+func (h *Handler) allowStatus(c *fiber.Ctx, status models.Status) error {
+	if h.Visibility == nil || status == models.StatusPublished {
+		return nil
+	}
+	return h.Visibility.AllowUnpublished(c)
+}
+
+// readableBlog fetches the live blog with the given ID. Blogs the request
+// may not see are reported as not found, so their existence does not leak.
+func (h *Handler) readableBlog(c *fiber.Ctx, id int64) (models.Blog, error) {
+	blog, err := h.Store.GetBlog(c.UserContext(), id)
+	if err != nil {
+		return models.Blog{}, err
+	}
+	if !h.canRead(c, blog) {
+		return models.Blog{}, &db.NotFoundError{Resource: "blog"}
+	}
+	return blog, nil
+}
+
+func (h *Handler) canRead(c *fiber.Ctx, blog models.Blog) bool {
+	return h.Visibility == nil || blog.Status == models.StatusPublished || h.Visibility.CanRead(c, blog)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"blog_post/models"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	m "blog_post/middlewares"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/spf13/viper"
+)
+
+// jwtConfig builds the JWT middleware settings from the JWT_* keys, loading
+// the key set named by JWT_JWKS_URL or JWT_JWKS_FILE
+func jwtConfig(ctx context.Context) (m.JWTConfig, error) {
+	cfg := m.JWTConfig{
+		Issuer:   viper.GetString("JWT_ISSUER"),
+		Audience: viper.GetString("JWT_AUDIENCE"),
+		Leeway:   viper.GetDuration("JWT_LEEWAY"),
+		TokenTTL: viper.GetDuration("JWT_TOKEN_TTL"),
+	}
+	if secret := viper.GetString("JWT_SECRET"); secret != "" {
+		cfg.Secret = []byte(secret)
+	}
+	if algorithms := viper.GetString("JWT_ALGORITHMS"); algorithms != "" {
+		for _, alg := range strings.Split(algorithms, ",") {
+			cfg.Algorithms = append(cfg.Algorithms, strings.TrimSpace(alg))
+		}
+	}
+
+	url, file := viper.GetString("JWT_JWKS_URL"), viper.GetString("JWT_JWKS_FILE")
+	var err error
+	switch {
+	case url != "" && file != "":
+		return cfg, fmt.Errorf("set only one of JWT_JWKS_URL and JWT_JWKS_FILE")
+	case url != "":
+		cfg.Keys, err = m.NewURLKeySet(ctx, url, nil)
+	case file != "":
+		cfg.Keys, err = m.NewFileKeySet(ctx, file)
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.Secret == nil && cfg.Keys == nil {
+		log.Info("No JWT keys configured; bearer tokens will be rejected")
+	}
+	return cfg, nil
+}
+
+// loadPolicy reads the access policy overrides in RBAC_POLICY_FILE, falling
+// back to m.DefaultPolicy when it is unset
+func loadPolicy() (m.Policy, error) {
+	path := viper.GetString("RBAC_POLICY_FILE")
+	if path == "" {
+		return m.DefaultPolicy, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return m.ParsePolicy(data)
+}
+
+// runGrant implements the `grant <user-id> <role>` subcommand, which is how
+// the first admin is made since new accounts are readers
+func runGrant(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: blog_post grant <user-id> reader|author|editor|admin")
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid user id %q", args[0])
+	}
+	store, err := newStore()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if err := autoMigrate(ctx, store); err != nil {
+		return err
+	}
+	user, err := store.SetUserRole(ctx, id, models.Role(args[1]))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s is now %s\n", user.Username, user.Role)
+	return nil
+}
@@ -0,0 +1,32 @@
+package db
+
+import "context"
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx naming who performs the writes made with
+// it; stores record the actor on every revision
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor set by WithActor, or "" for anonymous writes
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+type authorKey struct{}
+
+// WithAuthor returns a copy of ctx whose blog creates are owned by the user
+// with the given ID
+func WithAuthor(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, authorKey{}, userID)
+}
+
+// AuthorFrom returns the user ID set by WithAuthor; ok is false for
+// anonymous writes
+func AuthorFrom(ctx context.Context) (userID int64, ok bool) {
+	userID, ok = ctx.Value(authorKey{}).(int64)
+	return userID, ok
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"blog_post/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// apiKeyPrefixLength is how much of a key, "bpk_" included, is kept to
+// tell keys apart
+const apiKeyPrefixLength = 10
+
+// apiKeyTag starts every API key so that leaked keys are easy to spot
+const apiKeyTag = "bpk_"
+
+// ValidateAPIKey normalizes req and checks it against the `validate` tags
+// of models.APIKeyRequest and that it expires after now, reporting every
+// bad field at once
+func ValidateAPIKey(req *models.APIKeyRequest, now time.Time) error {
+	var fields []models.FieldError
+	var validationErr *ValidationError
+	if errors.As(Validate(req), &validationErr) {
+		fields = validationErr.Fields
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		fields = append(fields, models.FieldError{Field: "expires_at", Message: "must be in the future"})
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// generateAPIKey returns a new random key and the hash stored in its place
+func generateAPIKey() (key, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	key = apiKeyTag + base64.RawURLEncoding.EncodeToString(secret)
+	return key, hashAPIKey(key), nil
+}
+
+// hashAPIKey returns the hash keys are stored and looked up by. Keys are
+// random and long, so unlike passwords they need no slow, salted hash.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// newAPIKey builds the key CreateAPIKey stores for req, returning it with
+// its secret and the hash of that secret
+func newAPIKey(ctx context.Context, req models.APIKeyRequest) (models.NewAPIKey, string, error) {
+	now := time.Now().UTC()
+	if err := ValidateAPIKey(&req, now); err != nil {
+		return models.NewAPIKey{}, "", err
+	}
+	key, hash, err := generateAPIKey()
+	if err != nil {
+		return models.NewAPIKey{}, "", err
+	}
+	if req.ExpiresAt != nil {
+		expires := req.ExpiresAt.UTC()
+		req.ExpiresAt = &expires
+	}
+	return models.NewAPIKey{
+		APIKey: models.APIKey{
+			Name:      req.Name,
+			Prefix:    key[:apiKeyPrefixLength],
+			Scope:     req.Scope,
+			CreatedBy: authorOf(ctx),
+			CreatedAt: now,
+			ExpiresAt: req.ExpiresAt,
+		},
+		Key: key,
+	}, hash, nil
+}
+
+// usable reports whether key may still authenticate at now
+func usable(key models.APIKey, now time.Time) bool {
+	return key.RevokedAt == nil && (key.ExpiresAt == nil || key.ExpiresAt.After(now))
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"blog_post/models"
+	"cmp"
+	"encoding/base64"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CommentListOptions selects one page of comments, oldest first
+type CommentListOptions struct {
+	Limit int    // page size; DefaultLimit when zero, capped at MaxLimit
+	After string // cursor returned as NextCursor by the previous page
+}
+
+// normalize fills in defaults and checks the options, returning the ID of
+// the comment the page follows, or 0 for the first page
+func (o *CommentListOptions) normalize() (int64, error) {
+	var fields []models.FieldError
+	if o.Limit < 0 {
+		fields = append(fields, models.FieldError{Field: "limit", Message: "must not be negative"})
+	}
+	if o.Limit == 0 {
+		o.Limit = DefaultLimit
+	}
+	if o.Limit > MaxLimit {
+		o.Limit = MaxLimit
+	}
+	var after int64
+	if o.After != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(o.After)
+		id, convErr := strconv.ParseInt(strings.TrimPrefix(string(raw), "comment:"), 10, 64)
+		if err != nil || convErr != nil || !strings.HasPrefix(string(raw), "comment:") || id < 1 {
+			fields = append(fields, models.FieldError{Field: "after", Message: "is not a valid comment cursor"})
+		}
+		after = id
+	}
+	if len(fields) > 0 {
+		return 0, &ValidationError{Fields: fields}
+	}
+	return after, nil
+}
+
+func commentCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("comment:" + strconv.FormatInt(id, 10)))
+}
+
+// ValidateComment normalizes req and checks it against the `validate` tags
+// of models.CommentRequest, reporting every bad field at once
+func ValidateComment(req *models.CommentRequest) error {
+	return Validate(req)
+}
+
+func validCommentStatus(s models.CommentStatus) bool {
+	switch s {
+	case models.CommentPending, models.CommentApproved, models.CommentSpam:
+		return true
+	}
+	return false
+}
+
+var (
+	commentStatusFieldError = models.FieldError{Field: "status", Message: "must be one of pending, approved, spam"}
+	errBadParent            = &ValidationError{Fields: []models.FieldError{
+		{Field: "parent_id", Message: "must name an approved comment on this blog"},
+	}}
+)
+
+// checkQueueStatus rejects a moderation queue filter that is neither empty
+// nor a comment status
+func checkQueueStatus(status models.CommentStatus) error {
+	if status != "" && !validCommentStatus(status) {
+		return &ValidationError{Fields: []models.FieldError{commentStatusFieldError}}
+	}
+	return nil
+}
+
+// sortComments orders comments oldest first; IDs only ever grow
+func sortComments(comments []models.Comment) {
+	slices.SortFunc(comments, func(a, b models.Comment) int { return cmp.Compare(a.ID, b.ID) })
+}
+
+// pageComments cuts a page of limit comments out of comments, sorted and
+// all following the cursor, returning the cursor of the next page if more
+// follow. Stores fetch limit+1 comments to tell.
+func pageComments(comments []models.Comment, limit int) ([]models.Comment, string) {
+	if comments == nil {
+		comments = []models.Comment{}
+	}
+	if len(comments) <= limit {
+		return comments, ""
+	}
+	comments = comments[:limit]
+	return comments, commentCursor(comments[limit-1].ID)
+}
+
+// nest hangs replies, sorted, under the threads they belong to. Replies
+// whose parent is in neither are left out, along with their own replies.
+func nest(threads, replies []models.Comment) []models.Comment {
+	children := make(map[int64][]models.Comment)
+	for _, reply := range replies {
+		children[*reply.ParentID] = append(children[*reply.ParentID], reply)
+	}
+	var attach func(comments []models.Comment)
+	attach = func(comments []models.Comment) {
+		for i := range comments {
+			comments[i].Replies = children[comments[i].ID]
+			attach(comments[i].Replies)
+		}
+	}
+	attach(threads)
+	return threads
+}
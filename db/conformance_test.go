@@ -0,0 +1,150 @@
+package db_test
+
+import (
+	"blog_post/db"
+	"blog_post/db/storetest"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepoConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) db.BlogStore {
+		return db.NewRepo()
+	})
+	storetest.RunUsers(t, func(t *testing.T) db.Store {
+		return db.NewRepo()
+	})
+	storetest.RunAPIKeys(t, func(t *testing.T) db.Store {
+		return db.NewRepo()
+	})
+	storetest.RunCategories(t, func(t *testing.T) db.Store {
+		return db.NewRepo()
+	})
+	storetest.RunComments(t, func(t *testing.T) db.Store {
+		return db.NewRepo()
+	})
+}
+
+func TestSQLiteConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) db.BlogStore {
+		return newSQLiteRepo(t)
+	})
+	storetest.RunUsers(t, func(t *testing.T) db.Store {
+		return newSQLiteRepo(t)
+	})
+	storetest.RunAPIKeys(t, func(t *testing.T) db.Store {
+		return newSQLiteRepo(t)
+	})
+	storetest.RunCategories(t, func(t *testing.T) db.Store {
+		return newSQLiteRepo(t)
+	})
+	storetest.RunComments(t, func(t *testing.T) db.Store {
+		return newSQLiteRepo(t)
+	})
+}
+
+// TestPostgresConformance runs against the database in POSTGRES_TEST_DSN
+// (see `make pg-up`, `make test-postgres`) or, without one, a throwaway
+// container; it is skipped when neither is available
+func TestPostgresConformance(t *testing.T) {
+	dsn := postgresDSN(t)
+	storetest.Run(t, func(t *testing.T) db.BlogStore {
+		return newPostgresRepo(t, dsn)
+	})
+	storetest.RunUsers(t, func(t *testing.T) db.Store {
+		return newPostgresRepo(t, dsn)
+	})
+	storetest.RunAPIKeys(t, func(t *testing.T) db.Store {
+		return newPostgresRepo(t, dsn)
+	})
+	storetest.RunCategories(t, func(t *testing.T) db.Store {
+		return newPostgresRepo(t, dsn)
+	})
+	storetest.RunComments(t, func(t *testing.T) db.Store {
+		return newPostgresRepo(t, dsn)
+	})
+}
+
+// newSQLiteRepo returns a migrated SQLiteRepo in a fresh database file
+func newSQLiteRepo(t *testing.T) *db.SQLiteRepo {
+	r, err := db.NewSQLiteRepo(filepath.Join(t.TempDir(), "blog.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { r.Close() })
+	migrateUp(t, r)
+	return r
+}
+
+// postgresDSN returns POSTGRES_TEST_DSN or, when it is unset, starts a
+// PostgreSQL container removed at the end of the test and returns its DSN.
+// The test is skipped if there is no docker to start one with.
+func postgresDSN(t *testing.T) string {
+	if dsn := os.Getenv("POSTGRES_TEST_DSN"); dsn != "" {
+		return dsn
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("POSTGRES_TEST_DSN not set and docker not found")
+	}
+	if err := exec.Command("docker", "info").Run(); err != nil {
+		t.Skip("POSTGRES_TEST_DSN not set and docker not running")
+	}
+	out, err := exec.Command("docker", "run", "--rm", "-d", "-p", "127.0.0.1::5432",
+		"-e", "POSTGRES_PASSWORD=postgres", "-e", "POSTGRES_DB=blog_post", postgresImage).Output()
+	require.NoError(t, err, "starting %s", postgresImage)
+	container := strings.TrimSpace(string(out))
+	t.Cleanup(func() { exec.Command("docker", "rm", "-f", container).Run() })
+
+	out, err = exec.Command("docker", "port", container, "5432/tcp").Output()
+	require.NoError(t, err)
+	addr, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	dsn := "postgres://postgres:postgres@" + addr + "/blog_post?sslmode=disable"
+
+	// The image only listens on TCP once its first-run setup is done
+	deadline := time.Now().Add(time.Minute)
+	for {
+		r, err := db.NewPostgresRepo(dsn)
+		if err == nil {
+			r.Close()
+			return dsn
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("PostgreSQL in %s did not come up: %v", container, err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// postgresImage is the image postgresDSN starts, the one `make pg-up` runs
+const postgresImage = "postgres:16-alpine"
+
+// newPostgresRepo returns a PostgresRepo on dsn with its schema rebuilt
+// from scratch
+func newPostgresRepo(t *testing.T, dsn string) *db.PostgresRepo {
+	r, err := db.NewPostgresRepo(dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { r.Close() })
+	migrator, err := r.Migrator()
+	require.NoError(t, err)
+	for {
+		rolledBack, err := migrator.Down(context.Background())
+		require.NoError(t, err)
+		if !rolledBack {
+			break
+		}
+	}
+	migrateUp(t, r)
+	return r
+}
+
+func migrateUp(t *testing.T, r interface{ Migrator() (*db.Migrator, error) }) {
+	migrator, err := r.Migrator()
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"blog_post/models"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sentinel errors returned by every BlogStore implementation. Callers should
+// match them with errors.Is; the concrete errors carry more detail.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrValidation = errors.New("validation failed")
+	ErrConflict   = errors.New("conflict")
+	// ErrPreconditionFailed means a conditional write expected a version
+	// the resource no longer has
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrUnauthorized means the caller's credentials were missing or wrong
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden means the caller is known but may not do what it asked
+	ErrForbidden = errors.New("forbidden")
+)
+
+// NotFoundError reports a missing resource; it matches ErrNotFound
+type NotFoundError struct {
+	Resource string
+}
+
+func (e *NotFoundError) Error() string {
+	return e.Resource + " not found"
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// ValidationError reports an invalid request field by field; it matches
+// ErrValidation
+type ValidationError struct {
+	Fields []models.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Field + " " + f.Message
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// ConflictError reports a write that clashes with existing data; it matches
+// ErrConflict
+type ConflictError struct {
+	Reason string
+}
+
+func (e *ConflictError) Error() string {
+	return e.Reason
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// VersionMismatchError reports a conditional write against a stale version;
+// it matches ErrPreconditionFailed
+type VersionMismatchError struct {
+	Expected, Actual int64
+}
+
+func (e *VersionMismatchError) Error() string {
+	return fmt.Sprintf("version mismatch: expected %d, current is %d", e.Expected, e.Actual)
+}
+
+func (e *VersionMismatchError) Is(target error) bool {
+	return target == ErrPreconditionFailed
+}
+
+// PreconditionError reports a request precondition that cannot be expressed
+// as a single expected version, such as an If-Match list; it matches
+// ErrPreconditionFailed
+type PreconditionError struct {
+	Reason string
+}
+
+func (e *PreconditionError) Error() string {
+	return e.Reason
+}
+
+func (e *PreconditionError) Is(target error) bool {
+	return target == ErrPreconditionFailed
+}
+
+// UnauthorizedError reports credentials that do not identify a user; it
+// matches ErrUnauthorized
+type UnauthorizedError struct {
+	Reason string
+}
+
+func (e *UnauthorizedError) Error() string {
+	return e.Reason
+}
+
+func (e *UnauthorizedError) Is(target error) bool {
+	return target == ErrUnauthorized
+}
+
+// ForbiddenError reports a caller lacking the permission for an action; it
+// matches ErrForbidden
+type ForbiddenError struct {
+	Reason string
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.Reason
+}
+
+func (e *ForbiddenError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+var (
+	errBlogNotFound     = &NotFoundError{Resource: "blog"}
+	errRevisionNotFound = &NotFoundError{Resource: "revision"}
+	errBlogNotTrashed   = &ConflictError{Reason: "blog is not in the trash"}
+	errUserNotFound     = &NotFoundError{Resource: "user"}
+	errUsernameTaken    = &ConflictError{Reason: "username already taken"}
+	errBadCredentials   = &UnauthorizedError{Reason: "invalid username or password"}
+	errAPIKeyNotFound   = &NotFoundError{Resource: "API key"}
+	errBadAPIKey        = &UnauthorizedError{Reason: "invalid, expired or revoked API key"}
+	errCategoryNotFound = &NotFoundError{Resource: "category"}
+	errCategoryTaken    = &ConflictError{Reason: "category slug already taken"}
+	errCommentNotFound  = &NotFoundError{Resource: "comment"}
+	errCommentsClosed   = &ConflictError{Reason: "comments are only open on published blogs"}
+	errAnonymousComment = &ForbiddenError{Reason: "only users may comment"}
+)
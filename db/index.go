@@ -0,0 +1,110 @@
+package db
+
+import (
+	"blog_post/models"
+	"sort"
+	"strings"
+)
+
+// searchIndex is the inverted index Repo keeps up to date on every write so
+// that searches only look at posts that can possibly match. It is guarded by
+// the owning Repo's mutex.
+type searchIndex struct {
+	docs     map[int64]*document
+	postings map[string]map[int64]struct{} // term → IDs of blogs containing it
+	terms    []string                      // sorted vocabulary for prefix lookups
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		docs:     make(map[int64]*document),
+		postings: make(map[string]map[int64]struct{}),
+	}
+}
+
+// put indexes blog, replacing any previous version of it
+func (ix *searchIndex) put(blog models.Blog) {
+	ix.remove(blog.ID)
+	doc := analyze(blog)
+	ix.docs[blog.ID] = doc
+	for term := range doc.positions {
+		ids, ok := ix.postings[term]
+		if !ok {
+			ids = make(map[int64]struct{})
+			ix.postings[term] = ids
+			i := sort.SearchStrings(ix.terms, term)
+			ix.terms = append(ix.terms, "")
+			copy(ix.terms[i+1:], ix.terms[i:])
+			ix.terms[i] = term
+		}
+		ids[blog.ID] = struct{}{}
+	}
+}
+
+// remove drops a blog from the index
+func (ix *searchIndex) remove(id int64) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	delete(ix.docs, id)
+	for term := range doc.positions {
+		ids := ix.postings[term]
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(ix.postings, term)
+			i := sort.SearchStrings(ix.terms, term)
+			ix.terms = append(ix.terms[:i], ix.terms[i+1:]...)
+		}
+	}
+}
+
+// candidates returns the documents containing every term of every clause.
+// Phrases still need their positions checked by document.match.
+func (ix *searchIndex) candidates(clauses []clause) []*document {
+	var result map[int64]struct{}
+	for _, c := range clauses {
+		var ids map[int64]struct{}
+		switch c.kind {
+		case clausePrefix:
+			ids = make(map[int64]struct{})
+			for i := sort.SearchStrings(ix.terms, c.terms[0]); i < len(ix.terms) && strings.HasPrefix(ix.terms[i], c.terms[0]); i++ {
+				for id := range ix.postings[ix.terms[i]] {
+					ids[id] = struct{}{}
+				}
+			}
+		default:
+			for _, term := range c.terms {
+				ids = intersect(ids, ix.postings[term])
+			}
+		}
+		result = intersect(result, ids)
+		if len(result) == 0 {
+			return nil
+		}
+	}
+
+	docs := make([]*document, 0, len(result))
+	for id := range result {
+		docs = append(docs, ix.docs[id])
+	}
+	return docs
+}
+
+// intersect returns the IDs present in both sets; a nil a means "everything"
+func intersect(a, b map[int64]struct{}) map[int64]struct{} {
+	if a == nil {
+		out := make(map[int64]struct{}, len(b))
+		for id := range b {
+			out[id] = struct{}{}
+		}
+		return out
+	}
+	out := make(map[int64]struct{})
+	for id := range a {
+		if _, ok := b[id]; ok {
+			out[id] = struct{}{}
+		}
+	}
+	return out
+}
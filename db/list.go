@@ -0,0 +1,225 @@
+package db
+
+import (
+	"blog_post/models"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Page size bounds for GetAllBlogs
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// SortField names a field blogs can be ordered by
+type SortField string
+
+const (
+	SortCreatedAt SortField = "created_at"
+	SortUpdatedAt SortField = "updated_at"
+	SortTitle     SortField = "title"
+)
+
+// ListOptions selects one page of blogs. Ordering is always stable: ties on
+// the sort field are broken by ID in the same direction.
+type ListOptions struct {
+	Limit    int       // page size; DefaultLimit when zero, capped at MaxLimit
+	After    string    // cursor; return the page following this position
+	Before   string    // cursor; return the page preceding this position
+	Sort     SortField // SortCreatedAt when empty
+	Desc     bool
+	Deleted  bool          // list the trash instead of live blogs
+	Status   models.Status // only list blogs in this status; any when empty
+	Tag      string        // only list blogs with this tag; normalized like stored tags
+	Category string        // only list blogs filed under the category with this slug
+}
+
+// normalize fills in defaults and checks the options, returning the decoded
+// cursor (if any) and whether it points backwards
+func (o *ListOptions) normalize() (*cursor, bool, error) {
+	var fields []models.FieldError
+	if o.Sort == "" {
+		o.Sort = SortCreatedAt
+	}
+	switch o.Sort {
+	case SortCreatedAt, SortUpdatedAt, SortTitle:
+	default:
+		fields = append(fields, models.FieldError{Field: "sort", Message: "must be one of created_at, updated_at, title"})
+	}
+	if o.Limit < 0 {
+		fields = append(fields, models.FieldError{Field: "limit", Message: "must not be negative"})
+	}
+	if o.Status != "" && !validStatus(o.Status) {
+		fields = append(fields, statusFieldError)
+	}
+	if o.Tag != "" {
+		if o.Tag = NormalizeTag(o.Tag); o.Tag == "" {
+			fields = append(fields, models.FieldError{Field: "tag", Message: "must contain letters or digits"})
+		}
+	}
+	o.Category = normalizeCategory(o.Category)
+	if o.Limit == 0 {
+		o.Limit = DefaultLimit
+	}
+	if o.Limit > MaxLimit {
+		o.Limit = MaxLimit
+	}
+	if o.After != "" && o.Before != "" {
+		fields = append(fields, models.FieldError{Field: "before", Message: "cannot be combined with after"})
+	}
+	if len(fields) > 0 {
+		return nil, false, &ValidationError{Fields: fields}
+	}
+
+	raw, field, backward := o.After, "after", false
+	if o.Before != "" {
+		raw, field, backward = o.Before, "before", true
+	}
+	if raw == "" {
+		return nil, false, nil
+	}
+	c, err := decodeCursor(raw)
+	if err != nil || c.Sort != o.Sort || c.Desc != o.Desc {
+		return nil, false, &ValidationError{Fields: []models.FieldError{
+			{Field: field, Message: "is not a valid cursor for this sort order"},
+		}}
+	}
+	return c, backward, nil
+}
+
+// matches reports whether blog belongs in the listing described by o
+func (o ListOptions) matches(blog models.Blog) bool {
+	return (blog.DeletedAt != nil) == o.Deleted &&
+		(o.Status == "" || blog.Status == o.Status) &&
+		(o.Tag == "" || slices.Contains(blog.Tags, NormalizeTag(o.Tag))) &&
+		(o.Category == "" || slices.Contains(blog.Categories, normalizeCategory(o.Category)))
+}
+
+// cursor is the decoded form of the opaque page tokens handed to clients
+type cursor struct {
+	Sort SortField `json:"s"`
+	Desc bool      `json:"d,omitempty"`
+	ID   int64     `json:"i"`
+	Text string    `json:"t,omitempty"`
+	Time time.Time `json:"ts,omitempty"`
+}
+
+// cursorFor captures the position of blog in the order described by opts
+func cursorFor(blog models.Blog, opts ListOptions) *cursor {
+	c := &cursor{Sort: opts.Sort, Desc: opts.Desc, ID: blog.ID}
+	switch opts.Sort {
+	case SortTitle:
+		c.Text = blog.Title
+	case SortUpdatedAt:
+		c.Time = blog.UpdatedAt.UTC()
+	default:
+		c.Time = blog.CreatedAt.UTC()
+	}
+	return c
+}
+
+func (c *cursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// value is the sort key the cursor points at
+func (c *cursor) value() any {
+	if c.Sort == SortTitle {
+		return c.Text
+	}
+	return c.Time
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// compareBlogs orders a before b (-1), after (1) or equal (0) under the
+// ascending sort order of field
+func compareBlogs(a, b models.Blog, field SortField) int {
+	var c int
+	switch field {
+	case SortTitle:
+		c = strings.Compare(a.Title, b.Title)
+	case SortUpdatedAt:
+		c = a.UpdatedAt.Compare(b.UpdatedAt)
+	default:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if c != 0 {
+		return c
+	}
+	switch {
+	case a.ID < b.ID:
+		return -1
+	case a.ID > b.ID:
+		return 1
+	}
+	return 0
+}
+
+// blog rebuilds the sort key a cursor points at
+func (c *cursor) blog() models.Blog {
+	return models.Blog{ID: c.ID, Title: c.Text, CreatedAt: c.Time, UpdatedAt: c.Time}
+}
+
+// paginate sorts blogs in memory and cuts out the page selected by opts
+func paginate(blogs []models.Blog, opts ListOptions) (models.BlogList, error) {
+	pos, backward, err := opts.normalize()
+	if err != nil {
+		return models.BlogList{}, err
+	}
+	cmp := func(a, b models.Blog) int {
+		c := compareBlogs(a, b, opts.Sort)
+		if opts.Desc {
+			return -c
+		}
+		return c
+	}
+	sort.Slice(blogs, func(i, j int) bool { return cmp(blogs[i], blogs[j]) < 0 })
+
+	start, end := 0, len(blogs)
+	if pos != nil {
+		key := pos.blog()
+		if backward {
+			// everything ordered strictly before the cursor
+			end = sort.Search(len(blogs), func(i int) bool { return cmp(blogs[i], key) >= 0 })
+		} else {
+			// everything ordered strictly after the cursor
+			start = sort.Search(len(blogs), func(i int) bool { return cmp(blogs[i], key) > 0 })
+		}
+	}
+	if backward {
+		start = max(end-opts.Limit, 0)
+	} else {
+		end = min(start+opts.Limit, len(blogs))
+	}
+
+	page := models.BlogList{
+		Items: append(make([]models.Blog, 0, end-start), blogs[start:end]...),
+		Total: len(blogs),
+	}
+	if len(page.Items) > 0 {
+		if end < len(blogs) {
+			page.NextCursor = cursorFor(page.Items[len(page.Items)-1], opts).encode()
+		}
+		if start > 0 {
+			page.PrevCursor = cursorFor(page.Items[0], opts).encode()
+		}
+	}
+	return page, nil
+}
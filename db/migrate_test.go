@@ -0,0 +1,76 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("Embedded migrations", func(t *testing.T) {
+		for _, d := range []dialect{sqliteDialect, postgresDialect} {
+			migrations, err := loadMigrations(migrationFS, "migrations/"+d.name)
+			require.NoError(t, err)
+			require.NotEmpty(t, migrations)
+			for i, m := range migrations {
+				assert.Equal(t, i+1, m.Version, "%s migrations must be numbered without gaps", d.name)
+			}
+		}
+	})
+	t.Run("Missing down file", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"m/0001_init.up.sql": {Data: []byte("SELECT 1")},
+		}
+		_, err := loadMigrations(fsys, "m")
+		assert.ErrorContains(t, err, "needs both up and down files")
+	})
+	t.Run("Invalid file name", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"m/init.up.sql": {Data: []byte("SELECT 1")},
+		}
+		_, err := loadMigrations(fsys, "m")
+		assert.ErrorContains(t, err, "invalid migration file name")
+	})
+}
+
+func TestMigrator(t *testing.T) {
+	r, err := NewSQLiteRepo(filepath.Join(t.TempDir(), "blog.db"))
+	require.NoError(t, err)
+	defer r.Close()
+	migrator, err := r.Migrator()
+	require.NoError(t, err)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, s := range statuses {
+		assert.Nil(t, s.AppliedAt)
+	}
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, len(statuses), applied)
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, applied, "Up must be idempotent")
+	createRandomBlog(t, r)
+
+	statuses, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, s := range statuses {
+		assert.NotNil(t, s.AppliedAt)
+	}
+
+	for range statuses {
+		rolledBack, err := migrator.Down(ctx)
+		require.NoError(t, err)
+		assert.True(t, rolledBack)
+	}
+	rolledBack, err := migrator.Down(ctx)
+	require.NoError(t, err)
+	assert.False(t, rolledBack)
+	_, err = r.GetBlog(ctx, 1)
+	assert.Error(t, err, "blogs table should be dropped")
+}
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS blogs (
+	id          BIGSERIAL   PRIMARY KEY,
+	title       TEXT        NOT NULL,
+	description TEXT        NOT NULL,
+	body        TEXT        NOT NULL,
+	created_at  TIMESTAMPTZ NOT NULL,
+	updated_at  TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE blogs ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS blog_revisions (
+	blog_id     BIGINT      NOT NULL REFERENCES blogs (id) ON DELETE CASCADE,
+	version     BIGINT      NOT NULL,
+	title       TEXT        NOT NULL,
+	description TEXT        NOT NULL,
+	body        TEXT        NOT NULL,
+	author      TEXT        NOT NULL DEFAULT '',
+	created_at  TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (blog_id, version)
+);
+
+-- Blogs written before revisions existed start their history at their
+-- current content.
+INSERT INTO blog_revisions (blog_id, version, title, description, body, created_at)
+SELECT id, version, title, description, body, updated_at FROM blogs;
@@ -0,0 +1,2 @@
+ALTER TABLE blogs ADD COLUMN deleted_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS blogs_deleted_at_idx ON blogs (deleted_at);
@@ -0,0 +1,9 @@
+ALTER TABLE blogs ADD COLUMN status TEXT NOT NULL DEFAULT 'draft';
+ALTER TABLE blogs ADD COLUMN publish_at TIMESTAMPTZ;
+ALTER TABLE blog_revisions ADD COLUMN status TEXT NOT NULL DEFAULT 'draft';
+
+-- Everything written before the lifecycle existed was public.
+UPDATE blogs SET status = 'published', publish_at = created_at;
+UPDATE blog_revisions SET status = 'published';
+
+CREATE INDEX IF NOT EXISTS blogs_status_publish_at_idx ON blogs (status, publish_at);
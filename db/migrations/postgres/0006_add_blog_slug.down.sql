@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS slug_history;
+DROP INDEX IF EXISTS blogs_slug_idx;
+ALTER TABLE blogs DROP COLUMN slug;
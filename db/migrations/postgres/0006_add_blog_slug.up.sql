@@ -0,0 +1,13 @@
+ALTER TABLE blogs ADD COLUMN slug TEXT;
+
+-- Slugs are derived in Go, so existing posts get a placeholder until their
+-- title next changes.
+UPDATE blogs SET slug = 'post-' || id;
+ALTER TABLE blogs ALTER COLUMN slug SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS blogs_slug_idx ON blogs (slug);
+
+CREATE TABLE IF NOT EXISTS slug_history (
+	slug    TEXT   PRIMARY KEY,
+	blog_id BIGINT NOT NULL REFERENCES blogs (id) ON DELETE CASCADE
+);
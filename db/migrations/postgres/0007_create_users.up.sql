@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS users (
+	id            BIGSERIAL   PRIMARY KEY,
+	username      TEXT        NOT NULL UNIQUE,
+	name          TEXT        NOT NULL DEFAULT '',
+	password_hash TEXT        NOT NULL,
+	created_at    TIMESTAMPTZ NOT NULL
+);
+
+-- Posts written before accounts existed have no author.
+ALTER TABLE blogs ADD COLUMN author_id BIGINT REFERENCES users (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS blogs_author_id_idx ON blogs (author_id);
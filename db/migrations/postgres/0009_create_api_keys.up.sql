@@ -0,0 +1,14 @@
+-- Only a SHA-256 hash of each key is stored; prefix is its first characters,
+-- kept so that keys can be told apart.
+CREATE TABLE IF NOT EXISTS api_keys (
+	id           BIGSERIAL PRIMARY KEY,
+	name         TEXT NOT NULL,
+	prefix       TEXT NOT NULL,
+	key_hash     TEXT NOT NULL UNIQUE,
+	scope        TEXT NOT NULL,
+	created_by   BIGINT REFERENCES users (id) ON DELETE SET NULL,
+	created_at   TIMESTAMPTZ NOT NULL,
+	expires_at   TIMESTAMPTZ,
+	last_used_at TIMESTAMPTZ,
+	revoked_at   TIMESTAMPTZ
+);
@@ -0,0 +1,4 @@
+ALTER TABLE blog_revisions DROP COLUMN body_html;
+ALTER TABLE blog_revisions DROP COLUMN body_format;
+ALTER TABLE blogs DROP COLUMN body_html;
+ALTER TABLE blogs DROP COLUMN body_format;
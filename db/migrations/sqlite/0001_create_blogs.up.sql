@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS blogs (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	title       TEXT     NOT NULL,
+	description TEXT     NOT NULL,
+	body        TEXT     NOT NULL,
+	created_at  DATETIME NOT NULL,
+	updated_at  DATETIME NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE blogs ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
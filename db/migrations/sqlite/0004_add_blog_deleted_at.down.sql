@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS blogs_deleted_at_idx;
+ALTER TABLE blogs DROP COLUMN deleted_at;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS blogs_status_publish_at_idx;
+ALTER TABLE blog_revisions DROP COLUMN status;
+ALTER TABLE blogs DROP COLUMN publish_at;
+ALTER TABLE blogs DROP COLUMN status;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS blogs_author_id_idx;
+ALTER TABLE blogs DROP COLUMN author_id;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,3 @@
+-- Accounts made before roles existed could already write, so they become
+-- authors; new accounts are created as readers.
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'author';
@@ -0,0 +1,14 @@
+-- Only a SHA-256 hash of each key is stored; prefix is its first characters,
+-- kept so that keys can be told apart.
+CREATE TABLE IF NOT EXISTS api_keys (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	name         TEXT NOT NULL,
+	prefix       TEXT NOT NULL,
+	key_hash     TEXT NOT NULL UNIQUE,
+	scope        TEXT NOT NULL,
+	created_by   INTEGER REFERENCES users (id) ON DELETE SET NULL,
+	created_at   DATETIME NOT NULL,
+	expires_at   DATETIME,
+	last_used_at DATETIME,
+	revoked_at   DATETIME
+);
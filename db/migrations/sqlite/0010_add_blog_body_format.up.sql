@@ -0,0 +1,6 @@
+-- Existing posts are taken as Markdown; their body_html is rendered on read
+-- until they are next written.
+ALTER TABLE blogs ADD COLUMN body_format TEXT NOT NULL DEFAULT 'markdown';
+ALTER TABLE blogs ADD COLUMN body_html TEXT NOT NULL DEFAULT '';
+ALTER TABLE blog_revisions ADD COLUMN body_format TEXT NOT NULL DEFAULT 'markdown';
+ALTER TABLE blog_revisions ADD COLUMN body_html TEXT NOT NULL DEFAULT '';
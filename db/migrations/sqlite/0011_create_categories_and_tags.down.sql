@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS blog_tags;
+DROP TABLE IF EXISTS blog_categories;
+DROP TABLE IF EXISTS categories;
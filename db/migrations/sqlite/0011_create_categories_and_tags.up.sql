@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS categories (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	slug        TEXT NOT NULL UNIQUE,
+	name        TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	created_at  DATETIME NOT NULL
+);
+
+-- Blogs are filed by category ID, so renaming a category needs no change
+-- here and deleting one takes it off its blogs.
+CREATE TABLE IF NOT EXISTS blog_categories (
+	blog_id     INTEGER NOT NULL REFERENCES blogs (id) ON DELETE CASCADE,
+	category_id INTEGER NOT NULL REFERENCES categories (id) ON DELETE CASCADE,
+	PRIMARY KEY (blog_id, category_id)
+);
+CREATE INDEX IF NOT EXISTS blog_categories_category_idx ON blog_categories (category_id);
+
+CREATE TABLE IF NOT EXISTS blog_tags (
+	blog_id INTEGER NOT NULL REFERENCES blogs (id) ON DELETE CASCADE,
+	tag     TEXT NOT NULL,
+	PRIMARY KEY (blog_id, tag)
+);
+CREATE INDEX IF NOT EXISTS blog_tags_tag_idx ON blog_tags (tag);
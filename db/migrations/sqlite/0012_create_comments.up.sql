@@ -0,0 +1,16 @@
+-- Replies go with the comment they reply to and comments with their blog
+-- when it is purged; moving a blog to the trash only hides them.
+CREATE TABLE IF NOT EXISTS comments (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	blog_id    INTEGER  NOT NULL REFERENCES blogs (id) ON DELETE CASCADE,
+	parent_id  INTEGER  REFERENCES comments (id) ON DELETE CASCADE,
+	author_id  INTEGER  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	author     TEXT     NOT NULL DEFAULT '',
+	body       TEXT     NOT NULL,
+	status     TEXT     NOT NULL DEFAULT 'pending',
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS comments_blog_id_idx ON comments (blog_id, status);
+CREATE INDEX IF NOT EXISTS comments_parent_id_idx ON comments (parent_id);
+CREATE INDEX IF NOT EXISTS comments_status_idx ON comments (status);
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" database/sql driver
+)
+
+var postgresDialect = dialect{
+	name:            "postgres",
+	driver:          "pgx",
+	numbered:        true,
+	binaryCollation: ` COLLATE "C"`,
+	likeOp:          "ILIKE",
+	forUpdate:       ` FOR UPDATE`,
+	uniqueViolation: postgresUniqueViolation,
+}
+
+func postgresUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// PostgresRepo is a BlogStore persisted in PostgreSQL
+type PostgresRepo struct {
+	*sqlRepo
+}
+
+var _ Store = (*PostgresRepo)(nil)
+
+// NewPostgresRepo connects to the PostgreSQL database described by dsn.
+// Run its Migrator before use to create the schema.
+func NewPostgresRepo(dsn string) (*PostgresRepo, error) {
+	conn, err := sql.Open(postgresDialect.driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &PostgresRepo{&sqlRepo{db: conn, dialect: postgresDialect}}, nil
+}
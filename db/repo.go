@@ -2,103 +2,957 @@ package db
 
 import (
 	"blog_post/models"
+	"blog_post/render"
+	"cmp"
+	"context"
 	"errors"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2/log"
 )
 
+// Repo is the in-memory implementation of BlogStore
 type Repo struct {
-	data map[int64]models.Blog
-	mu   sync.RWMutex
+	data        map[int64]models.Blog
+	revisions   map[int64][]models.Revision // per blog, oldest first
+	slugs       map[string]int64            // current slug to blog ID
+	slugHistory map[string]int64            // retired slug to blog ID
+	index       *searchIndex
+	lastID      int64 // highest ID ever allocated; never reused after deletes
+	categories  map[int64]models.Category
+	categoryIDs map[string]int64 // category slug to category ID
+	lastCatID   int64
+	comments    map[int64]models.Comment
+	lastComment int64 // highest comment ID ever allocated
+	users       map[int64]userRecord
+	usernames   map[string]int64 // normalized username to user ID
+	lastUserID  int64
+	apiKeys     map[int64]models.APIKey
+	apiKeyIDs   map[string]int64 // key hash to API key ID
+	lastKeyID   int64
+	mu          sync.RWMutex
 }
 
-var DB = Repo{
-	data: make(map[int64]models.Blog),
+// userRecord is a user as the Repo keeps it, password hash included
+type userRecord struct {
+	models.User
+	hash []byte
 }
 
-// GetAllBlogs lists all blogs
-func (r *Repo) GetAllBlogs() ([]models.Blog, error) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+var _ Store = (*Repo)(nil)
+
+// NewRepo returns an empty in-memory Repo
+func NewRepo() *Repo {
+	return &Repo{
+		data:        make(map[int64]models.Blog),
+		revisions:   make(map[int64][]models.Revision),
+		slugs:       make(map[string]int64),
+		slugHistory: make(map[string]int64),
+		index:       newSearchIndex(),
+		categories:  make(map[int64]models.Category),
+		categoryIDs: make(map[string]int64),
+		comments:    make(map[int64]models.Comment),
+		users:       make(map[int64]userRecord),
+		usernames:   make(map[string]int64),
+		apiKeys:     make(map[int64]models.APIKey),
+		apiKeyIDs:   make(map[string]int64),
+	}
+}
 
+// GetAllBlogs lists one page of blogs; an empty store yields an empty page
+func (r *Repo) GetAllBlogs(ctx context.Context, opts ListOptions) (models.BlogList, error) {
+	r.mu.RLock()
 	blogs := make([]models.Blog, 0, len(r.data))
 	for _, blog := range r.data {
-		blogs = append(blogs, blog)
+		if opts.matches(blog) {
+			blogs = append(blogs, blog)
+		}
 	}
-	if len(blogs) == 0 {
-		return nil, errors.New("no blogs in DB")
+	r.mu.RUnlock()
+
+	return paginate(blogs, opts)
+}
+
+// SearchBlogs runs a full-text query over title, description and body,
+// consulting the inverted index rather than scanning every post. Only live
+// blogs are indexed.
+func (r *Repo) SearchBlogs(ctx context.Context, query string, opts SearchOptions) (models.SearchResults, error) {
+	clauses, offset, err := opts.normalize(query)
+	if err != nil {
+		return models.SearchResults{}, err
 	}
-	return blogs, nil
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return rank(r.index.candidates(clauses), clauses, opts, offset), nil
 }
 
 // GetBlog fetches a blog by id
-func (r *Repo) GetBlog(id int64) (models.Blog, error) {
+func (r *Repo) GetBlog(ctx context.Context, id int64) (models.Blog, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.live(id)
+}
+
+// GetBlogBySlug fetches a blog by its current slug or by one it had before;
+// compare the returned blog's Slug to tell the two apart
+func (r *Repo) GetBlogBySlug(ctx context.Context, slug string) (models.Blog, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, exists := r.slugs[slug]
+	if !exists {
+		if id, exists = r.slugHistory[slug]; !exists {
+			return models.Blog{}, errBlogNotFound
+		}
+	}
+	return r.live(id)
+}
+
+// DeleteBlog moves a blog to the trash as its next version
+func (r *Repo) DeleteBlog(ctx context.Context, id int64, ifVersion int64) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	blog, exists := r.data[id]
-	if !exists {
-		return models.Blog{}, errors.New("blog not found")
+	blog, err := r.live(id)
+	if err != nil {
+		return err
 	}
-	return blog, nil
+	if err := checkVersion(blog, ifVersion); err != nil {
+		return err
+	}
+	now := time.Now()
+	blog.DeletedAt = &now
+	r.write(ctx, blog)
+	r.index.remove(id)
+	return nil
 }
 
-// DeleteBlog deletes a blog
-func (r *Repo) DeleteBlog(id int64) error {
+// RestoreBlog takes a blog back out of the trash as its next version
+func (r *Repo) RestoreBlog(ctx context.Context, id int64) (models.Blog, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	_, exists := r.data[id]
-	if !exists {
-		return errors.New("blog not found")
+	blog, err := r.trashed(id)
+	if err != nil {
+		return models.Blog{}, err
 	}
-	delete(r.data, id)
+	blog.DeletedAt = nil
+	return r.write(ctx, blog), nil
+}
+
+// PurgeBlog permanently removes a trashed blog, its revisions and comments
+func (r *Repo) PurgeBlog(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.trashed(id); err != nil {
+		return err
+	}
+	r.purge(id)
 	return nil
 }
 
+// PurgeDeleted permanently removes every blog trashed before cutoff
+func (r *Repo) PurgeDeleted(ctx context.Context, cutoff time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int
+	for id, blog := range r.data {
+		if blog.DeletedAt != nil && blog.DeletedAt.Before(cutoff) {
+			r.purge(id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// purge drops every trace of a blog; callers hold the lock
+func (r *Repo) purge(id int64) {
+	delete(r.slugs, r.data[id].Slug)
+	for slug, owner := range r.slugHistory {
+		if owner == id {
+			delete(r.slugHistory, slug)
+		}
+	}
+	delete(r.data, id)
+	delete(r.revisions, id)
+	for commentID, comment := range r.comments {
+		if comment.BlogID == id {
+			delete(r.comments, commentID)
+		}
+	}
+}
+
+// assignSlug resolves the slug of a write to old (nil for a new blog); a
+// slug counts as taken while any other blog, trashed or not, holds it.
+// Callers hold the lock.
+func (r *Repo) assignSlug(req models.BlogRequestBody, old *models.Blog) (string, error) {
+	var self int64
+	if old != nil {
+		self = old.ID
+	}
+	taken := func(slug string) (bool, error) {
+		owner, exists := r.slugs[slug]
+		return exists && owner != self, nil
+	}
+	slug, fixed := slugFor(req, old)
+	if !fixed {
+		return uniqueSlug(slug, taken)
+	}
+	if used, _ := taken(slug); used {
+		return "", errSlugTaken
+	}
+	return slug, nil
+}
+
+// moveSlug points slug at a blog, retiring its previous slug into the
+// history so old links keep resolving; callers hold the lock
+func (r *Repo) moveSlug(id int64, from, to string) {
+	if from == to {
+		return
+	}
+	if from != "" {
+		delete(r.slugs, from)
+		r.slugHistory[from] = id
+	}
+	delete(r.slugHistory, to)
+	r.slugs[to] = id
+}
+
+// live returns a blog that is not in the trash; callers hold the lock
+func (r *Repo) live(id int64) (models.Blog, error) {
+	blog, exists := r.data[id]
+	if !exists || blog.DeletedAt != nil {
+		return models.Blog{}, errBlogNotFound
+	}
+	return blog, nil
+}
+
+// trashed returns a blog that is in the trash; callers hold the lock
+func (r *Repo) trashed(id int64) (models.Blog, error) {
+	blog, exists := r.data[id]
+	if !exists {
+		return models.Blog{}, errBlogNotFound
+	}
+	if blog.DeletedAt == nil {
+		return models.Blog{}, errBlogNotTrashed
+	}
+	return blog, nil
+}
+
 // CreateBlog creates a new blog
-func (r *Repo) CreateBlog(blog models.BlogRequestBody) (models.Blog, error) {
+func (r *Repo) CreateBlog(ctx context.Context, blog models.BlogRequestBody) (models.Blog, error) {
+	if err := ValidateBlog(&blog); err != nil {
+		log.Errorf("CreateBlog failed: %v", err)
+		return models.Blog{}, err
+	}
+	// Render before taking the lock so that readers do not wait on it
+	bodyHTML, err := renderBody(blog)
+	if err != nil {
+		return models.Blog{}, err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if blog.Title == "" || blog.Body == "" || blog.Description == "" {
-		log.Error("CreateBlog failed: Missing field")
-		return models.Blog{}, errors.New("missing required field")
+	if err := r.checkCategories(blog.Categories); err != nil {
+		return models.Blog{}, err
 	}
-	id := len(r.data)
-	newID := int64(id + 1)
+	slug, err := r.assignSlug(blog, nil)
+	if err != nil {
+		return models.Blog{}, err
+	}
+	r.lastID++
+	newID := r.lastID
+	now := time.Now()
+	r.moveSlug(newID, "", slug)
 	r.data[newID] = models.Blog{
 		ID:          newID,
+		Slug:        slug,
 		Title:       blog.Title,
 		Description: blog.Description,
 		Body:        blog.Body,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		BodyFormat:  blog.BodyFormat,
+		BodyHTML:    bodyHTML,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Version:     1,
+		Status:      models.StatusDraft,
+		AuthorID:    authorOf(ctx),
+		Tags:        blog.Tags,
+		Categories:  blog.Categories,
 	}
+	r.index.put(r.data[newID])
+	r.record(ctx, r.data[newID])
 	return r.data[newID], nil
 }
 
 // UpdateBlog updates an existing blog
-func (r *Repo) UpdateBlog(id int64, blog models.BlogRequestBody) (models.Blog, error) {
+func (r *Repo) UpdateBlog(ctx context.Context, id int64, blog models.BlogRequestBody, ifVersion int64) (models.Blog, error) {
+	if err := ValidateBlog(&blog); err != nil {
+		log.Errorf("UpdateBlog failed: %v", err)
+		return models.Blog{}, err
+	}
+	bodyHTML, err := renderBody(blog)
+	if err != nil {
+		return models.Blog{}, err
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if blog.Title == "" || blog.Body == "" || blog.Description == "" {
-		log.Error("UpdateBlog failed: Missing field")
-		return models.Blog{}, errors.New("missing required field")
+	oldBlog, err := r.live(id)
+	if err != nil {
+		return models.Blog{}, err
+	}
+	if err := checkVersion(oldBlog, ifVersion); err != nil {
+		return models.Blog{}, err
 	}
-	oldBlog, exists := r.data[id]
+	if err := r.checkCategories(blog.Categories); err != nil {
+		return models.Blog{}, err
+	}
+	slug, err := r.assignSlug(blog, &oldBlog)
+	if err != nil {
+		return models.Blog{}, err
+	}
+	r.moveSlug(id, oldBlog.Slug, slug)
+	newBlog := oldBlog
+	newBlog.Slug = slug
+	newBlog.Title = blog.Title
+	newBlog.Description = blog.Description
+	newBlog.Body = blog.Body
+	newBlog.BodyFormat = blog.BodyFormat
+	newBlog.BodyHTML = bodyHTML
+	newBlog.Tags = blog.Tags
+	newBlog.Categories = blog.Categories
+	return r.write(ctx, newBlog), nil
+}
+
+// TransitionBlog moves a blog to another lifecycle status
+func (r *Repo) TransitionBlog(ctx context.Context, id int64, to models.Status, publishAt *time.Time, ifVersion int64) (models.Blog, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	blog, err := r.live(id)
+	if err != nil {
+		return models.Blog{}, err
+	}
+	publishAt, err = publishAtFor(to, publishAt, blog.PublishAt, time.Now())
+	if err != nil {
+		return models.Blog{}, err
+	}
+	if err := checkVersion(blog, ifVersion); err != nil {
+		return models.Blog{}, err
+	}
+	if !CanTransition(blog.Status, to) {
+		return models.Blog{}, transitionError(blog.Status, to)
+	}
+	blog.Status = to
+	blog.PublishAt = publishAt
+	return r.write(ctx, blog), nil
+}
+
+// PublishDue publishes every scheduled blog whose publish_at is not after now
+func (r *Repo) PublishDue(ctx context.Context, now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int
+	for _, blog := range r.data {
+		if blog.DeletedAt == nil && blog.Status == models.StatusScheduled && !blog.PublishAt.After(now) {
+			blog.Status = models.StatusPublished
+			r.write(ctx, blog)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// write stores an edited blog as its next version; callers hold the lock
+func (r *Repo) write(ctx context.Context, blog models.Blog) models.Blog {
+	blog.UpdatedAt = time.Now()
+	blog.Version++
+	r.data[blog.ID] = blog
+	r.index.put(blog)
+	r.record(ctx, blog)
+	return blog
+}
+
+// ListRevisions returns every revision of a blog, oldest first
+func (r *Repo) ListRevisions(ctx context.Context, id int64) ([]models.Revision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, err := r.live(id); err != nil {
+		return nil, err
+	}
+	return slices.Clone(r.revisions[id]), nil
+}
+
+// GetRevision fetches the revision that produced the given blog version
+func (r *Repo) GetRevision(ctx context.Context, id int64, version int64) (models.Revision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, err := r.live(id); err != nil {
+		return models.Revision{}, err
+	}
+	for _, rev := range r.revisions[id] {
+		if rev.Version == version {
+			return rev, nil
+		}
+	}
+	return models.Revision{}, errRevisionNotFound
+}
+
+// BlogAuthor returns the author_id of a blog, trashed or not
+func (r *Repo) BlogAuthor(ctx context.Context, id int64) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	blog, exists := r.data[id]
 	if !exists {
-		return models.Blog{}, errors.New("blog not found")
+		return 0, errBlogNotFound
+	}
+	if blog.AuthorID == nil {
+		return 0, nil
 	}
-	newBlog := models.Blog{
-		ID:          id,
+	return *blog.AuthorID, nil
+}
+
+// TagCounts counts the live blogs carrying each tag
+func (r *Repo) TagCounts(ctx context.Context, status models.Status) ([]models.TagCount, error) {
+	if status != "" && !validStatus(status) {
+		return nil, &ValidationError{Fields: []models.FieldError{statusFieldError}}
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, blog := range r.data {
+		if blog.DeletedAt == nil && (status == "" || blog.Status == status) {
+			for _, tag := range blog.Tags {
+				counts[tag]++
+			}
+		}
+	}
+	return sortTagCounts(counts), nil
+}
+
+// checkCategories rejects category slugs that name no category; callers
+// hold the lock
+func (r *Repo) checkCategories(slugs []string) error {
+	var missing []string
+	for _, slug := range slugs {
+		if _, exists := r.categoryIDs[slug]; !exists {
+			missing = append(missing, slug)
+		}
+	}
+	if len(missing) > 0 {
+		return unknownCategories(missing)
+	}
+	return nil
+}
+
+// record appends the revision produced by a write; callers hold the lock
+func (r *Repo) record(ctx context.Context, blog models.Blog) {
+	r.revisions[blog.ID] = append(r.revisions[blog.ID], revisionOf(ctx, blog))
+}
+
+// revisionOf snapshots blog as written by the actor in ctx
+func revisionOf(ctx context.Context, blog models.Blog) models.Revision {
+	return models.Revision{
+		BlogID:      blog.ID,
+		Version:     blog.Version,
 		Title:       blog.Title,
 		Description: blog.Description,
 		Body:        blog.Body,
-		UpdatedAt:   time.Now(),
-		CreatedAt:   oldBlog.CreatedAt,
+		BodyFormat:  blog.BodyFormat,
+		BodyHTML:    blog.BodyHTML,
+		Status:      blog.Status,
+		Author:      ActorFrom(ctx),
+		CreatedAt:   blog.UpdatedAt,
+	}
+}
+
+// authorOf returns the author recorded on blogs created with ctx
+func authorOf(ctx context.Context) *int64 {
+	if id, ok := AuthorFrom(ctx); ok {
+		return &id
+	}
+	return nil
+}
+
+// CreateCategory adds a category
+func (r *Repo) CreateCategory(ctx context.Context, req models.CategoryRequest) (models.Category, error) {
+	if err := ValidateCategory(&req); err != nil {
+		return models.Category{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	slug := categorySlugFor(req, nil)
+	if _, taken := r.categoryIDs[slug]; taken {
+		return models.Category{}, errCategoryTaken
+	}
+	r.lastCatID++
+	category := models.Category{
+		ID:          r.lastCatID,
+		Slug:        slug,
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedAt:   time.Now(),
+	}
+	r.categories[category.ID] = category
+	r.categoryIDs[slug] = category.ID
+	return category, nil
+}
+
+// ListCategories returns every category ordered by slug
+func (r *Repo) ListCategories(ctx context.Context) ([]models.Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	categories := make([]models.Category, 0, len(r.categories))
+	for _, category := range r.categories {
+		categories = append(categories, category)
+	}
+	slices.SortFunc(categories, func(a, b models.Category) int { return cmp.Compare(a.Slug, b.Slug) })
+	return categories, nil
+}
+
+// GetCategory fetches a category by id
+func (r *Repo) GetCategory(ctx context.Context, id int64) (models.Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	category, exists := r.categories[id]
+	if !exists {
+		return models.Category{}, errCategoryNotFound
 	}
-	r.data[id] = newBlog
-	return newBlog, nil
+	return category, nil
+}
+
+// UpdateCategory replaces a category, refiling its blogs under its new slug
+func (r *Repo) UpdateCategory(ctx context.Context, id int64, req models.CategoryRequest) (models.Category, error) {
+	if err := ValidateCategory(&req); err != nil {
+		return models.Category{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	category, exists := r.categories[id]
+	if !exists {
+		return models.Category{}, errCategoryNotFound
+	}
+	slug := categorySlugFor(req, &category)
+	if owner, taken := r.categoryIDs[slug]; taken && owner != id {
+		return models.Category{}, errCategoryTaken
+	}
+	if slug != category.Slug {
+		delete(r.categoryIDs, category.Slug)
+		r.categoryIDs[slug] = id
+		r.refile(ctx, category.Slug, slug)
+	}
+	category.Slug = slug
+	category.Name = req.Name
+	category.Description = req.Description
+	r.categories[id] = category
+	return category, nil
+}
+
+// DeleteCategory removes a category and takes it off its blogs
+func (r *Repo) DeleteCategory(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	category, exists := r.categories[id]
+	if !exists {
+		return errCategoryNotFound
+	}
+	delete(r.categories, id)
+	delete(r.categoryIDs, category.Slug)
+	r.refile(ctx, category.Slug, "")
+	return nil
+}
+
+// refile moves every blog filed under category slug from to slug to, or
+// takes them off it when to is empty. Each refiled blog, trashed or not,
+// is written as its next version so that its ETag changes with its
+// categories. Callers hold the lock.
+func (r *Repo) refile(ctx context.Context, from, to string) {
+	for _, blog := range r.data {
+		i := slices.Index(blog.Categories, from)
+		if i < 0 {
+			continue
+		}
+		categories := slices.Delete(slices.Clone(blog.Categories), i, i+1)
+		if to != "" {
+			categories = append(categories, to)
+			slices.Sort(categories)
+		}
+		blog.Categories = categories
+		r.write(ctx, blog)
+		if blog.DeletedAt != nil {
+			r.index.remove(blog.ID)
+		}
+	}
+}
+
+// CreateComment adds a pending comment by the user in ctx
+func (r *Repo) CreateComment(ctx context.Context, blogID int64, req models.CommentRequest) (models.Comment, error) {
+	author, ok := AuthorFrom(ctx)
+	if !ok {
+		return models.Comment{}, errAnonymousComment
+	}
+	if err := ValidateComment(&req); err != nil {
+		return models.Comment{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	blog, err := r.live(blogID)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	if blog.Status != models.StatusPublished {
+		return models.Comment{}, errCommentsClosed
+	}
+	var parentID *int64
+	if req.ParentID != nil {
+		parent, exists := r.comments[*req.ParentID]
+		if !exists || parent.BlogID != blogID || parent.Status != models.CommentApproved {
+			return models.Comment{}, errBadParent
+		}
+		parentID = &parent.ID
+	}
+	r.lastComment++
+	now := time.Now()
+	comment := models.Comment{
+		ID:        r.lastComment,
+		BlogID:    blogID,
+		ParentID:  parentID,
+		AuthorID:  author,
+		Author:    ActorFrom(ctx),
+		Body:      req.Body,
+		Status:    models.CommentPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.comments[comment.ID] = comment
+	return comment, nil
+}
+
+// ListComments returns a page of a blog's approved threads
+func (r *Repo) ListComments(ctx context.Context, blogID int64, opts CommentListOptions) (models.CommentList, error) {
+	after, err := opts.normalize()
+	if err != nil {
+		return models.CommentList{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, err := r.live(blogID); err != nil {
+		return models.CommentList{}, err
+	}
+	var threads, replies []models.Comment
+	total := 0
+	for _, comment := range r.comments {
+		switch {
+		case comment.BlogID != blogID || comment.Status != models.CommentApproved:
+		case comment.ParentID != nil:
+			replies = append(replies, comment)
+		default:
+			total++
+			if comment.ID > after {
+				threads = append(threads, comment)
+			}
+		}
+	}
+	sortComments(threads)
+	sortComments(replies)
+	page, next := pageComments(threads, opts.Limit)
+	return models.CommentList{Items: nest(page, replies), Total: total, NextCursor: next}, nil
+}
+
+// UpdateComment replaces the body of a comment, sending it back to the
+// moderation queue
+func (r *Repo) UpdateComment(ctx context.Context, blogID, id int64, req models.CommentEditRequest) (models.Comment, error) {
+	if err := Validate(&req); err != nil {
+		return models.Comment{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	comment, err := r.commentOn(blogID, id)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	comment.Body = req.Body
+	comment.Status = models.CommentPending
+	comment.UpdatedAt = time.Now()
+	r.comments[id] = comment
+	return comment, nil
+}
+
+// DeleteComment removes a comment and its replies
+func (r *Repo) DeleteComment(ctx context.Context, blogID, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.commentOn(blogID, id); err != nil {
+		return err
+	}
+	r.dropComment(id)
+	return nil
+}
+
+// dropComment deletes a comment and, recursively, its replies; callers
+// hold the lock
+func (r *Repo) dropComment(id int64) {
+	delete(r.comments, id)
+	for replyID, reply := range r.comments {
+		if reply.ParentID != nil && *reply.ParentID == id {
+			r.dropComment(replyID)
+		}
+	}
+}
+
+// commentOn returns a comment on a live blog; callers hold the lock
+func (r *Repo) commentOn(blogID, id int64) (models.Comment, error) {
+	if _, err := r.live(blogID); err != nil {
+		return models.Comment{}, err
+	}
+	comment, exists := r.comments[id]
+	if !exists || comment.BlogID != blogID {
+		return models.Comment{}, errCommentNotFound
+	}
+	return comment, nil
+}
+
+// ModerationQueue returns a page of the comments on live blogs
+func (r *Repo) ModerationQueue(ctx context.Context, status models.CommentStatus, opts CommentListOptions) (models.CommentList, error) {
+	if err := checkQueueStatus(status); err != nil {
+		return models.CommentList{}, err
+	}
+	after, err := opts.normalize()
+	if err != nil {
+		return models.CommentList{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var queued []models.Comment
+	total := 0
+	for _, comment := range r.comments {
+		if _, err := r.live(comment.BlogID); err != nil || (status != "" && comment.Status != status) {
+			continue
+		}
+		total++
+		if comment.ID > after {
+			queued = append(queued, comment)
+		}
+	}
+	sortComments(queued)
+	page, next := pageComments(queued, opts.Limit)
+	return models.CommentList{Items: page, Total: total, NextCursor: next}, nil
+}
+
+// ModerateComment sets the status of a comment on a live blog
+func (r *Repo) ModerateComment(ctx context.Context, id int64, status models.CommentStatus) (models.Comment, error) {
+	if !validCommentStatus(status) {
+		return models.Comment{}, &ValidationError{Fields: []models.FieldError{commentStatusFieldError}}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	comment, exists := r.comments[id]
+	if !exists {
+		return models.Comment{}, errCommentNotFound
+	}
+	if _, err := r.live(comment.BlogID); err != nil {
+		return models.Comment{}, errCommentNotFound
+	}
+	comment.Status = status
+	r.comments[id] = comment
+	return comment, nil
+}
+
+// CommentAuthor returns the author_id of a comment
+func (r *Repo) CommentAuthor(ctx context.Context, id int64) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	comment, exists := r.comments[id]
+	if !exists {
+		return 0, errCommentNotFound
+	}
+	return comment.AuthorID, nil
+}
+
+// CreateUser registers a new user account
+func (r *Repo) CreateUser(ctx context.Context, req models.RegisterRequest) (models.User, error) {
+	if err := ValidateUser(&req); err != nil {
+		return models.User{}, err
+	}
+	// Hash before taking the lock; bcrypt is slow on purpose
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		return models.User{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.usernames[req.Username]; exists {
+		return models.User{}, errUsernameTaken
+	}
+	r.lastUserID++
+	user := models.User{
+		ID:        r.lastUserID,
+		Username:  req.Username,
+		Name:      req.Name,
+		Role:      models.RoleReader,
+		CreatedAt: time.Now(),
+	}
+	r.users[user.ID] = userRecord{User: user, hash: hash}
+	r.usernames[user.Username] = user.ID
+	return user, nil
+}
+
+// GetUser fetches a user by id
+func (r *Repo) GetUser(ctx context.Context, id int64) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	record, exists := r.users[id]
+	if !exists {
+		return models.User{}, errUserNotFound
+	}
+	return record.User, nil
+}
+
+// SetUserRole changes what a user may do
+func (r *Repo) SetUserRole(ctx context.Context, id int64, role models.Role) (models.User, error) {
+	if !ValidRole(role) {
+		return models.User{}, &ValidationError{Fields: []models.FieldError{roleFieldError}}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, exists := r.users[id]
+	if !exists {
+		return models.User{}, errUserNotFound
+	}
+	record.Role = role
+	r.users[id] = record
+	return record.User, nil
+}
+
+// Authenticate returns the user with the given credentials
+func (r *Repo) Authenticate(ctx context.Context, username, password string) (models.User, error) {
+	r.mu.RLock()
+	record := r.users[r.usernames[NormalizeUsername(username)]]
+	r.mu.RUnlock()
+
+	if err := checkPassword(record.hash, password); err != nil {
+		return models.User{}, err
+	}
+	return record.User, nil
+}
+
+// CreateAPIKey issues a new API key
+func (r *Repo) CreateAPIKey(ctx context.Context, req models.APIKeyRequest) (models.NewAPIKey, error) {
+	key, hash, err := newAPIKey(ctx, req)
+	if err != nil {
+		return models.NewAPIKey{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastKeyID++
+	key.ID = r.lastKeyID
+	r.apiKeys[key.ID] = key.APIKey
+	r.apiKeyIDs[hash] = key.ID
+	return key, nil
+}
+
+// ListAPIKeys returns every API key, revoked and expired ones included,
+// oldest first
+func (r *Repo) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]models.APIKey, 0, len(r.apiKeys))
+	for _, key := range r.apiKeys {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b models.APIKey) int { return cmp.Compare(a.ID, b.ID) })
+	return keys, nil
+}
+
+// RevokeAPIKey stops a key from authenticating
+func (r *Repo) RevokeAPIKey(ctx context.Context, id int64) (models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, exists := r.apiKeys[id]
+	if !exists {
+		return models.APIKey{}, errAPIKeyNotFound
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		r.apiKeys[id] = key
+	}
+	return key, nil
+}
+
+// AuthenticateAPIKey returns the usable key matching key
+func (r *Repo) AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	found, exists := r.apiKeys[r.apiKeyIDs[hashAPIKey(key)]]
+	if !exists || !usable(found, now) {
+		return models.APIKey{}, errBadAPIKey
+	}
+	found.LastUsedAt = &now
+	r.apiKeys[found.ID] = found
+	return found, nil
+}
+
+// checkVersion enforces the ifVersion precondition of a write
+func checkVersion(blog models.Blog, ifVersion int64) error {
+	if ifVersion != AnyVersion && ifVersion != blog.Version {
+		return &VersionMismatchError{Expected: ifVersion, Actual: blog.Version}
+	}
+	return nil
+}
+
+// ValidateBlog normalizes blog and checks it against the `validate` tags of
+// models.BlogRequestBody and the limits on tags and categories, reporting
+// every bad field at once. A missing body format defaults to Markdown.
+func ValidateBlog(blog *models.BlogRequestBody) error {
+	var fields []models.FieldError
+	var validationErr *ValidationError
+	if errors.As(Validate(blog), &validationErr) {
+		fields = validationErr.Fields
+	}
+	if fields = normalizeTerms(blog, fields); len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	if blog.BodyFormat == "" {
+		blog.BodyFormat = models.FormatMarkdown
+	}
+	return nil
+}
+
+// renderBody renders the body of a validated request once per write; the
+// result is stored with the blog and its revision
+func renderBody(blog models.BlogRequestBody) (string, error) {
+	return render.HTML(blog.BodyFormat, blog.Body)
 }
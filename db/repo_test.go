@@ -2,14 +2,16 @@ package db
 
 import (
 	"blog_post/models"
-	"errors"
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func createRandomBlog(t *testing.T, r *Repo) models.Blog {
-	blog, err := r.CreateBlog(models.BlogRequestBody{
+var ctx = context.Background()
+
+func createRandomBlog(t *testing.T, r BlogStore) models.Blog {
+	blog, err := r.CreateBlog(ctx, models.BlogRequestBody{
 		Title:       "Random Blog",
 		Description: "Random Description",
 		Body:        "Random Body",
@@ -19,35 +21,30 @@ func createRandomBlog(t *testing.T, r *Repo) models.Blog {
 }
 
 func TestGetAllBlogs(t *testing.T) {
-	r := &Repo{
-		data: make(map[int64]models.Blog),
-	}
+	r := NewRepo()
 	t.Run("No Blogs in DB", func(t *testing.T) {
-		blogs, err := r.GetAllBlogs()
-		assert.Error(t, err)
-		assert.Equal(t, errors.New("no blogs in DB"), err)
-		assert.Empty(t, blogs)
+		page, err := r.GetAllBlogs(ctx, ListOptions{})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Items)
 	})
 	t.Run("Successful Blog Creation", func(t *testing.T) {
 		newBlog := createRandomBlog(t, r)
-		blogs, err := r.GetAllBlogs()
+		page, err := r.GetAllBlogs(ctx, ListOptions{})
 		assert.NoError(t, err)
-		assert.Equal(t, newBlog, blogs[0])
+		assert.Equal(t, newBlog, page.Items[0])
 	})
 }
 
 func TestCreateBlog(t *testing.T) {
-	r := &Repo{
-		data: make(map[int64]models.Blog),
-	}
+	r := NewRepo()
 	t.Run("Missing Required Fields", func(t *testing.T) {
 
-		blog, err := r.CreateBlog(models.BlogRequestBody{
+		blog, err := r.CreateBlog(ctx, models.BlogRequestBody{
 			Title:       "Random Blog",
 			Description: "Random Description",
 			Body:        "",
 		})
-		assert.Error(t, err, "missing required field")
+		assert.ErrorIs(t, err, ErrValidation)
 		assert.Empty(t, blog)
 	})
 	t.Run("Successful Creation", func(t *testing.T) {
@@ -57,51 +54,58 @@ func TestCreateBlog(t *testing.T) {
 }
 
 func TestDeleteBlog(t *testing.T) {
-	r := &Repo{
-		data: make(map[int64]models.Blog),
-	}
+	r := NewRepo()
 	t.Run("Blog Not Found", func(t *testing.T) {
-		err := r.DeleteBlog(1000)
-		assert.Error(t, err, "blog not found")
+		err := r.DeleteBlog(ctx, 1000, AnyVersion)
+		assert.ErrorIs(t, err, ErrNotFound)
 	})
 	t.Run("Successful Deletion", func(t *testing.T) {
 		blog := createRandomBlog(t, r)
-		err := r.DeleteBlog(blog.ID)
+		err := r.DeleteBlog(ctx, blog.ID, AnyVersion)
+		assert.NoError(t, err)
+	})
+	t.Run("Create After Delete", func(t *testing.T) {
+		first := createRandomBlog(t, r)
+		second := createRandomBlog(t, r)
+		assert.NoError(t, r.DeleteBlog(ctx, first.ID, AnyVersion))
+		third := createRandomBlog(t, r)
+		assert.Equal(t, second.ID+1, third.ID)
+		page, err := r.GetAllBlogs(ctx, ListOptions{})
 		assert.NoError(t, err)
+		assert.Equal(t, 2, page.Total)
+		assert.Len(t, r.data, 4, "trashed blogs are kept until purged")
 	})
 
 }
 
 func TestUpdateBlog(t *testing.T) {
-	r := &Repo{
-		data: make(map[int64]models.Blog),
-	}
+	r := NewRepo()
 	t.Run("Missing Required Fields", func(t *testing.T) {
 
-		blog, err := r.UpdateBlog(1000, models.BlogRequestBody{
+		blog, err := r.UpdateBlog(ctx, 1000, models.BlogRequestBody{
 			Title:       "Random Blog",
 			Description: "Random Description",
 			Body:        "",
-		})
-		assert.Error(t, err, "missing required field")
+		}, AnyVersion)
+		assert.ErrorIs(t, err, ErrValidation)
 		assert.Empty(t, blog)
 	})
 	t.Run("Blog Not Found", func(t *testing.T) {
-		blog, err := r.UpdateBlog(1000, models.BlogRequestBody{
+		blog, err := r.UpdateBlog(ctx, 1000, models.BlogRequestBody{
 			Title:       "Updated Blog",
 			Description: "Updated Description",
 			Body:        "Updated Body",
-		})
-		assert.Error(t, err, "blog not found")
+		}, AnyVersion)
+		assert.ErrorIs(t, err, ErrNotFound)
 		assert.Empty(t, blog)
 	})
 	t.Run("Successful Updation", func(t *testing.T) {
 		blog := createRandomBlog(t, r)
-		updatedBlog, err := r.UpdateBlog(blog.ID, models.BlogRequestBody{
+		updatedBlog, err := r.UpdateBlog(ctx, blog.ID, models.BlogRequestBody{
 			Title:       "Updated Blog",
 			Description: "Updated Description",
 			Body:        "Updated Body",
-		})
+		}, AnyVersion)
 		assert.NoError(t, err)
 		assert.NotEmpty(t, blog)
 		assert.NotEqual(t, blog.Body, updatedBlog.Body)
@@ -111,18 +115,17 @@ func TestUpdateBlog(t *testing.T) {
 }
 
 func TestGetBlog(t *testing.T) {
-	r := &Repo{
-		data: make(map[int64]models.Blog),
-	}
+	r := NewRepo()
 	t.Run("Blog Not Found", func(t *testing.T) {
-		blog, err := r.GetBlog(1000)
+		blog, err := r.GetBlog(ctx, 1000)
 		assert.Error(t, err)
-		assert.Equal(t, errors.New("blog not found"), err)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.EqualError(t, err, "blog not found")
 		assert.Empty(t, blog)
 	})
 	t.Run("Successful Blog Creation", func(t *testing.T) {
 		newBlog := createRandomBlog(t, r)
-		blogs, err := r.GetBlog(newBlog.ID)
+		blogs, err := r.GetBlog(ctx, newBlog.ID)
 		assert.NoError(t, err)
 		assert.Equal(t, newBlog, blogs)
 	})
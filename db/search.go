@@ -0,0 +1,363 @@
+package db
+
+import (
+	"blog_post/models"
+	"encoding/base64"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SearchOptions selects one page of search results
+type SearchOptions struct {
+	Limit  int           // page size; DefaultLimit when zero, capped at MaxLimit
+	After  string        // cursor returned as NextCursor by the previous page
+	Status models.Status // only match blogs in this status; any when empty
+}
+
+// Searchable fields, in the order used by fieldTokens and friends
+const (
+	fieldTitle = iota
+	fieldDescription
+	fieldBody
+	numFields
+)
+
+var (
+	fieldNames   = [numFields]string{"title", "description", "body"}
+	fieldWeights = [numFields]float64{3, 2, 1}
+)
+
+// Relevance tuning: term frequency saturates like BM25's k1, and prefix
+// matches count for less than whole-word matches
+const (
+	tfSaturation = 1.2
+	prefixWeight = 0.8
+)
+
+type clauseKind int
+
+const (
+	clauseTerm clauseKind = iota
+	clausePrefix
+	clausePhrase
+)
+
+// clause is one required part of a query: a word, a word prefix (go*) or a
+// quoted phrase. A blog matches a query when it matches every clause.
+type clause struct {
+	kind  clauseKind
+	terms []string
+}
+
+// parseQuery splits a search string into clauses; quotes group phrases and
+// a trailing * makes a prefix
+func parseQuery(q string) []clause {
+	var clauses []clause
+	for i, part := range strings.Split(q, `"`) {
+		if i%2 == 1 {
+			// inside quotes
+			terms := tokenTerms(part)
+			switch len(terms) {
+			case 0:
+			case 1:
+				clauses = append(clauses, clause{kind: clauseTerm, terms: terms})
+			default:
+				clauses = append(clauses, clause{kind: clausePhrase, terms: terms})
+			}
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			prefix := strings.HasSuffix(word, "*")
+			terms := tokenTerms(word)
+			for j, term := range terms {
+				kind := clauseTerm
+				if prefix && j == len(terms)-1 {
+					kind = clausePrefix
+				}
+				clauses = append(clauses, clause{kind: kind, terms: []string{term}})
+			}
+		}
+	}
+	return clauses
+}
+
+// token is a normalised word and where it sits in the original text
+type token struct {
+	term       string
+	start, end int // byte offsets
+}
+
+// tokenize splits text into lower-cased runs of letters and digits
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case word && start < 0:
+			start = i
+		case !word && start >= 0:
+			tokens = append(tokens, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return tokens
+}
+
+func tokenTerms(text string) []string {
+	tokens := tokenize(text)
+	terms := make([]string, len(tokens))
+	for i, t := range tokens {
+		terms[i] = t.term
+	}
+	return terms
+}
+
+// document is a blog analysed for matching: the positions of every term in
+// each field, plus the tokens themselves for building snippets
+type document struct {
+	blog      models.Blog
+	tokens    [numFields][]token
+	positions map[string][numFields][]int
+}
+
+func analyze(blog models.Blog) *document {
+	doc := &document{blog: blog, positions: make(map[string][numFields][]int)}
+	for f, text := range [numFields]string{blog.Title, blog.Description, blog.Body} {
+		doc.tokens[f] = tokenize(text)
+		for pos, t := range doc.tokens[f] {
+			p := doc.positions[t.term]
+			p[f] = append(p[f], pos)
+			doc.positions[t.term] = p
+		}
+	}
+	return doc
+}
+
+// match scores doc against the query. It returns false when any clause is
+// missing; otherwise the relevance score and, per field, the token
+// positions to highlight.
+func (doc *document) match(clauses []clause) (float64, [numFields][]int, bool) {
+	var score float64
+	var marks [numFields][]int
+	for _, c := range clauses {
+		var hits [numFields][]int // start position of each occurrence
+		width, weight := len(c.terms), 1.0
+		switch c.kind {
+		case clauseTerm:
+			hits = doc.positions[c.terms[0]]
+		case clausePrefix:
+			weight = prefixWeight
+			for term, p := range doc.positions {
+				if strings.HasPrefix(term, c.terms[0]) {
+					for f := range hits {
+						hits[f] = append(hits[f], p[f]...)
+					}
+				}
+			}
+		case clausePhrase:
+			hits = doc.phrase(c.terms)
+		}
+
+		found := false
+		for f := range hits {
+			tf := float64(len(hits[f]))
+			if tf == 0 {
+				continue
+			}
+			found = true
+			score += weight * fieldWeights[f] * tf / (tf + tfSaturation)
+			for _, start := range hits[f] {
+				for i := 0; i < width; i++ {
+					marks[f] = append(marks[f], start+i)
+				}
+			}
+		}
+		if !found {
+			return 0, marks, false
+		}
+	}
+	return score, marks, true
+}
+
+// phrase finds where terms appear consecutively, per field
+func (doc *document) phrase(terms []string) [numFields][]int {
+	var hits [numFields][]int
+	first := doc.positions[terms[0]]
+	for f := range first {
+	starts:
+		for _, start := range first[f] {
+			for i, term := range terms[1:] {
+				if !containsInt(doc.positions[term][f], start+i+1) {
+					continue starts
+				}
+			}
+			hits[f] = append(hits[f], start)
+		}
+	}
+	return hits
+}
+
+func containsInt(sorted []int, v int) bool {
+	i := sort.SearchInts(sorted, v)
+	return i < len(sorted) && sorted[i] == v
+}
+
+// Snippets show up to snippetRadius tokens either side of the first match
+const snippetRadius = 12
+
+// highlights renders an HTML-escaped snippet per matching field with the
+// matched words wrapped in <mark>
+func (doc *document) highlights(marks [numFields][]int) map[string]string {
+	out := make(map[string]string)
+	text := [numFields]string{doc.blog.Title, doc.blog.Description, doc.blog.Body}
+	for f := range marks {
+		if len(marks[f]) == 0 {
+			continue
+		}
+		sort.Ints(marks[f])
+		tokens := doc.tokens[f]
+		first := marks[f][0]
+		from, to := 0, len(tokens)-1
+		if f != fieldTitle {
+			from, to = max(first-snippetRadius, 0), min(first+snippetRadius, len(tokens)-1)
+		}
+		start, end := tokens[from].start, tokens[to].end
+		if from == 0 {
+			start = 0
+		}
+		if to == len(tokens)-1 {
+			end = len(text[f])
+		}
+
+		var b strings.Builder
+		if start > 0 {
+			b.WriteString("…")
+		}
+		cursor := start
+		for _, pos := range marks[f] {
+			if pos < from || pos > to || tokens[pos].start < cursor {
+				continue
+			}
+			b.WriteString(html.EscapeString(text[f][cursor:tokens[pos].start]))
+			b.WriteString("<mark>")
+			b.WriteString(html.EscapeString(text[f][tokens[pos].start:tokens[pos].end]))
+			b.WriteString("</mark>")
+			cursor = tokens[pos].end
+		}
+		b.WriteString(html.EscapeString(text[f][cursor:end]))
+		if end < len(text[f]) {
+			b.WriteString("…")
+		}
+		out[fieldNames[f]] = b.String()
+	}
+	return out
+}
+
+// normalize fills in defaults and checks the options and query, returning
+// the parsed clauses and the offset to start from
+func (o *SearchOptions) normalize(query string) ([]clause, int, error) {
+	var fields []models.FieldError
+	clauses := parseQuery(query)
+	if len(clauses) == 0 {
+		fields = append(fields, models.FieldError{Field: "q", Message: "must contain at least one word"})
+	}
+	if o.Limit < 0 {
+		fields = append(fields, models.FieldError{Field: "limit", Message: "must not be negative"})
+	}
+	if o.Status != "" && !validStatus(o.Status) {
+		fields = append(fields, statusFieldError)
+	}
+	if o.Limit == 0 {
+		o.Limit = DefaultLimit
+	}
+	if o.Limit > MaxLimit {
+		o.Limit = MaxLimit
+	}
+	offset := 0
+	if o.After != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(o.After)
+		n, convErr := strconv.Atoi(strings.TrimPrefix(string(raw), "offset:"))
+		if err != nil || convErr != nil || !strings.HasPrefix(string(raw), "offset:") || n < 0 {
+			fields = append(fields, models.FieldError{Field: "after", Message: "is not a valid search cursor"})
+		}
+		offset = n
+	}
+	if len(fields) > 0 {
+		return nil, 0, &ValidationError{Fields: fields}
+	}
+	return clauses, offset, nil
+}
+
+func searchCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+// rank matches the candidate documents, orders them by relevance (ties go to
+// the lower ID) and cuts out the requested page
+func rank(candidates []*document, clauses []clause, opts SearchOptions, offset int) models.SearchResults {
+	hits := make([]models.SearchHit, 0, len(candidates))
+	for _, doc := range candidates {
+		if opts.Status != "" && doc.blog.Status != opts.Status {
+			continue
+		}
+		score, marks, ok := doc.match(clauses)
+		if !ok {
+			continue
+		}
+		hits = append(hits, models.SearchHit{
+			Blog:       doc.blog,
+			Score:      score,
+			Highlights: doc.highlights(marks),
+		})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	results := models.SearchResults{Items: []models.SearchHit{}, Total: len(hits)}
+	if offset < len(hits) {
+		end := min(offset+opts.Limit, len(hits))
+		results.Items = hits[offset:end]
+		if end < len(hits) {
+			results.NextCursor = searchCursor(end)
+		}
+	}
+	return results
+}
+
+// likePatterns returns substring patterns every match must contain, for
+// backends that pre-filter in SQL. Only ASCII terms are used because not
+// every database folds the case of other scripts.
+func likePatterns(clauses []clause) []string {
+	var patterns []string
+	for _, c := range clauses {
+		for _, term := range c.terms {
+			if !isASCII(term) {
+				continue
+			}
+			escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+			patterns = append(patterns, "%"+escaped+"%")
+		}
+	}
+	return patterns
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"blog_post/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected []clause
+	}{
+		{"Go", []clause{{kind: clauseTerm, terms: []string{"go"}}}},
+		{"go concurrency", []clause{
+			{kind: clauseTerm, terms: []string{"go"}},
+			{kind: clauseTerm, terms: []string{"concurrency"}},
+		}},
+		{"conc*", []clause{{kind: clausePrefix, terms: []string{"conc"}}}},
+		{`"worker pools" go`, []clause{
+			{kind: clausePhrase, terms: []string{"worker", "pools"}},
+			{kind: clauseTerm, terms: []string{"go"}},
+		}},
+		{`"single"`, []clause{{kind: clauseTerm, terms: []string{"single"}}}},
+		{"error-handling", []clause{
+			{kind: clauseTerm, terms: []string{"error"}},
+			{kind: clauseTerm, terms: []string{"handling"}},
+		}},
+		{`  "" * !! `, nil},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, parseQuery(test.query), test.query)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tokens := tokenize("Héllo, wörld 42!")
+	assert.Equal(t, []token{
+		{"héllo", 0, 6},
+		{"wörld", 8, 14},
+		{"42", 15, 17},
+	}, tokens)
+}
+
+func TestHighlights(t *testing.T) {
+	doc := analyze(models.Blog{
+		Title:       "Go <generics> explained",
+		Description: "short",
+		Body:        "one two three four five six seven eight nine ten eleven twelve thirteen generics fourteen fifteen sixteen seventeen eighteen nineteen twenty twenty-one twenty-two twenty-three twenty-four twenty-five",
+	})
+	_, marks, ok := doc.match(parseQuery("generics"))
+	assert.True(t, ok)
+	highlights := doc.highlights(marks)
+	assert.Equal(t, "Go &lt;<mark>generics</mark>&gt; explained", highlights["title"])
+	assert.Equal(t, "…two three four five six seven eight nine ten eleven twelve thirteen <mark>generics</mark> fourteen fifteen sixteen seventeen eighteen nineteen twenty twenty-one twenty-two twenty…", highlights["body"], "snippets keep 12 words either side of the first match")
+	assert.NotContains(t, highlights, "description")
+}
+
+func TestSearchIndex(t *testing.T) {
+	ix := newSearchIndex()
+	ix.put(models.Blog{ID: 1, Title: "Gophers", Description: "d", Body: "concurrency"})
+	ix.put(models.Blog{ID: 2, Title: "Goroutines", Description: "d", Body: "channels"})
+	assert.Len(t, ix.candidates(parseQuery("go*")), 2)
+	assert.Len(t, ix.candidates(parseQuery("gophers")), 1)
+
+	ix.put(models.Blog{ID: 1, Title: "Rust", Description: "d", Body: "ownership"})
+	assert.Empty(t, ix.candidates(parseQuery("gophers")))
+	assert.NotContains(t, ix.terms, "gophers", "terms no longer used are dropped from the vocabulary")
+
+	ix.remove(2)
+	assert.Empty(t, ix.candidates(parseQuery("go*")))
+	assert.Equal(t, []string{"d", "ownership", "rust"}, ix.terms)
+}
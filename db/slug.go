@@ -0,0 +1,74 @@
+package db
+
+import (
+	"blog_post/models"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gosimple/slug"
+)
+
+// MaxSlugLength bounds generated and client-chosen slugs alike
+const MaxSlugLength = 80
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Slugify derives a URL slug from a title, transliterating non-Latin
+// scripts ("Привет мир" becomes "privet-mir"). Titles without any usable
+// characters fall back to "post".
+func Slugify(title string) string {
+	s := slug.Make(title)
+	if len(s) > MaxSlugLength {
+		s = s[:MaxSlugLength]
+		if i := strings.LastIndexByte(s, '-'); i > 0 {
+			s = s[:i]
+		}
+	}
+	s = strings.Trim(s, "-")
+	if s == "" {
+		return "post"
+	}
+	return s
+}
+
+// validSlug reports whether a client-chosen slug is already in canonical form
+func validSlug(s string) bool {
+	return len(s) <= MaxSlugLength && slugPattern.MatchString(s)
+}
+
+var slugFieldError = models.FieldError{
+	Field:   "slug",
+	Message: "must be lowercase letters, digits and single hyphens, at most " + strconv.Itoa(MaxSlugLength) + " characters",
+}
+
+// uniqueSlug returns base, or base with the lowest numeric suffix ("-2",
+// "-3"...) that taken does not report as used
+func uniqueSlug(base string, taken func(string) (bool, error)) (string, error) {
+	candidate := base
+	for n := 2; ; n++ {
+		used, err := taken(candidate)
+		if err != nil || !used {
+			return candidate, err
+		}
+		suffix := "-" + strconv.Itoa(n)
+		candidate = strings.TrimRight(base[:min(len(base), MaxSlugLength-len(suffix))], "-") + suffix
+	}
+}
+
+// slugFor picks the slug a write should end up with. A slug in the request
+// wins; otherwise the current slug is kept unless the title changed, in
+// which case a new one is derived from it. fixed reports whether the slug
+// must be used as is, so that a clash is an error rather than a reason to
+// pick a suffixed one.
+func slugFor(req models.BlogRequestBody, old *models.Blog) (slug string, fixed bool) {
+	switch {
+	case req.Slug != "":
+		return req.Slug, true
+	case old != nil && old.Title == req.Title:
+		return old.Slug, true
+	}
+	return Slugify(req.Title), false
+}
+
+var errSlugTaken = &ConflictError{Reason: "slug already taken"}
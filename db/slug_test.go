@@ -0,0 +1,49 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title, slug string
+	}{
+		{"Hello, World!", "hello-world"},
+		{"Héllo Wörld", "hello-world"},
+		{"Straße", "strasse"},
+		{"Привет мир", "privet-mir"},
+		{"Go & Rust", "go-and-rust"},
+		{"  --  ", "post"},
+		{"!!!", "post"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.slug, Slugify(test.title), test.title)
+	}
+
+	long := Slugify(strings.Repeat("word ", 40))
+	assert.LessOrEqual(t, len(long), MaxSlugLength)
+	assert.True(t, validSlug(long), "truncation must not leave a dangling hyphen: %q", long)
+}
+
+func TestUniqueSlug(t *testing.T) {
+	used := map[string]bool{"post": true, "post-2": true}
+	taken := func(s string) (bool, error) { return used[s], nil }
+
+	slug, err := uniqueSlug("fresh", taken)
+	require.NoError(t, err)
+	assert.Equal(t, "fresh", slug)
+	slug, err = uniqueSlug("post", taken)
+	require.NoError(t, err)
+	assert.Equal(t, "post-3", slug)
+
+	long := strings.Repeat("a", MaxSlugLength)
+	used[long] = true
+	slug, err = uniqueSlug(long, taken)
+	require.NoError(t, err)
+	assert.Len(t, slug, MaxSlugLength)
+	assert.True(t, strings.HasSuffix(slug, "-2"))
+}
@@ -0,0 +1,1394 @@
+package db
+
+import (
+	"blog_post/models"
+	"blog_post/render"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// dialect captures the differences between the SQL backends
+type dialect struct {
+	name            string
+	driver          string
+	numbered        bool   // $1, $2... placeholders instead of ?
+	binaryCollation string // clause forcing bytewise string comparison
+	likeOp          string // case-insensitive (for ASCII) LIKE operator
+	forUpdate       string // clause locking selected rows until commit
+	// uniqueViolation reports whether err came from a UNIQUE constraint
+	uniqueViolation func(err error) bool
+}
+
+// rebind rewrites ? placeholders into the dialect's native form
+func (d dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, ch := range query {
+		if ch == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
+
+// sqlRepo implements BlogStore on top of database/sql; SQLiteRepo and
+// PostgresRepo only differ in how the connection is opened
+type sqlRepo struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+const (
+	blogColumns     = `id, slug, title, description, body, body_format, body_html, created_at, updated_at, version, status, publish_at, deleted_at, author_id`
+	revisionColumns = `blog_id, version, title, description, body, body_format, body_html, status, author, created_at`
+	userColumns     = `id, username, name, role, created_at`
+	apiKeyColumns   = `id, name, prefix, scope, created_by, created_at, expires_at, last_used_at, revoked_at`
+	categoryColumns = `id, slug, name, description, created_at`
+	commentColumns  = `id, blog_id, parent_id, author_id, author, body, status, created_at, updated_at`
+)
+
+// Close releases the underlying database handle
+func (r *sqlRepo) Close() error {
+	return r.db.Close()
+}
+
+// Migrator returns a Migrator for the repo's embedded schema migrations
+func (r *sqlRepo) Migrator() (*Migrator, error) {
+	return newMigrator(r.db, r.dialect)
+}
+
+// GetAllBlogs lists one page of blogs; an empty store yields an empty page
+func (r *sqlRepo) GetAllBlogs(ctx context.Context, opts ListOptions) (models.BlogList, error) {
+	pos, backward, err := opts.normalize()
+	if err != nil {
+		return models.BlogList{}, err
+	}
+	// Walking backwards means reading the reversed order from the cursor.
+	ascending := opts.Desc == backward
+
+	filter, filterArgs := listFilter(opts)
+	query := `SELECT ` + blogColumns + ` FROM blogs WHERE ` + filter
+	args := slices.Clone(filterArgs)
+	if pos != nil {
+		where, whereArgs := r.keyset(opts.Sort, ascending, pos)
+		query += ` AND ` + where
+		args = append(args, whereArgs...)
+	}
+	dir := "ASC"
+	if !ascending {
+		dir = "DESC"
+	}
+	query += ` ORDER BY ` + r.sortColumn(opts.Sort) + ` ` + dir + `, id ` + dir + ` LIMIT ?`
+	args = append(args, opts.Limit+1)
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind(query), args...)
+	if err != nil {
+		return models.BlogList{}, err
+	}
+	defer rows.Close()
+	items := make([]models.Blog, 0, opts.Limit)
+	for rows.Next() {
+		blog, err := scanBlog(rows)
+		if err != nil {
+			return models.BlogList{}, err
+		}
+		items = append(items, blog)
+	}
+	if err := rows.Err(); err != nil {
+		return models.BlogList{}, err
+	}
+	more := len(items) > opts.Limit
+	if more {
+		items = items[:opts.Limit]
+	}
+	if backward {
+		slices.Reverse(items)
+	}
+	listed := make([]*models.Blog, len(items))
+	for i := range items {
+		listed[i] = &items[i]
+	}
+	if err := r.loadTerms(ctx, r.db, listed...); err != nil {
+		return models.BlogList{}, err
+	}
+
+	page := models.BlogList{Items: items}
+	err = r.db.QueryRowContext(ctx, r.dialect.rebind(`SELECT COUNT(*) FROM blogs WHERE `+filter), filterArgs...).Scan(&page.Total)
+	if err != nil {
+		return models.BlogList{}, err
+	}
+	if len(items) == 0 {
+		return page, nil
+	}
+
+	first, last := cursorFor(items[0], opts), cursorFor(items[len(items)-1], opts)
+	hasNext, hasPrev := more, more
+	if backward {
+		hasNext, err = r.exists(ctx, opts, !opts.Desc, last)
+	} else if pos != nil {
+		hasPrev, err = r.exists(ctx, opts, opts.Desc, first)
+	} else {
+		hasPrev = false
+	}
+	if err != nil {
+		return models.BlogList{}, err
+	}
+	if hasNext {
+		page.NextCursor = last.encode()
+	}
+	if hasPrev {
+		page.PrevCursor = first.encode()
+	}
+	return page, nil
+}
+
+// SearchBlogs runs a full-text query over title, description and body. SQL
+// narrows the candidates down by substring; ranking and snippets are then
+// computed exactly as for the in-memory store.
+func (r *sqlRepo) SearchBlogs(ctx context.Context, query string, opts SearchOptions) (models.SearchResults, error) {
+	clauses, offset, err := opts.normalize(query)
+	if err != nil {
+		return models.SearchResults{}, err
+	}
+	stmt := `SELECT ` + blogColumns + ` FROM blogs WHERE deleted_at IS NULL`
+	var args []any
+	if opts.Status != "" {
+		stmt += ` AND status = ?`
+		args = append(args, opts.Status)
+	}
+	for _, pattern := range likePatterns(clauses) {
+		stmt += ` AND (title || ' ' || description || ' ' || body) ` + r.dialect.likeOp + ` ? ESCAPE '\'`
+		args = append(args, pattern)
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind(stmt), args...)
+	if err != nil {
+		return models.SearchResults{}, err
+	}
+	defer rows.Close()
+	var candidates []*document
+	for rows.Next() {
+		blog, err := scanBlog(rows)
+		if err != nil {
+			return models.SearchResults{}, err
+		}
+		candidates = append(candidates, analyze(blog))
+	}
+	if err := rows.Err(); err != nil {
+		return models.SearchResults{}, err
+	}
+	results := rank(candidates, clauses, opts, offset)
+	hits := make([]*models.Blog, len(results.Items))
+	for i := range results.Items {
+		hits[i] = &results.Items[i].Blog
+	}
+	if err := r.loadTerms(ctx, r.db, hits...); err != nil {
+		return models.SearchResults{}, err
+	}
+	return results, nil
+}
+
+// sortColumn is the ORDER BY expression for field. Titles compare bytewise
+// on every backend so that all stores agree on the order.
+func (r *sqlRepo) sortColumn(field SortField) string {
+	if field == SortTitle {
+		return "title" + r.dialect.binaryCollation
+	}
+	return string(field)
+}
+
+// keyset builds the condition selecting rows strictly beyond pos when
+// walking in the given direction
+func (r *sqlRepo) keyset(field SortField, ascending bool, pos *cursor) (string, []any) {
+	col, op := r.sortColumn(field), ">"
+	if !ascending {
+		op = "<"
+	}
+	return `(` + col + ` ` + op + ` ? OR (` + col + ` = ? AND id ` + op + ` ?))`,
+		[]any{pos.value(), pos.value(), pos.ID}
+}
+
+// exists reports whether any row listed by opts lies beyond pos in the
+// given direction
+func (r *sqlRepo) exists(ctx context.Context, opts ListOptions, ascending bool, pos *cursor) (bool, error) {
+	filter, args := listFilter(opts)
+	where, whereArgs := r.keyset(opts.Sort, ascending, pos)
+	var found int
+	err := r.db.QueryRowContext(ctx, r.dialect.rebind(
+		`SELECT 1 FROM blogs WHERE `+filter+` AND `+where+` LIMIT 1`), append(args, whereArgs...)...).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetBlogBySlug fetches a blog by its current slug or by one it had before;
+// compare the returned blog's Slug to tell the two apart
+func (r *sqlRepo) GetBlogBySlug(ctx context.Context, slug string) (models.Blog, error) {
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind(
+		`SELECT `+blogColumns+` FROM blogs WHERE deleted_at IS NULL
+		AND (slug = ? OR id = (SELECT blog_id FROM slug_history WHERE slug = ?))
+		ORDER BY slug = ? DESC LIMIT 1`), slug, slug, slug)
+	blog, err := scanBlog(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Blog{}, errBlogNotFound
+	}
+	if err != nil {
+		return models.Blog{}, err
+	}
+	return blog, r.loadTerms(ctx, r.db, &blog)
+}
+
+// GetBlog fetches a blog by id
+func (r *sqlRepo) GetBlog(ctx context.Context, id int64) (models.Blog, error) {
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind(`SELECT `+blogColumns+` FROM blogs WHERE id = ? AND deleted_at IS NULL`), id)
+	blog, err := scanBlog(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Blog{}, errBlogNotFound
+	}
+	if err != nil {
+		return models.Blog{}, err
+	}
+	return blog, r.loadTerms(ctx, r.db, &blog)
+}
+
+// DeleteBlog moves a blog to the trash as its next version
+func (r *sqlRepo) DeleteBlog(ctx context.Context, id int64, ifVersion int64) error {
+	where, whereArgs := versionedWhere(id, ifVersion)
+	now := time.Now().UTC()
+	args := append([]any{now, now}, whereArgs...)
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx, r.dialect.rebind(
+			`UPDATE blogs SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE `+where+` RETURNING `+blogColumns), args...)
+		trashed, err := scanBlog(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			return r.writeMissed(ctx, tx, id, ifVersion)
+		}
+		if err != nil {
+			return err
+		}
+		return r.record(ctx, tx, trashed)
+	})
+}
+
+// CreateBlog creates a new blog
+func (r *sqlRepo) CreateBlog(ctx context.Context, blog models.BlogRequestBody) (models.Blog, error) {
+	if err := ValidateBlog(&blog); err != nil {
+		log.Errorf("CreateBlog failed: %v", err)
+		return models.Blog{}, err
+	}
+	bodyHTML, err := renderBody(blog)
+	if err != nil {
+		return models.Blog{}, err
+	}
+	now := time.Now().UTC()
+	var created models.Blog
+	err = r.retrySlug(func() error {
+		return r.inTx(ctx, func(tx *sql.Tx) error {
+			categoryIDs, err := r.categoryIDs(ctx, tx, blog.Categories)
+			if err != nil {
+				return err
+			}
+			slug, err := r.assignSlug(ctx, tx, blog, nil)
+			if err != nil {
+				return err
+			}
+			row := tx.QueryRowContext(ctx, r.dialect.rebind(
+				`INSERT INTO blogs (slug, title, description, body, body_format, body_html, created_at, updated_at, version, status, author_id)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?) RETURNING `+blogColumns),
+				slug, blog.Title, blog.Description, blog.Body, blog.BodyFormat, bodyHTML, now, now, models.StatusDraft, authorOf(ctx))
+			if created, err = scanBlog(row); err != nil {
+				return err
+			}
+			if err := r.moveSlug(ctx, tx, created.ID, "", slug); err != nil {
+				return err
+			}
+			if err := r.fileTerms(ctx, tx, created.ID, blog.Tags, categoryIDs); err != nil {
+				return err
+			}
+			created.Tags, created.Categories = blog.Tags, blog.Categories
+			return r.record(ctx, tx, created)
+		})
+	})
+	if err != nil {
+		return models.Blog{}, err
+	}
+	return created, nil
+}
+
+// UpdateBlog updates an existing blog
+func (r *sqlRepo) UpdateBlog(ctx context.Context, id int64, blog models.BlogRequestBody, ifVersion int64) (models.Blog, error) {
+	if err := ValidateBlog(&blog); err != nil {
+		log.Errorf("UpdateBlog failed: %v", err)
+		return models.Blog{}, err
+	}
+	bodyHTML, err := renderBody(blog)
+	if err != nil {
+		return models.Blog{}, err
+	}
+	now := time.Now().UTC()
+	where, whereArgs := versionedWhere(id, ifVersion)
+	var updated models.Blog
+	err = r.retrySlug(func() error {
+		return r.inTx(ctx, func(tx *sql.Tx) error {
+			var old models.Blog
+			err := tx.QueryRowContext(ctx, r.dialect.rebind(
+				`SELECT id, slug, title FROM blogs WHERE id = ? AND deleted_at IS NULL`+r.dialect.forUpdate), id).
+				Scan(&old.ID, &old.Slug, &old.Title)
+			if errors.Is(err, sql.ErrNoRows) {
+				return errBlogNotFound
+			}
+			if err != nil {
+				return err
+			}
+			categoryIDs, err := r.categoryIDs(ctx, tx, blog.Categories)
+			if err != nil {
+				return err
+			}
+			slug, err := r.assignSlug(ctx, tx, blog, &old)
+			if err != nil {
+				return err
+			}
+			args := append([]any{slug, blog.Title, blog.Description, blog.Body, blog.BodyFormat, bodyHTML, now}, whereArgs...)
+			row := tx.QueryRowContext(ctx, r.dialect.rebind(
+				`UPDATE blogs SET slug = ?, title = ?, description = ?, body = ?, body_format = ?, body_html = ?, updated_at = ?, version = version + 1
+				WHERE `+where+` RETURNING `+blogColumns), args...)
+			updated, err = scanBlog(row)
+			if errors.Is(err, sql.ErrNoRows) {
+				return r.writeMissed(ctx, tx, id, ifVersion)
+			}
+			if err != nil {
+				return err
+			}
+			if err := r.moveSlug(ctx, tx, id, old.Slug, slug); err != nil {
+				return err
+			}
+			if err := r.fileTerms(ctx, tx, id, blog.Tags, categoryIDs); err != nil {
+				return err
+			}
+			updated.Tags, updated.Categories = blog.Tags, blog.Categories
+			return r.record(ctx, tx, updated)
+		})
+	})
+	if err != nil {
+		return models.Blog{}, err
+	}
+	return updated, nil
+}
+
+// assignSlug resolves the slug of a write to old (nil for a new blog); a
+// slug counts as taken while any other blog, trashed or not, holds it
+func (r *sqlRepo) assignSlug(ctx context.Context, tx *sql.Tx, req models.BlogRequestBody, old *models.Blog) (string, error) {
+	var self int64
+	if old != nil {
+		self = old.ID
+	}
+	taken := func(slug string) (bool, error) {
+		var found int
+		err := tx.QueryRowContext(ctx, r.dialect.rebind(`SELECT 1 FROM blogs WHERE slug = ? AND id <> ?`), slug, self).Scan(&found)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	slug, fixed := slugFor(req, old)
+	if !fixed {
+		return uniqueSlug(slug, taken)
+	}
+	used, err := taken(slug)
+	if err != nil {
+		return "", err
+	}
+	if used {
+		return "", errSlugTaken
+	}
+	return slug, nil
+}
+
+// moveSlug retires a blog's previous slug into the history so old links
+// keep resolving, and forgets any history entry for its new slug
+func (r *sqlRepo) moveSlug(ctx context.Context, tx *sql.Tx, id int64, from, to string) error {
+	if from == to {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, r.dialect.rebind(`DELETE FROM slug_history WHERE slug IN (?, ?)`), from, to); err != nil {
+		return err
+	}
+	if from == "" {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, r.dialect.rebind(`INSERT INTO slug_history (slug, blog_id) VALUES (?, ?)`), from, id)
+	return err
+}
+
+// retrySlug reruns a write whose generated slug was claimed by a concurrent
+// transaction between choosing it and committing; a slug the client asked
+// for is reported as taken instead
+func (r *sqlRepo) retrySlug(write func() error) error {
+	const attempts = 3
+	var err error
+	for i := 0; i < attempts; i++ {
+		if err = write(); err == nil || !r.dialect.uniqueViolation(err) {
+			return err
+		}
+	}
+	return errSlugTaken
+}
+
+// TransitionBlog moves a blog to another lifecycle status. The allowed
+// source statuses are part of the UPDATE, so concurrent transitions cannot
+// sneak past the lifecycle rules.
+func (r *sqlRepo) TransitionBlog(ctx context.Context, id int64, to models.Status, publishAt *time.Time, ifVersion int64) (models.Blog, error) {
+	now := time.Now().UTC()
+	publishAt, err := publishAtFor(to, publishAt, nil, now)
+	if err != nil {
+		return models.Blog{}, err
+	}
+	set, args := `status = ?, updated_at = ?, version = version + 1`, []any{to, now}
+	if to != models.StatusArchived {
+		set += `, publish_at = ?`
+		args = append(args, publishAt)
+	}
+	where, whereArgs := versionedWhere(id, ifVersion)
+	args = append(args, whereArgs...)
+	from := sourcesOf(to)
+	for _, s := range from {
+		args = append(args, s)
+	}
+	where += ` AND status IN (?` + strings.Repeat(`, ?`, len(from)-1) + `)`
+
+	var moved models.Blog
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx, r.dialect.rebind(`UPDATE blogs SET `+set+` WHERE `+where+` RETURNING `+blogColumns), args...)
+		moved, err = scanBlog(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			return r.transitionMissed(ctx, tx, id, to, ifVersion)
+		}
+		if err != nil {
+			return err
+		}
+		if err := r.loadTerms(ctx, tx, &moved); err != nil {
+			return err
+		}
+		return r.record(ctx, tx, moved)
+	})
+	if err != nil {
+		return models.Blog{}, err
+	}
+	return moved, nil
+}
+
+// transitionMissed explains why a transition matched no row
+func (r *sqlRepo) transitionMissed(ctx context.Context, tx *sql.Tx, id int64, to models.Status, ifVersion int64) error {
+	var version int64
+	var status models.Status
+	err := tx.QueryRowContext(ctx, r.dialect.rebind(
+		`SELECT version, status FROM blogs WHERE id = ? AND deleted_at IS NULL`), id).Scan(&version, &status)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return errBlogNotFound
+	case err != nil:
+		return err
+	case ifVersion != AnyVersion && ifVersion != version:
+		return &VersionMismatchError{Expected: ifVersion, Actual: version}
+	}
+	return transitionError(status, to)
+}
+
+// PublishDue publishes every scheduled blog whose publish_at is not after now
+func (r *sqlRepo) PublishDue(ctx context.Context, now time.Time) (int, error) {
+	var published []models.Blog
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, r.dialect.rebind(
+			`UPDATE blogs SET status = ?, updated_at = ?, version = version + 1
+			WHERE status = ? AND deleted_at IS NULL AND publish_at <= ? RETURNING `+blogColumns),
+			models.StatusPublished, time.Now().UTC(), models.StatusScheduled, now.UTC())
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			blog, err := scanBlog(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			published = append(published, blog)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, blog := range published {
+			if err := r.record(ctx, tx, blog); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(published), nil
+}
+
+// ListRevisions returns every revision of a blog, oldest first
+func (r *sqlRepo) ListRevisions(ctx context.Context, id int64) ([]models.Revision, error) {
+	if err := r.blogExists(ctx, id); err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind(
+		`SELECT `+revisionColumns+` FROM blog_revisions WHERE blog_id = ? ORDER BY version`), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revisions := []models.Revision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+// GetRevision fetches the revision that produced the given blog version
+func (r *sqlRepo) GetRevision(ctx context.Context, id int64, version int64) (models.Revision, error) {
+	if err := r.blogExists(ctx, id); err != nil {
+		return models.Revision{}, err
+	}
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind(
+		`SELECT `+revisionColumns+` FROM blog_revisions WHERE blog_id = ? AND version = ?`), id, version)
+	rev, err := scanRevision(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Revision{}, errRevisionNotFound
+	}
+	return rev, err
+}
+
+// BlogAuthor returns the author_id of a blog, trashed or not
+func (r *sqlRepo) BlogAuthor(ctx context.Context, id int64) (int64, error) {
+	var author sql.NullInt64
+	err := r.db.QueryRowContext(ctx, r.dialect.rebind(`SELECT author_id FROM blogs WHERE id = ?`), id).Scan(&author)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, errBlogNotFound
+	}
+	return author.Int64, err
+}
+
+// TagCounts counts the live blogs carrying each tag
+func (r *sqlRepo) TagCounts(ctx context.Context, status models.Status) ([]models.TagCount, error) {
+	if status != "" && !validStatus(status) {
+		return nil, &ValidationError{Fields: []models.FieldError{statusFieldError}}
+	}
+	query := `SELECT t.tag, COUNT(*) FROM blog_tags t JOIN blogs b ON b.id = t.blog_id WHERE b.deleted_at IS NULL`
+	var args []any
+	if status != "" {
+		query += ` AND b.status = ?`
+		args = append(args, status)
+	}
+	query += ` GROUP BY t.tag ORDER BY COUNT(*) DESC, t.tag` + r.dialect.binaryCollation
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cloud := []models.TagCount{}
+	for rows.Next() {
+		var count models.TagCount
+		if err := rows.Scan(&count.Tag, &count.Count); err != nil {
+			return nil, err
+		}
+		cloud = append(cloud, count)
+	}
+	return cloud, rows.Err()
+}
+
+// categoryIDs resolves the category slugs a blog names, rejecting unknown
+// ones
+func (r *sqlRepo) categoryIDs(ctx context.Context, tx *sql.Tx, slugs []string) ([]int64, error) {
+	if len(slugs) == 0 {
+		return nil, nil
+	}
+	rows, err := tx.QueryContext(ctx, r.dialect.rebind(
+		`SELECT id, slug FROM categories WHERE slug IN `+placeholders(len(slugs))), anySlice(slugs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	found := make(map[string]int64, len(slugs))
+	for rows.Next() {
+		var id int64
+		var slug string
+		if err := rows.Scan(&id, &slug); err != nil {
+			return nil, err
+		}
+		found[slug] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(slugs))
+	var missing []string
+	for _, slug := range slugs {
+		if id, ok := found[slug]; ok {
+			ids = append(ids, id)
+		} else {
+			missing = append(missing, slug)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, unknownCategories(missing)
+	}
+	return ids, nil
+}
+
+// fileTerms replaces the tags and categories of a blog in the write's
+// transaction
+func (r *sqlRepo) fileTerms(ctx context.Context, tx *sql.Tx, id int64, tags []string, categoryIDs []int64) error {
+	for _, stmt := range []string{`DELETE FROM blog_tags WHERE blog_id = ?`, `DELETE FROM blog_categories WHERE blog_id = ?`} {
+		if _, err := tx.ExecContext(ctx, r.dialect.rebind(stmt), id); err != nil {
+			return err
+		}
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, r.dialect.rebind(`INSERT INTO blog_tags (blog_id, tag) VALUES (?, ?)`), id, tag); err != nil {
+			return err
+		}
+	}
+	for _, categoryID := range categoryIDs {
+		if _, err := tx.ExecContext(ctx, r.dialect.rebind(
+			`INSERT INTO blog_categories (blog_id, category_id) VALUES (?, ?)`), id, categoryID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTerms fills in the tags and categories of blogs, two queries for
+// all of them
+func (r *sqlRepo) loadTerms(ctx context.Context, q querier, blogs ...*models.Blog) error {
+	if len(blogs) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*models.Blog, len(blogs))
+	ids := make([]any, len(blogs))
+	for i, blog := range blogs {
+		blog.Tags, blog.Categories = []string{}, []string{}
+		byID[blog.ID] = blog
+		ids[i] = blog.ID
+	}
+	in := placeholders(len(ids))
+	load := func(query string, add func(blog *models.Blog, term string)) error {
+		rows, err := q.QueryContext(ctx, r.dialect.rebind(query), ids...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int64
+			var term string
+			if err := rows.Scan(&id, &term); err != nil {
+				return err
+			}
+			add(byID[id], term)
+		}
+		return rows.Err()
+	}
+	err := load(`SELECT blog_id, tag FROM blog_tags WHERE blog_id IN `+in+` ORDER BY tag`+r.dialect.binaryCollation,
+		func(blog *models.Blog, tag string) { blog.Tags = append(blog.Tags, tag) })
+	if err != nil {
+		return err
+	}
+	return load(`SELECT bc.blog_id, c.slug FROM blog_categories bc JOIN categories c ON c.id = bc.category_id
+		WHERE bc.blog_id IN `+in+` ORDER BY c.slug`+r.dialect.binaryCollation,
+		func(blog *models.Blog, slug string) { blog.Categories = append(blog.Categories, slug) })
+}
+
+// record stores the revision produced by a write in the write's transaction
+func (r *sqlRepo) record(ctx context.Context, tx *sql.Tx, blog models.Blog) error {
+	rev := revisionOf(ctx, blog)
+	_, err := tx.ExecContext(ctx, r.dialect.rebind(
+		`INSERT INTO blog_revisions (`+revisionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		rev.BlogID, rev.Version, rev.Title, rev.Description, rev.Body, rev.BodyFormat, rev.BodyHTML, rev.Status, rev.Author, rev.CreatedAt)
+	return err
+}
+
+// blogExists returns errBlogNotFound unless the blog is stored and live
+func (r *sqlRepo) blogExists(ctx context.Context, id int64) error {
+	var found int
+	err := r.db.QueryRowContext(ctx, r.dialect.rebind(`SELECT 1 FROM blogs WHERE id = ? AND deleted_at IS NULL`), id).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errBlogNotFound
+	}
+	return err
+}
+
+// inTx runs fn in a transaction, committing only if it succeeds
+func (r *sqlRepo) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// RestoreBlog takes a blog back out of the trash as its next version
+func (r *sqlRepo) RestoreBlog(ctx context.Context, id int64) (models.Blog, error) {
+	var restored models.Blog
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx, r.dialect.rebind(
+			`UPDATE blogs SET deleted_at = NULL, updated_at = ?, version = version + 1
+			WHERE id = ? AND deleted_at IS NOT NULL RETURNING `+blogColumns), time.Now().UTC(), id)
+		var err error
+		restored, err = scanBlog(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			return r.notTrashed(ctx, tx, id)
+		}
+		if err != nil {
+			return err
+		}
+		if err := r.loadTerms(ctx, tx, &restored); err != nil {
+			return err
+		}
+		return r.record(ctx, tx, restored)
+	})
+	if err != nil {
+		return models.Blog{}, err
+	}
+	return restored, nil
+}
+
+// PurgeBlog permanently removes a trashed blog; its revisions and comments
+// cascade
+func (r *sqlRepo) PurgeBlog(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, r.dialect.rebind(`DELETE FROM blogs WHERE id = ? AND deleted_at IS NOT NULL`), id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return r.notTrashed(ctx, r.db, id)
+	}
+	return nil
+}
+
+// PurgeDeleted permanently removes every blog trashed before cutoff
+func (r *sqlRepo) PurgeDeleted(ctx context.Context, cutoff time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx, r.dialect.rebind(
+		`DELETE FROM blogs WHERE deleted_at IS NOT NULL AND deleted_at < ?`), cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// notTrashed explains why a trash operation matched no row
+func (r *sqlRepo) notTrashed(ctx context.Context, q querier, id int64) error {
+	var found int
+	err := q.QueryRowContext(ctx, r.dialect.rebind(`SELECT 1 FROM blogs WHERE id = ?`), id).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errBlogNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return errBlogNotTrashed
+}
+
+// CreateCategory adds a category
+func (r *sqlRepo) CreateCategory(ctx context.Context, req models.CategoryRequest) (models.Category, error) {
+	if err := ValidateCategory(&req); err != nil {
+		return models.Category{}, err
+	}
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind(
+		`INSERT INTO categories (slug, name, description, created_at) VALUES (?, ?, ?, ?) RETURNING `+categoryColumns),
+		categorySlugFor(req, nil), req.Name, req.Description, time.Now().UTC())
+	category, err := scanCategory(row)
+	if r.dialect.uniqueViolation(err) {
+		return models.Category{}, errCategoryTaken
+	}
+	return category, err
+}
+
+// ListCategories returns every category ordered by slug
+func (r *sqlRepo) ListCategories(ctx context.Context) ([]models.Category, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+categoryColumns+` FROM categories ORDER BY slug`+r.dialect.binaryCollation)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	categories := []models.Category{}
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+// GetCategory fetches a category by id
+func (r *sqlRepo) GetCategory(ctx context.Context, id int64) (models.Category, error) {
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind(`SELECT `+categoryColumns+` FROM categories WHERE id = ?`), id)
+	category, err := scanCategory(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Category{}, errCategoryNotFound
+	}
+	return category, err
+}
+
+// UpdateCategory replaces a category; its blogs are filed by ID and follow
+// it to its new slug, each as its next version
+func (r *sqlRepo) UpdateCategory(ctx context.Context, id int64, req models.CategoryRequest) (models.Category, error) {
+	if err := ValidateCategory(&req); err != nil {
+		return models.Category{}, err
+	}
+	var updated models.Category
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		var old models.Category
+		err := tx.QueryRowContext(ctx, r.dialect.rebind(
+			`SELECT slug, name FROM categories WHERE id = ?`+r.dialect.forUpdate), id).Scan(&old.Slug, &old.Name)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errCategoryNotFound
+		}
+		if err != nil {
+			return err
+		}
+		row := tx.QueryRowContext(ctx, r.dialect.rebind(
+			`UPDATE categories SET slug = ?, name = ?, description = ? WHERE id = ? RETURNING `+categoryColumns),
+			categorySlugFor(req, &old), req.Name, req.Description, id)
+		if updated, err = scanCategory(row); err != nil {
+			return err
+		}
+		if updated.Slug != old.Slug {
+			return r.refile(ctx, tx, id)
+		}
+		return nil
+	})
+	if r.dialect.uniqueViolation(err) {
+		return models.Category{}, errCategoryTaken
+	}
+	if err != nil {
+		return models.Category{}, err
+	}
+	return updated, nil
+}
+
+// DeleteCategory removes a category; it comes off its blogs by cascade,
+// each as its next version
+func (r *sqlRepo) DeleteCategory(ctx context.Context, id int64) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		if err := r.refile(ctx, tx, id); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, r.dialect.rebind(`DELETE FROM categories WHERE id = ?`), id)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errCategoryNotFound
+		}
+		return nil
+	})
+}
+
+// refile bumps the version of every blog, trashed or not, filed under a
+// category whose slug is about to change or which is about to go, and
+// records the revisions, so that their ETags change with their categories
+func (r *sqlRepo) refile(ctx context.Context, tx *sql.Tx, categoryID int64) error {
+	rows, err := tx.QueryContext(ctx, r.dialect.rebind(
+		`UPDATE blogs SET updated_at = ?, version = version + 1
+		WHERE id IN (SELECT blog_id FROM blog_categories WHERE category_id = ?) RETURNING `+blogColumns),
+		time.Now().UTC(), categoryID)
+	if err != nil {
+		return err
+	}
+	var refiled []models.Blog
+	for rows.Next() {
+		blog, err := scanBlog(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		refiled = append(refiled, blog)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, blog := range refiled {
+		if err := r.record(ctx, tx, blog); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateComment adds a pending comment by the user in ctx
+func (r *sqlRepo) CreateComment(ctx context.Context, blogID int64, req models.CommentRequest) (models.Comment, error) {
+	author, ok := AuthorFrom(ctx)
+	if !ok {
+		return models.Comment{}, errAnonymousComment
+	}
+	if err := ValidateComment(&req); err != nil {
+		return models.Comment{}, err
+	}
+	var status models.Status
+	err := r.db.QueryRowContext(ctx, r.dialect.rebind(
+		`SELECT status FROM blogs WHERE id = ? AND deleted_at IS NULL`), blogID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Comment{}, errBlogNotFound
+	}
+	if err != nil {
+		return models.Comment{}, err
+	}
+	if status != models.StatusPublished {
+		return models.Comment{}, errCommentsClosed
+	}
+	if req.ParentID != nil {
+		var found int
+		err := r.db.QueryRowContext(ctx, r.dialect.rebind(
+			`SELECT 1 FROM comments WHERE id = ? AND blog_id = ? AND status = ?`),
+			*req.ParentID, blogID, models.CommentApproved).Scan(&found)
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Comment{}, errBadParent
+		}
+		if err != nil {
+			return models.Comment{}, err
+		}
+	}
+	now := time.Now().UTC()
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind(
+		`INSERT INTO comments (blog_id, parent_id, author_id, author, body, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING `+commentColumns),
+		blogID, req.ParentID, author, ActorFrom(ctx), req.Body, models.CommentPending, now, now)
+	return scanComment(row)
+}
+
+// ListComments returns a page of a blog's approved threads. Only the
+// replies under the page's threads are fetched, walking down from them.
+func (r *sqlRepo) ListComments(ctx context.Context, blogID int64, opts CommentListOptions) (models.CommentList, error) {
+	after, err := opts.normalize()
+	if err != nil {
+		return models.CommentList{}, err
+	}
+	if err := r.blogExists(ctx, blogID); err != nil {
+		return models.CommentList{}, err
+	}
+	var total int
+	err = r.db.QueryRowContext(ctx, r.dialect.rebind(
+		`SELECT COUNT(*) FROM comments WHERE blog_id = ? AND parent_id IS NULL AND status = ?`),
+		blogID, models.CommentApproved).Scan(&total)
+	if err != nil {
+		return models.CommentList{}, err
+	}
+	threads, err := r.queryComments(ctx,
+		`SELECT `+commentColumns+` FROM comments WHERE blog_id = ? AND parent_id IS NULL AND status = ? AND id > ? ORDER BY id LIMIT ?`,
+		blogID, models.CommentApproved, after, opts.Limit+1)
+	if err != nil {
+		return models.CommentList{}, err
+	}
+	page, next := pageComments(threads, opts.Limit)
+	var replies []models.Comment
+	if len(page) > 0 {
+		ids := make([]int64, len(page))
+		for i, thread := range page {
+			ids[i] = thread.ID
+		}
+		replies, err = r.queryComments(ctx, `WITH RECURSIVE thread (id) AS (
+				SELECT id FROM comments WHERE parent_id IN `+placeholders(len(ids))+` AND status = ?
+				UNION ALL
+				SELECT c.id FROM comments c JOIN thread t ON c.parent_id = t.id WHERE c.status = ?
+			)
+			SELECT `+commentColumns+` FROM comments WHERE id IN (SELECT id FROM thread) ORDER BY id`,
+			append(anySlice(ids), models.CommentApproved, models.CommentApproved)...)
+		if err != nil {
+			return models.CommentList{}, err
+		}
+	}
+	return models.CommentList{Items: nest(page, replies), Total: total, NextCursor: next}, nil
+}
+
+// UpdateComment replaces the body of a comment, sending it back to the
+// moderation queue
+func (r *sqlRepo) UpdateComment(ctx context.Context, blogID, id int64, req models.CommentEditRequest) (models.Comment, error) {
+	if err := Validate(&req); err != nil {
+		return models.Comment{}, err
+	}
+	if err := r.blogExists(ctx, blogID); err != nil {
+		return models.Comment{}, err
+	}
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind(
+		`UPDATE comments SET body = ?, status = ?, updated_at = ? WHERE id = ? AND blog_id = ? RETURNING `+commentColumns),
+		req.Body, models.CommentPending, time.Now().UTC(), id, blogID)
+	comment, err := scanComment(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Comment{}, errCommentNotFound
+	}
+	return comment, err
+}
+
+// DeleteComment removes a comment; its replies cascade
+func (r *sqlRepo) DeleteComment(ctx context.Context, blogID, id int64) error {
+	if err := r.blogExists(ctx, blogID); err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx, r.dialect.rebind(`DELETE FROM comments WHERE id = ? AND blog_id = ?`), id, blogID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errCommentNotFound
+	}
+	return nil
+}
+
+// ModerationQueue returns a page of the comments on live blogs
+func (r *sqlRepo) ModerationQueue(ctx context.Context, status models.CommentStatus, opts CommentListOptions) (models.CommentList, error) {
+	if err := checkQueueStatus(status); err != nil {
+		return models.CommentList{}, err
+	}
+	after, err := opts.normalize()
+	if err != nil {
+		return models.CommentList{}, err
+	}
+	filter, args := liveComments, []any(nil)
+	if status != "" {
+		filter += ` AND status = ?`
+		args = append(args, status)
+	}
+	var total int
+	err = r.db.QueryRowContext(ctx, r.dialect.rebind(`SELECT COUNT(*) FROM comments WHERE `+filter), args...).Scan(&total)
+	if err != nil {
+		return models.CommentList{}, err
+	}
+	queued, err := r.queryComments(ctx, `SELECT `+commentColumns+` FROM comments WHERE `+filter+` AND id > ? ORDER BY id LIMIT ?`,
+		append(args, after, opts.Limit+1)...)
+	if err != nil {
+		return models.CommentList{}, err
+	}
+	page, next := pageComments(queued, opts.Limit)
+	return models.CommentList{Items: page, Total: total, NextCursor: next}, nil
+}
+
+// ModerateComment sets the status of a comment on a live blog
+func (r *sqlRepo) ModerateComment(ctx context.Context, id int64, status models.CommentStatus) (models.Comment, error) {
+	if !validCommentStatus(status) {
+		return models.Comment{}, &ValidationError{Fields: []models.FieldError{commentStatusFieldError}}
+	}
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind(
+		`UPDATE comments SET status = ? WHERE id = ? AND `+liveComments+` RETURNING `+commentColumns), status, id)
+	comment, err := scanComment(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Comment{}, errCommentNotFound
+	}
+	return comment, err
+}
+
+// CommentAuthor returns the author_id of a comment
+func (r *sqlRepo) CommentAuthor(ctx context.Context, id int64) (int64, error) {
+	var author int64
+	err := r.db.QueryRowContext(ctx, r.dialect.rebind(`SELECT author_id FROM comments WHERE id = ?`), id).Scan(&author)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, errCommentNotFound
+	}
+	return author, err
+}
+
+// liveComments selects the comments whose blog is not in the trash
+const liveComments = `blog_id IN (SELECT id FROM blogs WHERE deleted_at IS NULL)`
+
+// queryComments runs a query selecting commentColumns
+func (r *sqlRepo) queryComments(ctx context.Context, query string, args ...any) ([]models.Comment, error) {
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var comments []models.Comment
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+// CreateUser registers a new user account
+func (r *sqlRepo) CreateUser(ctx context.Context, req models.RegisterRequest) (models.User, error) {
+	if err := ValidateUser(&req); err != nil {
+		return models.User{}, err
+	}
+	hash, err := hashPassword(req.Password)
+	if err != nil {
+		return models.User{}, err
+	}
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind(
+		`INSERT INTO users (username, name, role, password_hash, created_at) VALUES (?, ?, ?, ?, ?) RETURNING `+userColumns),
+		req.Username, req.Name, models.RoleReader, string(hash), time.Now().UTC())
+	user, err := scanUser(row)
+	if r.dialect.uniqueViolation(err) {
+		return models.User{}, errUsernameTaken
+	}
+	return user, err
+}
+
+// GetUser fetches a user by id
+func (r *sqlRepo) GetUser(ctx context.Context, id int64) (models.User, error) {
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind(`SELECT `+userColumns+` FROM users WHERE id = ?`), id)
+	user, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, errUserNotFound
+	}
+	return user, err
+}
+
+// SetUserRole changes what a user may do
+func (r *sqlRepo) SetUserRole(ctx context.Context, id int64, role models.Role) (models.User, error) {
+	if !ValidRole(role) {
+		return models.User{}, &ValidationError{Fields: []models.FieldError{roleFieldError}}
+	}
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind(`UPDATE users SET role = ? WHERE id = ? RETURNING `+userColumns), role, id)
+	user, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, errUserNotFound
+	}
+	return user, err
+}
+
+// Authenticate returns the user with the given credentials
+func (r *sqlRepo) Authenticate(ctx context.Context, username, password string) (models.User, error) {
+	var hash string
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind(
+		`SELECT `+userColumns+`, password_hash FROM users WHERE username = ?`), NormalizeUsername(username))
+	user, err := scanUser(row, &hash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, err
+	}
+	var stored []byte
+	if hash != "" {
+		stored = []byte(hash)
+	}
+	if err := checkPassword(stored, password); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// CreateAPIKey issues a new API key
+func (r *sqlRepo) CreateAPIKey(ctx context.Context, req models.APIKeyRequest) (models.NewAPIKey, error) {
+	key, hash, err := newAPIKey(ctx, req)
+	if err != nil {
+		return models.NewAPIKey{}, err
+	}
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind(
+		`INSERT INTO api_keys (name, prefix, key_hash, scope, created_by, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING `+apiKeyColumns),
+		key.Name, key.Prefix, hash, key.Scope, key.CreatedBy, key.CreatedAt, key.ExpiresAt)
+	key.APIKey, err = scanAPIKey(row)
+	return key, err
+}
+
+// ListAPIKeys returns every API key, revoked and expired ones included,
+// oldest first
+func (r *sqlRepo) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey stops a key from authenticating
+func (r *sqlRepo) RevokeAPIKey(ctx context.Context, id int64) (models.APIKey, error) {
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind(
+		`UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ? RETURNING `+apiKeyColumns),
+		time.Now().UTC(), id)
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, errAPIKeyNotFound
+	}
+	return key, err
+}
+
+// AuthenticateAPIKey returns the usable key matching key
+func (r *sqlRepo) AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, error) {
+	now := time.Now().UTC()
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind(
+		`UPDATE api_keys SET last_used_at = ?
+		WHERE key_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)
+		RETURNING `+apiKeyColumns),
+		now, hashAPIKey(key), now)
+	found, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, errBadAPIKey
+	}
+	return found, err
+}
+
+// listFilter selects the blogs GetAllBlogs lists: trashed or live ones,
+// optionally in a single status, with a tag or in a category
+func listFilter(opts ListOptions) (string, []any) {
+	filter := `deleted_at IS NULL`
+	if opts.Deleted {
+		filter = `deleted_at IS NOT NULL`
+	}
+	var args []any
+	if opts.Status != "" {
+		filter += ` AND status = ?`
+		args = append(args, opts.Status)
+	}
+	if opts.Tag != "" {
+		filter += ` AND id IN (SELECT blog_id FROM blog_tags WHERE tag = ?)`
+		args = append(args, opts.Tag)
+	}
+	if opts.Category != "" {
+		filter += ` AND id IN (SELECT bc.blog_id FROM blog_categories bc
+			JOIN categories c ON c.id = bc.category_id WHERE c.slug = ?)`
+		args = append(args, opts.Category)
+	}
+	return filter, args
+}
+
+// placeholders returns "(?, ?, ...)" with n placeholders, for IN lists
+func placeholders(n int) string {
+	return `(?` + strings.Repeat(`, ?`, n-1) + `)`
+}
+
+// anySlice converts values into query arguments
+func anySlice[T any](values []T) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+// versionedWhere selects a live blog by id, and by version unless ifVersion
+// is AnyVersion, so the precondition is checked by the write itself
+func versionedWhere(id int64, ifVersion int64) (string, []any) {
+	if ifVersion == AnyVersion {
+		return `id = ? AND deleted_at IS NULL`, []any{id}
+	}
+	return `id = ? AND deleted_at IS NULL AND version = ?`, []any{id, ifVersion}
+}
+
+// writeMissed explains why a versioned write matched no row
+func (r *sqlRepo) writeMissed(ctx context.Context, q querier, id int64, ifVersion int64) error {
+	var version int64
+	err := q.QueryRowContext(ctx, r.dialect.rebind(`SELECT version FROM blogs WHERE id = ? AND deleted_at IS NULL`), id).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errBlogNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return &VersionMismatchError{Expected: ifVersion, Actual: version}
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanBlog(row rowScanner) (models.Blog, error) {
+	var blog models.Blog
+	err := row.Scan(&blog.ID, &blog.Slug, &blog.Title, &blog.Description, &blog.Body, &blog.BodyFormat, &blog.BodyHTML, &blog.CreatedAt, &blog.UpdatedAt, &blog.Version, &blog.Status, &blog.PublishAt, &blog.DeletedAt, &blog.AuthorID)
+	if err != nil {
+		return blog, err
+	}
+	blog.BodyHTML, err = storedHTML(blog.BodyFormat, blog.Body, blog.BodyHTML)
+	return blog, err
+}
+
+func scanRevision(row rowScanner) (models.Revision, error) {
+	var rev models.Revision
+	err := row.Scan(&rev.BlogID, &rev.Version, &rev.Title, &rev.Description, &rev.Body, &rev.BodyFormat, &rev.BodyHTML, &rev.Status, &rev.Author, &rev.CreatedAt)
+	if err != nil {
+		return rev, err
+	}
+	rev.BodyHTML, err = storedHTML(rev.BodyFormat, rev.Body, rev.BodyHTML)
+	return rev, err
+}
+
+// storedHTML returns the body_html of a row, rendering it for rows written
+// before bodies were rendered on write
+func storedHTML(format models.BodyFormat, body, html string) (string, error) {
+	if html != "" || body == "" {
+		return html, nil
+	}
+	return render.HTML(format, body)
+}
+
+// scanUser scans the userColumns, then any extra columns into extra
+func scanUser(row rowScanner, extra ...any) (models.User, error) {
+	var user models.User
+	err := row.Scan(append([]any{&user.ID, &user.Username, &user.Name, &user.Role, &user.CreatedAt}, extra...)...)
+	return user, err
+}
+
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Scope, &key.CreatedBy, &key.CreatedAt, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt)
+	return key, err
+}
+
+func scanCategory(row rowScanner) (models.Category, error) {
+	var category models.Category
+	err := row.Scan(&category.ID, &category.Slug, &category.Name, &category.Description, &category.CreatedAt)
+	return category, err
+}
+
+func scanComment(row rowScanner) (models.Comment, error) {
+	var comment models.Comment
+	err := row.Scan(&comment.ID, &comment.BlogID, &comment.ParentID, &comment.AuthorID, &comment.Author, &comment.Body, &comment.Status, &comment.CreatedAt, &comment.UpdatedAt)
+	return comment, err
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"modernc.org/sqlite" // pure-Go SQLite driver
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var sqliteDialect = dialect{name: "sqlite", driver: "sqlite", likeOp: "LIKE", uniqueViolation: sqliteUniqueViolation}
+
+func sqliteUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+// SQLiteRepo is a BlogStore persisted in a SQLite database file
+type SQLiteRepo struct {
+	*sqlRepo
+}
+
+var _ Store = (*SQLiteRepo)(nil)
+
+// NewSQLiteRepo opens (creating if needed) the SQLite database at path.
+// Run its Migrator before use to create the schema.
+func NewSQLiteRepo(path string) (*SQLiteRepo, error) {
+	dsn := path
+	if !strings.Contains(dsn, "?") {
+		dsn += "?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)&_time_format=sqlite"
+	}
+	conn, err := sql.Open(sqliteDialect.driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; serialising through one connection
+	// avoids SQLITE_BUSY errors under concurrent requests.
+	conn.SetMaxOpenConns(1)
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &SQLiteRepo{&sqlRepo{db: conn, dialect: sqliteDialect}}, nil
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSQLiteRepo(t *testing.T, path string) *SQLiteRepo {
+	r, err := NewSQLiteRepo(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { r.Close() })
+	migrator, err := r.Migrator()
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	return r
+}
+
+func TestSQLiteRepoPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blog.db")
+	r := newSQLiteRepo(t, path)
+	blog := createRandomBlog(t, r)
+	require.NoError(t, r.Close())
+
+	reopened := newSQLiteRepo(t, path)
+	got, err := reopened.GetBlog(ctx, blog.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, blog.Title, got.Title)
+	assert.Equal(t, blog.Body, got.Body)
+}
+
+func TestSQLiteIDsSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blog.db")
+	r := newSQLiteRepo(t, path)
+	createRandomBlog(t, r)
+	last := createRandomBlog(t, r)
+	require.NoError(t, r.DeleteBlog(ctx, last.ID, AnyVersion))
+	require.NoError(t, r.Close())
+
+	reopened := newSQLiteRepo(t, path)
+	next := createRandomBlog(t, reopened)
+	assert.Greater(t, next.ID, last.ID, "ids of deleted posts must not be reused after a restart")
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"blog_post/models"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// transitions lists the statuses a blog in each status may move to.
+// Rescheduling is the only move that keeps the status.
+var transitions = map[models.Status][]models.Status{
+	models.StatusDraft:     {models.StatusScheduled, models.StatusPublished, models.StatusArchived},
+	models.StatusScheduled: {models.StatusDraft, models.StatusScheduled, models.StatusPublished, models.StatusArchived},
+	models.StatusPublished: {models.StatusDraft, models.StatusArchived},
+	models.StatusArchived:  {models.StatusDraft, models.StatusPublished},
+}
+
+// CanTransition reports whether a blog in status from may move to status to
+func CanTransition(from, to models.Status) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// sourcesOf lists the statuses from which a blog may move to status to
+func sourcesOf(to models.Status) []models.Status {
+	var from []models.Status
+	for _, s := range []models.Status{
+		models.StatusDraft, models.StatusScheduled, models.StatusPublished, models.StatusArchived,
+	} {
+		if CanTransition(s, to) {
+			from = append(from, s)
+		}
+	}
+	return from
+}
+
+func validStatus(s models.Status) bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+var statusFieldError = models.FieldError{Field: "status", Message: "must be one of draft, scheduled, published, archived"}
+
+// publishAtFor validates a transition request and returns the publish_at
+// the blog ends up with: the requested time when scheduling, now when
+// publishing, none for drafts, and the old value when archiving
+func publishAtFor(to models.Status, publishAt *time.Time, current *time.Time, now time.Time) (*time.Time, error) {
+	if !validStatus(to) {
+		return nil, &ValidationError{Fields: []models.FieldError{statusFieldError}}
+	}
+	if to != models.StatusScheduled && publishAt != nil {
+		return nil, &ValidationError{Fields: []models.FieldError{
+			{Field: "publish_at", Message: "is only allowed when scheduling"},
+		}}
+	}
+	switch to {
+	case models.StatusScheduled:
+		if publishAt == nil {
+			return nil, &ValidationError{Fields: []models.FieldError{{Field: "publish_at", Message: "is required"}}}
+		}
+		if !publishAt.After(now) {
+			return nil, &ValidationError{Fields: []models.FieldError{{Field: "publish_at", Message: "must be in the future"}}}
+		}
+		at := publishAt.UTC()
+		return &at, nil
+	case models.StatusPublished:
+		return &now, nil
+	case models.StatusArchived:
+		return current, nil
+	}
+	return nil, nil
+}
+
+// transitionError reports a move the lifecycle forbids
+func transitionError(from, to models.Status) error {
+	return &ConflictError{Reason: fmt.Sprintf("cannot move a %s blog to %s", from, to)}
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"blog_post/models"
+	"context"
+	"time"
+)
+
+// AnyVersion makes UpdateBlog and DeleteBlog unconditional
+const AnyVersion int64 = 0
+
+// BlogStore is the storage contract used by the API handlers. Every write
+// bumps models.Blog.Version; UpdateBlog and DeleteBlog only apply when the
+// stored version equals ifVersion (unless it is AnyVersion), checked
+// atomically with the write.
+//
+// Every write, trashing and restoring included, also records an immutable
+// models.Revision holding the new content, the actor from ActorFrom and
+// the time of the write. The revision shares its number with the blog
+// version it produced; revisions go away with their blog.
+//
+// DeleteBlog only moves a blog to the trash: it keeps its ID and revisions
+// but every other method treats it as missing until RestoreBlog brings it
+// back. PurgeBlog and PurgeDeleted remove trashed blogs for good; they
+// refuse to touch live ones.
+//
+// Every blog has a unique slug, derived from its title unless the request
+// names one. A title change derives a new slug; the old one keeps resolving
+// through GetBlogBySlug until another blog claims it.
+//
+// CreateBlog records the user from AuthorFrom, if any, as the blog's
+// author; the author never changes afterwards.
+//
+// Writes replace a blog's tags and categories with the normalized ones of
+// the request; naming a category that does not exist is a validation
+// error. Tags and categories are not part of revisions.
+//
+// New blogs start as drafts. TransitionBlog moves a blog through its
+// lifecycle (see CanTransition) and, like every other edit, bumps the
+// version and records a revision. PublishDue publishes scheduled blogs
+// whose publish_at has passed.
+type BlogStore interface {
+	GetAllBlogs(ctx context.Context, opts ListOptions) (models.BlogList, error)
+	SearchBlogs(ctx context.Context, query string, opts SearchOptions) (models.SearchResults, error)
+	GetBlog(ctx context.Context, id int64) (models.Blog, error)
+	GetBlogBySlug(ctx context.Context, slug string) (models.Blog, error)
+	CreateBlog(ctx context.Context, blog models.BlogRequestBody) (models.Blog, error)
+	UpdateBlog(ctx context.Context, id int64, blog models.BlogRequestBody, ifVersion int64) (models.Blog, error)
+	DeleteBlog(ctx context.Context, id int64, ifVersion int64) error
+	TransitionBlog(ctx context.Context, id int64, to models.Status, publishAt *time.Time, ifVersion int64) (models.Blog, error)
+	PublishDue(ctx context.Context, now time.Time) (int, error)
+	RestoreBlog(ctx context.Context, id int64) (models.Blog, error)
+	PurgeBlog(ctx context.Context, id int64) error
+	PurgeDeleted(ctx context.Context, cutoff time.Time) (int, error)
+	ListRevisions(ctx context.Context, id int64) ([]models.Revision, error)
+	GetRevision(ctx context.Context, id int64, version int64) (models.Revision, error)
+	// BlogAuthor returns the author_id of a blog, trashed or not, or 0 for
+	// an anonymous one
+	BlogAuthor(ctx context.Context, id int64) (int64, error)
+	// TagCounts counts the live blogs carrying each tag, only those in
+	// status unless it is empty, most used tag first
+	TagCounts(ctx context.Context, status models.Status) ([]models.TagCount, error)
+}
+
+// CategoryStore keeps the categories blogs are filed under. Slugs are
+// unique; a category keeps its slug until the request names another one or
+// its name changes. Deleting a category takes it off its blogs. Both a new
+// slug and a deletion are writes of every blog filed under the category:
+// each gets its next version and revision, so that ETags follow categories.
+type CategoryStore interface {
+	CreateCategory(ctx context.Context, req models.CategoryRequest) (models.Category, error)
+	// ListCategories returns every category ordered by slug
+	ListCategories(ctx context.Context) ([]models.Category, error)
+	GetCategory(ctx context.Context, id int64) (models.Category, error)
+	UpdateCategory(ctx context.Context, id int64, req models.CategoryRequest) (models.Category, error)
+	DeleteCategory(ctx context.Context, id int64) error
+}
+
+// CommentStore keeps the comments users leave on blogs. Comments are
+// written by the user from AuthorFrom, and only on published blogs; a
+// reply names an approved comment on the same blog as its parent.
+//
+// New and edited comments wait in the moderation queue as pending until
+// ModerateComment approves them or marks them spam. Only approved comments
+// are listed on their blog, and a reply only while its parent is.
+// Deleting a comment deletes its replies.
+//
+// Comments follow their blog: while it is in the trash they are treated as
+// missing and left out of the moderation queue, RestoreBlog brings them
+// back and purging the blog removes them for good.
+type CommentStore interface {
+	CreateComment(ctx context.Context, blogID int64, req models.CommentRequest) (models.Comment, error)
+	// ListComments returns a page of a blog's approved top-level comments,
+	// oldest first, each with its approved replies nested in it
+	ListComments(ctx context.Context, blogID int64, opts CommentListOptions) (models.CommentList, error)
+	UpdateComment(ctx context.Context, blogID, id int64, req models.CommentEditRequest) (models.Comment, error)
+	DeleteComment(ctx context.Context, blogID, id int64) error
+	// ModerationQueue returns a page of comments across blogs, oldest first
+	// and without nesting, only those in status unless it is empty
+	ModerationQueue(ctx context.Context, status models.CommentStatus, opts CommentListOptions) (models.CommentList, error)
+	ModerateComment(ctx context.Context, id int64, status models.CommentStatus) (models.Comment, error)
+	// CommentAuthor returns the author_id of a comment, whether or not its
+	// blog is in the trash
+	CommentAuthor(ctx context.Context, id int64) (int64, error)
+}
+
+// UserStore keeps the accounts that sign in and author blogs. Usernames are
+// unique after NormalizeUsername; passwords are only ever stored as bcrypt
+// hashes. New users are readers until SetUserRole grants them more.
+type UserStore interface {
+	CreateUser(ctx context.Context, req models.RegisterRequest) (models.User, error)
+	GetUser(ctx context.Context, id int64) (models.User, error)
+	SetUserRole(ctx context.Context, id int64, role models.Role) (models.User, error)
+	// Authenticate returns the user with the given credentials, or an error
+	// matching ErrUnauthorized that does not say which one was wrong
+	Authenticate(ctx context.Context, username, password string) (models.User, error)
+}
+
+// APIKeyStore keeps the keys machine clients authenticate with. Keys are
+// only ever stored as SHA-256 hashes; CreateAPIKey is the one place the key
+// itself is returned. Revoked and expired keys stay listed but no longer
+// authenticate, and revoking a key twice keeps the first revocation time.
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, req models.APIKeyRequest) (models.NewAPIKey, error)
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) (models.APIKey, error)
+	// AuthenticateAPIKey returns the usable key matching key and records
+	// the time it was used, or an error matching ErrUnauthorized
+	AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, error)
+}
+
+// Store is a storage backend for everything the service keeps
+type Store interface {
+	BlogStore
+	CategoryStore
+	CommentStore
+	UserStore
+	APIKeyStore
+}
@@ -0,0 +1,134 @@
+package storetest
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RunAPIKeys executes the db.APIKeyStore conformance suite against stores
+// built by newStore
+func RunAPIKeys(t *testing.T, newStore StoreFactory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, store db.Store)
+	}{
+		{"Create", testCreateAPIKey},
+		{"Invalid Requests", testAPIKeyValidation},
+		{"Authenticate", testAuthenticateAPIKey},
+		{"Revoke", testRevokeAPIKey},
+		{"Expiry", testAPIKeyExpiry},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.fn(t, newStore(t))
+		})
+	}
+}
+
+func createKey(t *testing.T, store db.APIKeyStore, req models.APIKeyRequest) models.NewAPIKey {
+	t.Helper()
+	key, err := store.CreateAPIKey(ctx, req)
+	require.NoError(t, err)
+	return key
+}
+
+func testCreateAPIKey(t *testing.T, store db.Store) {
+	alice := register(t, store, "alice")
+	key, err := store.CreateAPIKey(db.WithAuthor(ctx, alice.ID), models.APIKeyRequest{Name: " ci-importer ", Scope: models.ScopeWrite})
+	require.NoError(t, err)
+	assert.Positive(t, key.ID)
+	assert.Equal(t, "ci-importer", key.Name)
+	assert.Equal(t, models.ScopeWrite, key.Scope)
+	assert.True(t, strings.HasPrefix(key.Key, key.Prefix), "the prefix is the start of the key")
+	assert.Greater(t, len(key.Key), len(key.Prefix)+32)
+	require.NotNil(t, key.CreatedBy)
+	assert.Equal(t, alice.ID, *key.CreatedBy)
+	assert.False(t, key.CreatedAt.IsZero())
+	assert.Nil(t, key.ExpiresAt)
+
+	other := createKey(t, store, models.APIKeyRequest{Name: "ci-importer", Scope: models.ScopeRead})
+	assert.NotEqual(t, key.Key, other.Key)
+	assert.Nil(t, other.CreatedBy)
+
+	keys, err := store.ListAPIKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, key.ID, keys[0].ID)
+	assert.Equal(t, key.Prefix, keys[0].Prefix)
+	assert.Equal(t, other.ID, keys[1].ID)
+}
+
+func testAPIKeyValidation(t *testing.T, store db.Store) {
+	past := time.Now().Add(-time.Minute)
+	_, err := store.CreateAPIKey(ctx, models.APIKeyRequest{Name: " ", Scope: "root", ExpiresAt: &past})
+	var validationErr *db.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	fields := make([]string, len(validationErr.Fields))
+	for i, f := range validationErr.Fields {
+		fields[i] = f.Field
+	}
+	assert.Equal(t, []string{"name", "scope", "expires_at"}, fields, "every bad field is reported")
+
+	keys, err := store.ListAPIKeys(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func testAuthenticateAPIKey(t *testing.T, store db.Store) {
+	key := createKey(t, store, models.APIKeyRequest{Name: "ci-importer", Scope: models.ScopeWrite})
+	assert.Nil(t, key.LastUsedAt)
+
+	got, err := store.AuthenticateAPIKey(ctx, key.Key)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, got.ID)
+	assert.Equal(t, models.ScopeWrite, got.Scope)
+	require.NotNil(t, got.LastUsedAt, "use is recorded")
+
+	keys, err := store.ListAPIKeys(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, keys[0].LastUsedAt)
+
+	for _, wrong := range []string{"", key.Prefix, key.Key + "x", strings.ToUpper(key.Key)} {
+		_, err := store.AuthenticateAPIKey(ctx, wrong)
+		assert.ErrorIs(t, err, db.ErrUnauthorized, wrong)
+	}
+}
+
+func testRevokeAPIKey(t *testing.T, store db.Store) {
+	key := createKey(t, store, models.APIKeyRequest{Name: "ci-importer", Scope: models.ScopeAdmin})
+	revoked, err := store.RevokeAPIKey(ctx, key.ID)
+	require.NoError(t, err)
+	require.NotNil(t, revoked.RevokedAt)
+	_, err = store.AuthenticateAPIKey(ctx, key.Key)
+	assert.ErrorIs(t, err, db.ErrUnauthorized)
+
+	again, err := store.RevokeAPIKey(ctx, key.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked.RevokedAt.Equal(*again.RevokedAt), "revoking twice keeps the first time")
+
+	keys, err := store.ListAPIKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 1, "revoked keys stay listed")
+	assert.NotNil(t, keys[0].RevokedAt)
+
+	_, err = store.RevokeAPIKey(ctx, 9999)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+}
+
+func testAPIKeyExpiry(t *testing.T, store db.Store) {
+	expires := time.Now().Add(300 * time.Millisecond)
+	key := createKey(t, store, models.APIKeyRequest{Name: "nightly", Scope: models.ScopeRead, ExpiresAt: &expires})
+	require.NotNil(t, key.ExpiresAt)
+	_, err := store.AuthenticateAPIKey(ctx, key.Key)
+	require.NoError(t, err)
+
+	time.Sleep(400 * time.Millisecond)
+	_, err = store.AuthenticateAPIKey(ctx, key.Key)
+	assert.ErrorIs(t, err, db.ErrUnauthorized)
+}
@@ -0,0 +1,252 @@
+package storetest
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RunCategories executes the db.CategoryStore conformance suite, including
+// filing blogs under categories, against stores built by newStore
+func RunCategories(t *testing.T, newStore StoreFactory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, store db.Store)
+	}{
+		{"Create", testCreateCategory},
+		{"Invalid Requests", testCategoryValidation},
+		{"Update", testUpdateCategory},
+		{"Delete", testDeleteCategory},
+		{"Filing Blogs", testFileBlogs},
+		{"Refiling Versions", testRefileVersions},
+		{"Category Filter", testCategoryFilter},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.fn(t, newStore(t))
+		})
+	}
+}
+
+func createCategory(t *testing.T, store db.CategoryStore, name string) models.Category {
+	t.Helper()
+	category, err := store.CreateCategory(ctx, models.CategoryRequest{Name: name})
+	require.NoError(t, err)
+	return category
+}
+
+// filed creates a blog in the given categories
+func filed(t *testing.T, store db.BlogStore, n int, categories ...string) models.Blog {
+	t.Helper()
+	req := request(n)
+	req.Categories = categories
+	blog, err := store.CreateBlog(ctx, req)
+	require.NoError(t, err)
+	return blog
+}
+
+func testCreateCategory(t *testing.T, store db.Store) {
+	categories, err := store.ListCategories(ctx)
+	require.NoError(t, err)
+	assert.NotNil(t, categories, "no categories list as [] rather than null")
+	assert.Empty(t, categories)
+
+	tutorials, err := store.CreateCategory(ctx, models.CategoryRequest{Name: " Step-by-step Tutorials ", Description: "Guides"})
+	require.NoError(t, err)
+	assert.Positive(t, tutorials.ID)
+	assert.Equal(t, "step-by-step-tutorials", tutorials.Slug, "slugs are derived from the name")
+	assert.Equal(t, "Step-by-step Tutorials", tutorials.Name, "names are trimmed")
+	assert.Equal(t, "Guides", tutorials.Description)
+	assert.False(t, tutorials.CreatedAt.IsZero())
+	news, err := store.CreateCategory(ctx, models.CategoryRequest{Name: "News", Slug: "announcements"})
+	require.NoError(t, err)
+	assert.Equal(t, "announcements", news.Slug)
+
+	got, err := store.GetCategory(ctx, tutorials.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tutorials.Slug, got.Slug)
+	assert.Equal(t, tutorials.Name, got.Name)
+	categories, err = store.ListCategories(ctx)
+	require.NoError(t, err)
+	require.Len(t, categories, 2)
+	assert.Equal(t, []int64{news.ID, tutorials.ID}, []int64{categories[0].ID, categories[1].ID}, "ordered by slug")
+
+	_, err = store.CreateCategory(ctx, models.CategoryRequest{Name: "News", Slug: "announcements"})
+	assert.ErrorIs(t, err, db.ErrConflict)
+	_, err = store.CreateCategory(ctx, models.CategoryRequest{Name: "Step-by-step tutorials"})
+	assert.ErrorIs(t, err, db.ErrConflict, "derived slugs clash too")
+	_, err = store.GetCategory(ctx, news.ID+100)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+}
+
+func testCategoryValidation(t *testing.T, store db.Store) {
+	_, err := store.CreateCategory(ctx, models.CategoryRequest{Name: "  ", Slug: "Not A Slug"})
+	var validationErr *db.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Len(t, validationErr.Fields, 2, "every bad field is reported")
+	assert.Equal(t, models.FieldError{Field: "name", Message: "is required"}, validationErr.Fields[0])
+	assert.Equal(t, "slug", validationErr.Fields[1].Field)
+
+	category := createCategory(t, store, "News")
+	_, err = store.UpdateCategory(ctx, category.ID, models.CategoryRequest{Name: "News\nFlash"})
+	assert.ErrorIs(t, err, db.ErrValidation)
+}
+
+func testUpdateCategory(t *testing.T, store db.Store) {
+	category, err := store.CreateCategory(ctx, models.CategoryRequest{Name: "Tutorials", Slug: "tuts"})
+	require.NoError(t, err)
+	taken := createCategory(t, store, "News")
+
+	updated, err := store.UpdateCategory(ctx, category.ID, models.CategoryRequest{Name: "Tutorials", Description: "Guides"})
+	require.NoError(t, err)
+	assert.Equal(t, "tuts", updated.Slug, "the slug is kept while the name is")
+	assert.Equal(t, "Guides", updated.Description)
+	updated, err = store.UpdateCategory(ctx, category.ID, models.CategoryRequest{Name: "How-tos"})
+	require.NoError(t, err)
+	assert.Equal(t, "how-tos", updated.Slug, "a new name derives a new slug")
+	assert.Empty(t, updated.Description, "updates replace the whole category")
+
+	_, err = store.UpdateCategory(ctx, category.ID, models.CategoryRequest{Name: "How-tos", Slug: taken.Slug})
+	assert.ErrorIs(t, err, db.ErrConflict)
+	got, err := store.GetCategory(ctx, category.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "how-tos", got.Slug, "failed updates change nothing")
+
+	_, err = store.UpdateCategory(ctx, taken.ID+100, models.CategoryRequest{Name: "Other"})
+	assert.ErrorIs(t, err, db.ErrNotFound)
+}
+
+func testDeleteCategory(t *testing.T, store db.Store) {
+	news := createCategory(t, store, "News")
+	tutorials := createCategory(t, store, "Tutorials")
+	blog := filed(t, store, 1, news.Slug, tutorials.Slug)
+
+	require.NoError(t, store.DeleteCategory(ctx, news.ID))
+	_, err := store.GetCategory(ctx, news.ID)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	assert.ErrorIs(t, store.DeleteCategory(ctx, news.ID), db.ErrNotFound)
+
+	got, err := store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{tutorials.Slug}, got.Categories, "deleted categories come off their blogs")
+	assert.Equal(t, blog.Version+1, got.Version, "losing a category is a new version")
+	page, err := store.GetAllBlogs(ctx, db.ListOptions{Category: news.Slug})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+
+	again := createCategory(t, store, "News")
+	assert.Equal(t, news.Slug, again.Slug, "the slug of a deleted category is free again")
+	got, err = store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{tutorials.Slug}, got.Categories, "a new category does not inherit blogs")
+}
+
+func testFileBlogs(t *testing.T, store db.Store) {
+	news := createCategory(t, store, "News")
+	createCategory(t, store, "Tutorials")
+
+	blog := filed(t, store, 1, " Tutorials ", "news", "news")
+	assert.Equal(t, []string{"news", "tutorials"}, blog.Categories, "categories are normalized, deduplicated and sorted")
+	got, err := store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assert.Equal(t, blog.Categories, got.Categories)
+
+	req := request(1)
+	req.Categories = []string{"news", "missing", "gone"}
+	_, err = store.UpdateBlog(ctx, blog.ID, req, db.AnyVersion)
+	var validationErr *db.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []models.FieldError{{Field: "categories", Message: "names unknown categories: gone, missing"}}, validationErr.Fields)
+	got, err = store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assert.Equal(t, blog.Version, got.Version, "failed writes change nothing")
+	assert.Equal(t, blog.Categories, got.Categories)
+	_, err = store.CreateBlog(ctx, req)
+	assert.ErrorIs(t, err, db.ErrValidation)
+
+	req.Categories = []string{"news"}
+	updated, err := store.UpdateBlog(ctx, blog.ID, req, db.AnyVersion)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"news"}, updated.Categories, "writes replace the categories")
+
+	renamed, err := store.UpdateCategory(ctx, news.ID, models.CategoryRequest{Name: "Announcements"})
+	require.NoError(t, err)
+	got, err = store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{renamed.Slug}, got.Categories, "blogs follow their category to its new slug")
+	assert.Equal(t, updated.Version+1, got.Version)
+	results, err := store.SearchBlogs(ctx, "title", db.SearchOptions{})
+	require.NoError(t, err)
+	require.Len(t, results.Items, 1)
+	assert.Equal(t, []string{renamed.Slug}, results.Items[0].Categories)
+}
+
+func testCategoryFilter(t *testing.T, store db.Store) {
+	createCategory(t, store, "News")
+	createCategory(t, store, "Tutorials")
+	first := filed(t, store, 1, "tutorials")
+	both := filed(t, store, 2, "news", "tutorials")
+	req := request(3)
+	req.Categories, req.Tags = []string{"news"}, []string{"go"}
+	news, err := store.CreateBlog(ctx, req)
+	require.NoError(t, err)
+	req = request(4)
+	req.Categories, req.Tags = []string{"tutorials"}, []string{"go"}
+	goTutorial, err := store.CreateBlog(ctx, req)
+	require.NoError(t, err)
+
+	page, err := store.GetAllBlogs(ctx, db.ListOptions{Category: "Tutorials"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{first.ID, both.ID, goTutorial.ID}, ids(page.Items))
+	assert.Equal(t, 3, page.Total)
+	page, err = store.GetAllBlogs(ctx, db.ListOptions{Category: "news"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{both.ID, news.ID}, ids(page.Items))
+	page, err = store.GetAllBlogs(ctx, db.ListOptions{Category: "tutorials", Tag: "go"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{goTutorial.ID}, ids(page.Items), "tag and category filters combine")
+	assert.Equal(t, 1, page.Total)
+}
+
+func testRefileVersions(t *testing.T, store db.Store) {
+	news := createCategory(t, store, "News")
+	blog := filed(t, store, 1, news.Slug)
+	trashed := filed(t, store, 2, news.Slug)
+	other := create(t, store, 3)
+	require.NoError(t, store.DeleteBlog(ctx, trashed.ID, db.AnyVersion))
+
+	_, err := store.UpdateCategory(ctx, news.ID, models.CategoryRequest{Name: "News", Description: "Updates"})
+	require.NoError(t, err)
+	got, err := store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assert.Equal(t, blog.Version, got.Version, "keeping the slug changes no blog")
+
+	_, err = store.UpdateCategory(ctx, news.ID, models.CategoryRequest{Name: "Announcements"})
+	require.NoError(t, err)
+	got, err = store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"announcements"}, got.Categories)
+	assert.Equal(t, blog.Version+1, got.Version, "a renamed category is a new version of its blogs")
+	revision, err := store.GetRevision(ctx, blog.ID, got.Version)
+	require.NoError(t, err, "every version has its revision")
+	assert.Equal(t, got.Body, revision.Body)
+	_, err = store.UpdateBlog(ctx, blog.ID, request(4), blog.Version)
+	assert.ErrorIs(t, err, db.ErrPreconditionFailed, "versions from before the rename are stale")
+
+	require.NoError(t, store.DeleteCategory(ctx, news.ID))
+	deleted, err := store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assert.Empty(t, deleted.Categories)
+	assert.Equal(t, got.Version+1, deleted.Version, "a deleted category is a new version of its blogs")
+
+	restored, err := store.RestoreBlog(ctx, trashed.ID)
+	require.NoError(t, err)
+	assert.Empty(t, restored.Categories)
+	assert.Equal(t, trashed.Version+4, restored.Version, "trashed blogs are refiled too")
+	unfiled, err := store.GetBlog(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, other.Version, unfiled.Version, "blogs in other categories keep their versions")
+}
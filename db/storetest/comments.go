@@ -0,0 +1,336 @@
+package storetest
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RunComments executes the db.CommentStore conformance suite against
+// stores built by newStore
+func RunComments(t *testing.T, newStore StoreFactory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, store db.Store)
+	}{
+		{"Create", testCreateComment},
+		{"Invalid Requests", testCommentValidation},
+		{"Threads", testCommentThreads},
+		{"Thread Pagination", testCommentPagination},
+		{"Edit", testEditComment},
+		{"Delete", testDeleteComment},
+		{"Moderation Queue", testModerationQueue},
+		{"Blog Deletion", testCommentsFollowBlog},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.fn(t, newStore(t))
+		})
+	}
+}
+
+// commenter returns a context writing as a newly registered user
+func commenter(t *testing.T, store db.UserStore, username string) (context.Context, models.User) {
+	t.Helper()
+	user := register(t, store, username)
+	return db.WithAuthor(db.WithActor(ctx, user.Username), user.ID), user
+}
+
+// publishedBlog creates a blog open for comments
+func publishedBlog(t *testing.T, store db.BlogStore, n int) models.Blog {
+	t.Helper()
+	blog, err := store.CreateBlog(ctx, request(n))
+	require.NoError(t, err)
+	blog, err = store.TransitionBlog(ctx, blog.ID, models.StatusPublished, nil, db.AnyVersion)
+	require.NoError(t, err)
+	return blog
+}
+
+// approved creates a comment, replying to parent unless it is nil, and
+// approves it
+func approved(t *testing.T, store db.CommentStore, as context.Context, blogID int64, body string, parent *models.Comment) models.Comment {
+	t.Helper()
+	req := models.CommentRequest{Body: body}
+	if parent != nil {
+		req.ParentID = &parent.ID
+	}
+	comment, err := store.CreateComment(as, blogID, req)
+	require.NoError(t, err)
+	comment, err = store.ModerateComment(ctx, comment.ID, models.CommentApproved)
+	require.NoError(t, err)
+	return comment
+}
+
+func commentIDs(comments []models.Comment) []int64 {
+	ids := make([]int64, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+	return ids
+}
+
+func testCreateComment(t *testing.T, store db.Store) {
+	alice, user := commenter(t, store, "alice")
+	blog := publishedBlog(t, store, 1)
+
+	comment, err := store.CreateComment(alice, blog.ID, models.CommentRequest{Body: "  First!\n\nGreat post.  "})
+	require.NoError(t, err)
+	assert.Positive(t, comment.ID)
+	assert.Equal(t, blog.ID, comment.BlogID)
+	assert.Nil(t, comment.ParentID)
+	assert.Equal(t, user.ID, comment.AuthorID)
+	assert.Equal(t, "alice", comment.Author)
+	assert.Equal(t, "First!\n\nGreat post.", comment.Body, "bodies are trimmed")
+	assert.Equal(t, models.CommentPending, comment.Status, "comments wait for moderation")
+	assert.False(t, comment.CreatedAt.IsZero())
+	assert.Equal(t, comment.CreatedAt, comment.UpdatedAt)
+	author, err := store.CommentAuthor(ctx, comment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, author)
+
+	_, err = store.CreateComment(ctx, blog.ID, models.CommentRequest{Body: "Anonymous"})
+	assert.ErrorIs(t, err, db.ErrForbidden, "only users may comment")
+	_, err = store.CreateComment(alice, blog.ID+100, models.CommentRequest{Body: "Nowhere"})
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	draft, err := store.CreateBlog(ctx, request(2))
+	require.NoError(t, err)
+	_, err = store.CreateComment(alice, draft.ID, models.CommentRequest{Body: "Too early"})
+	assert.ErrorIs(t, err, db.ErrConflict, "drafts take no comments")
+	_, err = store.CommentAuthor(ctx, comment.ID+100)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+}
+
+func testCommentValidation(t *testing.T, store db.Store) {
+	alice, _ := commenter(t, store, "alice")
+	blog := publishedBlog(t, store, 1)
+	other := publishedBlog(t, store, 2)
+
+	_, err := store.CreateComment(alice, blog.ID, models.CommentRequest{Body: " \n "})
+	var validationErr *db.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []models.FieldError{{Field: "body", Message: "is required"}}, validationErr.Fields)
+	_, err = store.CreateComment(alice, blog.ID, models.CommentRequest{Body: "Bell\a"})
+	assert.ErrorIs(t, err, db.ErrValidation)
+
+	pending, err := store.CreateComment(alice, blog.ID, models.CommentRequest{Body: "Pending"})
+	require.NoError(t, err)
+	elsewhere := approved(t, store, alice, other.ID, "Elsewhere", nil)
+	missing := elsewhere.ID + 100
+	for name, parentID := range map[string]int64{
+		"pending parent":          pending.ID,
+		"parent on another blog":  elsewhere.ID,
+		"parent that is not here": missing,
+	} {
+		_, err := store.CreateComment(alice, blog.ID, models.CommentRequest{Body: "Reply", ParentID: &parentID})
+		require.ErrorAs(t, err, &validationErr, name)
+		assert.Equal(t, "parent_id", validationErr.Fields[0].Field, name)
+	}
+
+	_, err = store.UpdateComment(alice, blog.ID, pending.ID, models.CommentEditRequest{Body: ""})
+	assert.ErrorIs(t, err, db.ErrValidation)
+	_, err = store.ModerateComment(ctx, pending.ID, "deleted")
+	assert.ErrorIs(t, err, db.ErrValidation)
+	_, err = store.ModerationQueue(ctx, "deleted", db.CommentListOptions{})
+	assert.ErrorIs(t, err, db.ErrValidation)
+	_, err = store.ListComments(ctx, blog.ID, db.CommentListOptions{After: "not a cursor"})
+	assert.ErrorIs(t, err, db.ErrValidation)
+}
+
+func testCommentThreads(t *testing.T, store db.Store) {
+	alice, _ := commenter(t, store, "alice")
+	bob, _ := commenter(t, store, "bob")
+	blog := publishedBlog(t, store, 1)
+	other := publishedBlog(t, store, 2)
+
+	list, err := store.ListComments(ctx, blog.ID, db.CommentListOptions{})
+	require.NoError(t, err)
+	assert.NotNil(t, list.Items, "no comments list as [] rather than null")
+	assert.Empty(t, list.Items)
+	assert.Zero(t, list.Total)
+
+	first := approved(t, store, alice, blog.ID, "First", nil)
+	reply := approved(t, store, bob, blog.ID, "Reply", &first)
+	nested := approved(t, store, alice, blog.ID, "Nested reply", &reply)
+	second := approved(t, store, bob, blog.ID, "Second", nil)
+	_, err = store.CreateComment(bob, blog.ID, models.CommentRequest{Body: "Pending reply", ParentID: &first.ID})
+	require.NoError(t, err)
+	spam := approved(t, store, bob, blog.ID, "Spam", nil)
+	approved(t, store, alice, blog.ID, "Reply to spam", &spam)
+	_, err = store.ModerateComment(ctx, spam.ID, models.CommentSpam)
+	require.NoError(t, err)
+	approved(t, store, alice, other.ID, "On another blog", nil)
+
+	list, err = store.ListComments(ctx, blog.ID, db.CommentListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, list.Total, "only approved threads count")
+	assert.Empty(t, list.NextCursor)
+	require.Equal(t, []int64{first.ID, second.ID}, commentIDs(list.Items), "threads are oldest first")
+	require.Equal(t, []int64{reply.ID}, commentIDs(list.Items[0].Replies), "pending replies are hidden")
+	assert.Equal(t, first.ID, *list.Items[0].Replies[0].ParentID)
+	require.Equal(t, []int64{nested.ID}, commentIDs(list.Items[0].Replies[0].Replies))
+	assert.Equal(t, "Nested reply", list.Items[0].Replies[0].Replies[0].Body)
+	assert.Empty(t, list.Items[1].Replies)
+
+	_, err = store.ModerateComment(ctx, reply.ID, models.CommentPending)
+	require.NoError(t, err)
+	list, err = store.ListComments(ctx, blog.ID, db.CommentListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, list.Items[0].Replies, "replies are hidden with their parent")
+}
+
+func testCommentPagination(t *testing.T, store db.Store) {
+	alice, _ := commenter(t, store, "alice")
+	blog := publishedBlog(t, store, 1)
+	var threads []int64
+	for i := 0; i < 5; i++ {
+		thread := approved(t, store, alice, blog.ID, "Thread", nil)
+		approved(t, store, alice, blog.ID, "Reply", &thread)
+		threads = append(threads, thread.ID)
+	}
+
+	var seen []int64
+	opts := db.CommentListOptions{Limit: 2}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3)
+		list, err := store.ListComments(ctx, blog.ID, opts)
+		require.NoError(t, err)
+		assert.Equal(t, 5, list.Total)
+		for _, thread := range list.Items {
+			assert.Len(t, thread.Replies, 1, "every thread on a page carries its replies")
+		}
+		seen = append(seen, commentIDs(list.Items)...)
+		if list.NextCursor == "" {
+			break
+		}
+		opts.After = list.NextCursor
+	}
+	assert.Equal(t, threads, seen)
+}
+
+func testEditComment(t *testing.T, store db.Store) {
+	alice, _ := commenter(t, store, "alice")
+	blog := publishedBlog(t, store, 1)
+	other := publishedBlog(t, store, 2)
+	comment := approved(t, store, alice, blog.ID, "Tpyo", nil)
+	time.Sleep(10 * time.Millisecond)
+
+	edited, err := store.UpdateComment(alice, blog.ID, comment.ID, models.CommentEditRequest{Body: " Typo "})
+	require.NoError(t, err)
+	assert.Equal(t, "Typo", edited.Body)
+	assert.Equal(t, models.CommentPending, edited.Status, "edits are moderated again")
+	assert.True(t, edited.UpdatedAt.After(comment.UpdatedAt))
+	assert.Equal(t, comment.CreatedAt.Unix(), edited.CreatedAt.Unix())
+	list, err := store.ListComments(ctx, blog.ID, db.CommentListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, list.Items)
+
+	_, err = store.UpdateComment(alice, other.ID, comment.ID, models.CommentEditRequest{Body: "Wrong blog"})
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	_, err = store.UpdateComment(alice, blog.ID, comment.ID+100, models.CommentEditRequest{Body: "Missing"})
+	assert.ErrorIs(t, err, db.ErrNotFound)
+}
+
+func testDeleteComment(t *testing.T, store db.Store) {
+	alice, _ := commenter(t, store, "alice")
+	blog := publishedBlog(t, store, 1)
+	other := publishedBlog(t, store, 2)
+	thread := approved(t, store, alice, blog.ID, "Thread", nil)
+	reply := approved(t, store, alice, blog.ID, "Reply", &thread)
+	nested := approved(t, store, alice, blog.ID, "Nested", &reply)
+	kept := approved(t, store, alice, blog.ID, "Kept", nil)
+
+	assert.ErrorIs(t, store.DeleteComment(ctx, other.ID, thread.ID), db.ErrNotFound, "comments are deleted through their blog")
+	require.NoError(t, store.DeleteComment(ctx, blog.ID, thread.ID))
+	for _, id := range []int64{thread.ID, reply.ID, nested.ID} {
+		_, err := store.CommentAuthor(ctx, id)
+		assert.ErrorIs(t, err, db.ErrNotFound, "replies go with their parent")
+	}
+	list, err := store.ListComments(ctx, blog.ID, db.CommentListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{kept.ID}, commentIDs(list.Items))
+	assert.ErrorIs(t, store.DeleteComment(ctx, blog.ID, thread.ID), db.ErrNotFound)
+}
+
+func testModerationQueue(t *testing.T, store db.Store) {
+	alice, _ := commenter(t, store, "alice")
+	blog := publishedBlog(t, store, 1)
+	other := publishedBlog(t, store, 2)
+	var pending []int64
+	for _, blogID := range []int64{blog.ID, other.ID, blog.ID} {
+		comment, err := store.CreateComment(alice, blogID, models.CommentRequest{Body: "Pending"})
+		require.NoError(t, err)
+		pending = append(pending, comment.ID)
+	}
+	ok := approved(t, store, alice, blog.ID, "Approved", nil)
+
+	queue, err := store.ModerationQueue(ctx, models.CommentPending, db.CommentListOptions{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 3, queue.Total)
+	assert.Equal(t, pending[:2], commentIDs(queue.Items), "the queue spans blogs, oldest first")
+	require.NotEmpty(t, queue.NextCursor)
+	queue, err = store.ModerationQueue(ctx, models.CommentPending, db.CommentListOptions{Limit: 2, After: queue.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, pending[2:], commentIDs(queue.Items))
+	assert.Empty(t, queue.NextCursor)
+
+	spam, err := store.ModerateComment(ctx, pending[0], models.CommentSpam)
+	require.NoError(t, err)
+	assert.Equal(t, models.CommentSpam, spam.Status)
+	queue, err = store.ModerationQueue(ctx, models.CommentSpam, db.CommentListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{spam.ID}, commentIDs(queue.Items))
+	queue, err = store.ModerationQueue(ctx, "", db.CommentListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, append(pending, ok.ID), commentIDs(queue.Items), "an empty status lists every comment")
+
+	_, err = store.ModerateComment(ctx, ok.ID+100, models.CommentApproved)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+}
+
+func testCommentsFollowBlog(t *testing.T, store db.Store) {
+	alice, user := commenter(t, store, "alice")
+	blog := publishedBlog(t, store, 1)
+	thread := approved(t, store, alice, blog.ID, "Thread", nil)
+	reply := approved(t, store, alice, blog.ID, "Reply", &thread)
+	pending, err := store.CreateComment(alice, blog.ID, models.CommentRequest{Body: "Pending"})
+	require.NoError(t, err)
+
+	require.NoError(t, store.DeleteBlog(ctx, blog.ID, db.AnyVersion))
+	_, err = store.ListComments(ctx, blog.ID, db.CommentListOptions{})
+	assert.ErrorIs(t, err, db.ErrNotFound, "comments go to the trash with their blog")
+	_, err = store.CreateComment(alice, blog.ID, models.CommentRequest{Body: "Too late"})
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	_, err = store.UpdateComment(alice, blog.ID, thread.ID, models.CommentEditRequest{Body: "Edit"})
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	assert.ErrorIs(t, store.DeleteComment(alice, blog.ID, thread.ID), db.ErrNotFound)
+	_, err = store.ModerateComment(ctx, pending.ID, models.CommentApproved)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	queue, err := store.ModerationQueue(ctx, "", db.CommentListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, queue.Items, "trashed blogs leave the moderation queue")
+	author, err := store.CommentAuthor(ctx, thread.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, author)
+
+	_, err = store.RestoreBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	list, err := store.ListComments(ctx, blog.ID, db.CommentListOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []int64{thread.ID}, commentIDs(list.Items), "restoring a blog restores its comments")
+	assert.Equal(t, []int64{reply.ID}, commentIDs(list.Items[0].Replies))
+	queue, err = store.ModerationQueue(ctx, models.CommentPending, db.CommentListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{pending.ID}, commentIDs(queue.Items))
+
+	require.NoError(t, store.DeleteBlog(ctx, blog.ID, db.AnyVersion))
+	require.NoError(t, store.PurgeBlog(ctx, blog.ID))
+	for _, id := range []int64{thread.ID, reply.ID, pending.ID} {
+		_, err := store.CommentAuthor(ctx, id)
+		assert.ErrorIs(t, err, db.ErrNotFound, "purging a blog removes its comments")
+	}
+}
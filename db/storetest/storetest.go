@@ -0,0 +1,1266 @@
+// Package storetest is a backend-agnostic conformance suite for
+// db.BlogStore implementations. Every backend should run it against itself
+// so that swapping storage never changes API behaviour:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) db.BlogStore { return db.NewRepo() })
+//	}
+//
+// Backends that also keep user accounts run RunUsers the same way.
+package storetest
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns a new, empty store. It is called once per sub-test.
+type Factory func(t *testing.T) db.BlogStore
+
+// Run executes the full conformance suite against stores built by newStore
+func Run(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, store db.BlogStore)
+	}{
+		{"Create", testCreate},
+		{"Get", testGet},
+		{"List", testList},
+		{"Sorting", testSorting},
+		{"Pagination", testPagination},
+		{"Search", testSearch},
+		{"Search Pagination", testSearchPagination},
+		{"Update", testUpdate},
+		{"Delete", testDelete},
+		{"Not Found", testNotFound},
+		{"Missing Required Fields", testValidation},
+		{"Unique IDs", testUniqueIDs},
+		{"IDs Not Reused After Delete", testIDsNotReused},
+		{"Concurrent Creates And Deletes", testConcurrentCreateDelete},
+		{"Timestamps", testTimestamps},
+		{"Concurrent Access", testConcurrency},
+		{"Versions", testVersions},
+		{"Conditional Writes", testConditionalWrites},
+		{"Concurrent Conditional Updates", testConcurrentConditionalUpdates},
+		{"Revisions", testRevisions},
+		{"Revisions Not Found", testRevisionsNotFound},
+		{"Trash", testTrash},
+		{"Restore", testRestore},
+		{"Trash Versions", testTrashVersions},
+		{"Purge", testPurge},
+		{"Purge Deleted", testPurgeDeleted},
+		{"Lifecycle", testLifecycle},
+		{"Invalid Transitions", testInvalidTransitions},
+		{"Status Filter", testStatusFilter},
+		{"Publish Due", testPublishDue},
+		{"Slugs", testSlugs},
+		{"Slug History", testSlugHistory},
+		{"Slug Conflicts", testSlugConflicts},
+		{"Body Formats", testBodyFormats},
+		{"Tags", testTags},
+		{"Tag Filter", testTagFilter},
+		{"Tag Counts", testTagCounts},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.fn(t, newStore(t))
+		})
+	}
+}
+
+var ctx = context.Background()
+
+func request(n int) models.BlogRequestBody {
+	return models.BlogRequestBody{
+		Title:       fmt.Sprintf("Title %d", n),
+		Description: fmt.Sprintf("Description %d", n),
+		Body:        fmt.Sprintf("Body %d", n),
+	}
+}
+
+func create(t *testing.T, store db.BlogStore, n int) models.Blog {
+	t.Helper()
+	blog, err := store.CreateBlog(ctx, request(n))
+	require.NoError(t, err)
+	return blog
+}
+
+// assertSameBlog compares blogs field by field, using time.Equal so that
+// monotonic clock readings and time zones do not matter
+func assertSameBlog(t *testing.T, want, got models.Blog) {
+	t.Helper()
+	assert.Equal(t, want.ID, got.ID)
+	assert.Equal(t, want.Slug, got.Slug)
+	assert.Equal(t, want.Title, got.Title)
+	assert.Equal(t, want.Description, got.Description)
+	assert.Equal(t, want.Body, got.Body)
+	assert.Equal(t, want.BodyFormat, got.BodyFormat)
+	assert.Equal(t, want.BodyHTML, got.BodyHTML)
+	assert.Equal(t, want.Tags, got.Tags)
+	assert.Equal(t, want.Categories, got.Categories)
+	assert.Equal(t, want.Version, got.Version)
+	assert.True(t, want.CreatedAt.Equal(got.CreatedAt), "created_at: want %v, got %v", want.CreatedAt, got.CreatedAt)
+	assert.True(t, want.UpdatedAt.Equal(got.UpdatedAt), "updated_at: want %v, got %v", want.UpdatedAt, got.UpdatedAt)
+}
+
+func testCreate(t *testing.T, store db.BlogStore) {
+	req := request(1)
+	blog, err := store.CreateBlog(ctx, req)
+	require.NoError(t, err)
+	assert.Positive(t, blog.ID)
+	assert.Equal(t, req.Title, blog.Title)
+	assert.Equal(t, req.Description, blog.Description)
+	assert.Equal(t, req.Body, blog.Body)
+}
+
+func testGet(t *testing.T, store db.BlogStore) {
+	blog := create(t, store, 1)
+	got, err := store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assertSameBlog(t, blog, got)
+}
+
+func testList(t *testing.T, store db.BlogStore) {
+	page, err := store.GetAllBlogs(ctx, db.ListOptions{})
+	require.NoError(t, err, "an empty store is not an error")
+	assert.NotNil(t, page.Items, "an empty store lists as [] rather than null")
+	assert.Empty(t, page.Items)
+	assert.Zero(t, page.Total)
+	assert.Empty(t, page.NextCursor)
+	assert.Empty(t, page.PrevCursor)
+
+	created := map[int64]models.Blog{}
+	for i := 1; i <= 3; i++ {
+		blog := create(t, store, i)
+		created[blog.ID] = blog
+	}
+	page, err = store.GetAllBlogs(ctx, db.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.Items, len(created))
+	assert.Equal(t, len(created), page.Total)
+	for _, got := range page.Items {
+		want, ok := created[got.ID]
+		require.True(t, ok, "unexpected blog %d", got.ID)
+		assertSameBlog(t, want, got)
+	}
+}
+
+func ids(blogs []models.Blog) []int64 {
+	out := make([]int64, len(blogs))
+	for i, blog := range blogs {
+		out[i] = blog.ID
+	}
+	return out
+}
+
+// seedForSorting creates posts whose titles, creation and update order all
+// differ, including a duplicate title to exercise the ID tie-breaker
+func seedForSorting(t *testing.T, store db.BlogStore) []models.Blog {
+	titles := []string{"delta", "alpha", "charlie", "alpha", "bravo"}
+	blogs := make([]models.Blog, len(titles))
+	for i, title := range titles {
+		blog, err := store.CreateBlog(ctx, models.BlogRequestBody{Title: title, Description: "d", Body: "b"})
+		require.NoError(t, err)
+		blogs[i] = blog
+		time.Sleep(2 * time.Millisecond)
+	}
+	// touch the posts in a different order so updated_at differs from created_at
+	for _, i := range []int{2, 0, 4, 1, 3} {
+		updated, err := store.UpdateBlog(ctx, blogs[i].ID, models.BlogRequestBody{Title: blogs[i].Title, Description: "d2", Body: "b2"}, db.AnyVersion)
+		require.NoError(t, err)
+		blogs[i] = updated
+		time.Sleep(2 * time.Millisecond)
+	}
+	return blogs
+}
+
+func testSorting(t *testing.T, store db.BlogStore) {
+	b := seedForSorting(t, store)
+	tests := []struct {
+		sort db.SortField
+		desc bool
+		want []models.Blog
+	}{
+		{db.SortCreatedAt, false, []models.Blog{b[0], b[1], b[2], b[3], b[4]}},
+		{db.SortCreatedAt, true, []models.Blog{b[4], b[3], b[2], b[1], b[0]}},
+		{db.SortUpdatedAt, false, []models.Blog{b[2], b[0], b[4], b[1], b[3]}},
+		{db.SortUpdatedAt, true, []models.Blog{b[3], b[1], b[4], b[0], b[2]}},
+		{db.SortTitle, false, []models.Blog{b[1], b[3], b[4], b[2], b[0]}},
+		{db.SortTitle, true, []models.Blog{b[0], b[2], b[4], b[3], b[1]}},
+		{"", false, []models.Blog{b[0], b[1], b[2], b[3], b[4]}},
+	}
+	for _, test := range tests {
+		page, err := store.GetAllBlogs(ctx, db.ListOptions{Sort: test.sort, Desc: test.desc})
+		require.NoError(t, err)
+		assert.Equal(t, ids(test.want), ids(page.Items), "sort=%q desc=%v", test.sort, test.desc)
+	}
+
+	_, err := store.GetAllBlogs(ctx, db.ListOptions{Sort: "body"})
+	assert.ErrorIs(t, err, db.ErrValidation)
+}
+
+func testPagination(t *testing.T, store db.BlogStore) {
+	b := seedForSorting(t, store)
+	for _, sort := range []db.SortField{db.SortCreatedAt, db.SortUpdatedAt, db.SortTitle} {
+		for _, desc := range []bool{false, true} {
+			full, err := store.GetAllBlogs(ctx, db.ListOptions{Sort: sort, Desc: desc})
+			require.NoError(t, err)
+			require.Len(t, full.Items, len(b))
+
+			// walk forwards two at a time
+			opts := db.ListOptions{Limit: 2, Sort: sort, Desc: desc}
+			var forward []models.Blog
+			var pages []models.BlogList
+			for {
+				page, err := store.GetAllBlogs(ctx, opts)
+				require.NoError(t, err)
+				assert.Equal(t, len(b), page.Total)
+				assert.LessOrEqual(t, len(page.Items), 2)
+				assert.Equal(t, opts.After != "", page.PrevCursor != "", "only the first page lacks a prev cursor")
+				forward = append(forward, page.Items...)
+				pages = append(pages, page)
+				if page.NextCursor == "" {
+					break
+				}
+				opts.After = page.NextCursor
+			}
+			assert.Equal(t, ids(full.Items), ids(forward), "sort=%q desc=%v", sort, desc)
+			require.Len(t, pages, 3)
+
+			// and back again from the last page
+			opts = db.ListOptions{Limit: 2, Sort: sort, Desc: desc, Before: pages[2].PrevCursor}
+			page, err := store.GetAllBlogs(ctx, opts)
+			require.NoError(t, err)
+			assert.Equal(t, ids(pages[1].Items), ids(page.Items))
+			assert.NotEmpty(t, page.NextCursor)
+			assert.NotEmpty(t, page.PrevCursor)
+			opts.Before = page.PrevCursor
+			page, err = store.GetAllBlogs(ctx, opts)
+			require.NoError(t, err)
+			assert.Equal(t, ids(pages[0].Items), ids(page.Items))
+			assert.NotEmpty(t, page.NextCursor)
+			assert.Empty(t, page.PrevCursor, "the first page has no prev cursor")
+		}
+	}
+
+	t.Run("Limit", func(t *testing.T) {
+		page, err := store.GetAllBlogs(ctx, db.ListOptions{Limit: 1})
+		require.NoError(t, err)
+		assert.Len(t, page.Items, 1)
+		_, err = store.GetAllBlogs(ctx, db.ListOptions{Limit: -1})
+		assert.ErrorIs(t, err, db.ErrValidation)
+	})
+	t.Run("Cursor survives deletion", func(t *testing.T) {
+		opts := db.ListOptions{Limit: 2}
+		first, err := store.GetAllBlogs(ctx, opts)
+		require.NoError(t, err)
+		require.NoError(t, store.DeleteBlog(ctx, first.Items[1].ID, db.AnyVersion))
+		opts.After = first.NextCursor
+		second, err := store.GetAllBlogs(ctx, opts)
+		require.NoError(t, err)
+		assert.Equal(t, ids([]models.Blog{b[2], b[3]}), ids(second.Items))
+	})
+	t.Run("Invalid cursors", func(t *testing.T) {
+		page, err := store.GetAllBlogs(ctx, db.ListOptions{Limit: 2})
+		require.NoError(t, err)
+		for _, opts := range []db.ListOptions{
+			{After: "not a cursor"},
+			{Before: "bm90IGpzb24"},
+			{After: page.NextCursor, Sort: db.SortTitle},
+			{After: page.NextCursor, Desc: true},
+			{After: page.NextCursor, Before: page.NextCursor},
+		} {
+			_, err := store.GetAllBlogs(ctx, opts)
+			assert.ErrorIs(t, err, db.ErrValidation, "%+v", opts)
+		}
+	})
+}
+
+func hitIDs(hits []models.SearchHit) []int64 {
+	out := make([]int64, len(hits))
+	for i, hit := range hits {
+		out[i] = hit.ID
+	}
+	return out
+}
+
+func testSearch(t *testing.T, store db.BlogStore) {
+	post := func(title, description, body string) models.Blog {
+		blog, err := store.CreateBlog(ctx, models.BlogRequestBody{Title: title, Description: description, Body: body})
+		require.NoError(t, err)
+		return blog
+	}
+	inTitle := post("Concurrency in Go", "A tour", "Goroutines and channels make worker pools easy.")
+	inBody := post("Error handling", "Wrapping errors", "Go errors are values; concurrency is another topic.")
+	unrelated := post("Rust ownership", "Borrowing", "The borrow checker keeps memory safe.")
+	search := func(q string) models.SearchResults {
+		t.Helper()
+		results, err := store.SearchBlogs(ctx, q, db.SearchOptions{})
+		require.NoError(t, err, q)
+		return results
+	}
+
+	t.Run("Relevance", func(t *testing.T) {
+		results := search("concurrency")
+		assert.Equal(t, []int64{inTitle.ID, inBody.ID}, hitIDs(results.Items), "title matches outrank body matches")
+		assert.Equal(t, 2, results.Total)
+		assert.Greater(t, results.Items[0].Score, results.Items[1].Score)
+		assertSameBlog(t, inTitle, results.Items[0].Blog)
+	})
+	t.Run("Case insensitive and all terms required", func(t *testing.T) {
+		assert.Equal(t, []int64{inBody.ID}, hitIDs(search("GO Errors").Items))
+		assert.Empty(t, search("go ownership").Items)
+	})
+	t.Run("Phrase", func(t *testing.T) {
+		assert.Equal(t, []int64{inTitle.ID}, hitIDs(search(`"worker pools"`).Items))
+		assert.Empty(t, search(`"pools worker"`).Items)
+	})
+	t.Run("Prefix", func(t *testing.T) {
+		assert.Equal(t, []int64{inTitle.ID}, hitIDs(search("gorout*").Items))
+		assert.Equal(t, []int64{unrelated.ID}, hitIDs(search("borrow*").Items), "matches both borrow and borrowing")
+		assert.Empty(t, search("borrowed*").Items)
+	})
+	t.Run("Highlights", func(t *testing.T) {
+		hit := search("channels").Items[0]
+		assert.Equal(t, map[string]string{
+			"body": "Goroutines and <mark>channels</mark> make worker pools easy.",
+		}, hit.Highlights)
+	})
+	t.Run("Index follows writes", func(t *testing.T) {
+		_, err := store.UpdateBlog(ctx, unrelated.ID, models.BlogRequestBody{Title: "Zig comptime", Description: "Metaprogramming", Body: "Compile-time code."}, db.AnyVersion)
+		require.NoError(t, err)
+		assert.Empty(t, search("borrow").Items)
+		assert.Equal(t, []int64{unrelated.ID}, hitIDs(search("comptime").Items))
+
+		require.NoError(t, store.DeleteBlog(ctx, inTitle.ID, db.AnyVersion))
+		assert.Equal(t, []int64{inBody.ID}, hitIDs(search("concurrency").Items))
+	})
+	t.Run("Empty query", func(t *testing.T) {
+		_, err := store.SearchBlogs(ctx, ` "" `, db.SearchOptions{})
+		assert.ErrorIs(t, err, db.ErrValidation)
+	})
+}
+
+func testSearchPagination(t *testing.T, store db.BlogStore) {
+	for i := 0; i < 5; i++ {
+		create(t, store, i)
+	}
+	opts := db.SearchOptions{Limit: 2}
+	var all []int64
+	for {
+		results, err := store.SearchBlogs(ctx, "title", opts)
+		require.NoError(t, err)
+		assert.Equal(t, 5, results.Total)
+		all = append(all, hitIDs(results.Items)...)
+		if results.NextCursor == "" {
+			break
+		}
+		opts.After = results.NextCursor
+	}
+	assert.Len(t, all, 5)
+	assert.IsIncreasing(t, all, "equal scores are ordered by ID")
+
+	_, err := store.SearchBlogs(ctx, "title", db.SearchOptions{After: "bogus"})
+	assert.ErrorIs(t, err, db.ErrValidation)
+}
+
+func testUpdate(t *testing.T, store db.BlogStore) {
+	blog := create(t, store, 1)
+	req := request(2)
+	updated, err := store.UpdateBlog(ctx, blog.ID, req, db.AnyVersion)
+	require.NoError(t, err)
+	assert.Equal(t, blog.ID, updated.ID)
+	assert.Equal(t, req.Title, updated.Title)
+	assert.Equal(t, req.Description, updated.Description)
+	assert.Equal(t, req.Body, updated.Body)
+
+	got, err := store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assertSameBlog(t, updated, got)
+}
+
+func testDelete(t *testing.T, store db.BlogStore) {
+	blog := create(t, store, 1)
+	other := create(t, store, 2)
+	require.NoError(t, store.DeleteBlog(ctx, blog.ID, db.AnyVersion))
+
+	_, err := store.GetBlog(ctx, blog.ID)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	got, err := store.GetBlog(ctx, other.ID)
+	require.NoError(t, err, "deleting one blog must not affect another")
+	assertSameBlog(t, other, got)
+}
+
+func testNotFound(t *testing.T, store db.BlogStore) {
+	const missing = 1000
+	_, err := store.GetBlog(ctx, missing)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	_, err = store.UpdateBlog(ctx, missing, request(1), db.AnyVersion)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	assert.ErrorIs(t, store.DeleteBlog(ctx, missing, db.AnyVersion), db.ErrNotFound)
+
+	blog := create(t, store, 1)
+	require.NoError(t, store.DeleteBlog(ctx, blog.ID, db.AnyVersion))
+	assert.ErrorIs(t, store.DeleteBlog(ctx, blog.ID, db.AnyVersion), db.ErrNotFound, "double delete")
+	_, err = store.UpdateBlog(ctx, blog.ID, request(2), db.AnyVersion)
+	assert.ErrorIs(t, err, db.ErrNotFound, "update after delete")
+}
+
+func testValidation(t *testing.T, store db.BlogStore) {
+	incomplete := []models.BlogRequestBody{
+		{Description: "Description", Body: "Body"},
+		{Title: "Title", Body: "Body"},
+		{Title: "Title", Description: "Description"},
+	}
+	for _, req := range incomplete {
+		blog, err := store.CreateBlog(ctx, req)
+		assert.ErrorIs(t, err, db.ErrValidation)
+		assert.Empty(t, blog)
+	}
+	_, err := store.CreateBlog(ctx, models.BlogRequestBody{Body: "Body"})
+	var validationErr *db.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []models.FieldError{
+		{Field: "title", Message: "is required"},
+		{Field: "description", Message: "is required"},
+	}, validationErr.Fields, "every missing field is reported")
+
+	blog, err := store.CreateBlog(ctx, models.BlogRequestBody{Title: "  Padded title\t", Description: " Padded ", Body: "  Indented body\n"})
+	require.NoError(t, err)
+	assert.Equal(t, "Padded title", blog.Title, "titles are trimmed")
+	assert.Equal(t, "Padded", blog.Description)
+	assert.Equal(t, "  Indented body\n", blog.Body, "bodies are kept as sent")
+	_, err = store.CreateBlog(ctx, models.BlogRequestBody{Title: "   ", Description: "Description", Body: "\n"})
+	require.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Fields, 2, "blank is as good as missing")
+
+	existing := create(t, store, 1)
+	for _, req := range incomplete {
+		blog, err := store.UpdateBlog(ctx, existing.ID, req, db.AnyVersion)
+		assert.ErrorIs(t, err, db.ErrValidation)
+		assert.Empty(t, blog)
+	}
+	got, err := store.GetBlog(ctx, existing.ID)
+	require.NoError(t, err)
+	assertSameBlog(t, existing, got)
+}
+
+func testUniqueIDs(t *testing.T, store db.BlogStore) {
+	seen := map[int64]bool{}
+	for i := 0; i < 10; i++ {
+		blog := create(t, store, i)
+		assert.False(t, seen[blog.ID], "id %d allocated twice", blog.ID)
+		seen[blog.ID] = true
+	}
+}
+
+func testIDsNotReused(t *testing.T, store db.BlogStore) {
+	first := create(t, store, 1)
+	second := create(t, store, 2)
+	require.Greater(t, second.ID, first.ID)
+
+	// Deleting the oldest post must not let the next create overwrite the
+	// newest one (the old len(data)+1 allocation did exactly that).
+	require.NoError(t, store.DeleteBlog(ctx, first.ID, db.AnyVersion))
+	third := create(t, store, 3)
+	assert.Greater(t, third.ID, second.ID)
+	got, err := store.GetBlog(ctx, second.ID)
+	require.NoError(t, err)
+	assertSameBlog(t, second, got)
+
+	// Deleting the newest post must not hand its ID out again.
+	require.NoError(t, store.DeleteBlog(ctx, third.ID, db.AnyVersion))
+	fourth := create(t, store, 4)
+	assert.Greater(t, fourth.ID, third.ID)
+
+	// Nor may emptying the store reset the sequence.
+	require.NoError(t, store.DeleteBlog(ctx, second.ID, db.AnyVersion))
+	require.NoError(t, store.DeleteBlog(ctx, fourth.ID, db.AnyVersion))
+	fifth := create(t, store, 5)
+	assert.Greater(t, fifth.ID, fourth.ID)
+}
+
+func testConcurrentCreateDelete(t *testing.T, store db.BlogStore) {
+	const workers = 20
+
+	var mu sync.Mutex
+	seen := map[int64]bool{}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				blog, err := store.CreateBlog(ctx, request(n))
+				if !assert.NoError(t, err) {
+					return
+				}
+				mu.Lock()
+				assert.False(t, seen[blog.ID], "id %d allocated twice", blog.ID)
+				seen[blog.ID] = true
+				mu.Unlock()
+				if j%2 == 0 {
+					assert.NoError(t, store.DeleteBlog(ctx, blog.ID, db.AnyVersion))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Len(t, seen, workers*3)
+}
+
+func testTimestamps(t *testing.T, store db.BlogStore) {
+	before := time.Now().Add(-time.Second)
+	blog := create(t, store, 1)
+	after := time.Now().Add(time.Second)
+	assert.WithinRange(t, blog.CreatedAt, before, after)
+	assert.True(t, blog.CreatedAt.Equal(blog.UpdatedAt), "a new blog has not been updated yet")
+
+	time.Sleep(10 * time.Millisecond)
+	updated, err := store.UpdateBlog(ctx, blog.ID, request(2), db.AnyVersion)
+	require.NoError(t, err)
+	assert.True(t, blog.CreatedAt.Equal(updated.CreatedAt), "updates must keep created_at")
+	assert.True(t, updated.UpdatedAt.After(blog.UpdatedAt), "updates must advance updated_at")
+}
+
+func testConcurrency(t *testing.T, store db.BlogStore) {
+	const workers = 20
+
+	ids := make(chan int64, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			blog, err := store.CreateBlog(ctx, request(n))
+			if assert.NoError(t, err) {
+				ids <- blog.ID
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := map[int64]bool{}
+	for id := range ids {
+		assert.False(t, seen[id], "id %d allocated twice", id)
+		seen[id] = true
+	}
+	page, err := store.GetAllBlogs(ctx, db.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, workers, page.Total)
+
+	target := page.Items[0].ID
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func(n int) {
+			defer wg.Done()
+			_, err := store.UpdateBlog(ctx, target, request(n), db.AnyVersion)
+			assert.NoError(t, err)
+		}(i)
+		go func() {
+			defer wg.Done()
+			_, err := store.GetBlog(ctx, target)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	got, err := store.GetBlog(ctx, target)
+	require.NoError(t, err)
+	var n int
+	_, err = fmt.Sscanf(got.Title, "Title %d", &n)
+	require.NoError(t, err)
+	assert.Equal(t, request(n), models.BlogRequestBody{
+		Title:       got.Title,
+		Description: got.Description,
+		Body:        got.Body,
+	}, "fields of concurrent updates must not interleave")
+}
+
+func testVersions(t *testing.T, store db.BlogStore) {
+	blog := create(t, store, 1)
+	assert.Equal(t, int64(1), blog.Version, "new blogs start at version 1")
+
+	updated, err := store.UpdateBlog(ctx, blog.ID, request(2), db.AnyVersion)
+	require.NoError(t, err)
+	assert.Equal(t, blog.Version+1, updated.Version)
+	updated, err = store.UpdateBlog(ctx, blog.ID, request(3), updated.Version)
+	require.NoError(t, err)
+	assert.Equal(t, blog.Version+2, updated.Version)
+
+	got, err := store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assertSameBlog(t, updated, got)
+}
+
+func testConditionalWrites(t *testing.T, store db.BlogStore) {
+	blog := create(t, store, 1)
+	updated, err := store.UpdateBlog(ctx, blog.ID, request(2), blog.Version)
+	require.NoError(t, err)
+
+	_, err = store.UpdateBlog(ctx, blog.ID, request(3), blog.Version)
+	assert.ErrorIs(t, err, db.ErrPreconditionFailed, "update against a stale version")
+	var mismatch *db.VersionMismatchError
+	require.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, blog.Version, mismatch.Expected)
+	assert.Equal(t, updated.Version, mismatch.Actual)
+
+	assert.ErrorIs(t, store.DeleteBlog(ctx, blog.ID, blog.Version), db.ErrPreconditionFailed,
+		"delete against a stale version")
+	got, err := store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err, "failed preconditions must not write")
+	assertSameBlog(t, updated, got)
+
+	_, err = store.UpdateBlog(ctx, 1000, request(1), 1)
+	assert.ErrorIs(t, err, db.ErrNotFound, "a missing blog is not a version mismatch")
+	assert.ErrorIs(t, store.DeleteBlog(ctx, 1000, 1), db.ErrNotFound)
+
+	require.NoError(t, store.DeleteBlog(ctx, blog.ID, updated.Version))
+	_, err = store.GetBlog(ctx, blog.ID)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+}
+
+func testConcurrentConditionalUpdates(t *testing.T, store db.BlogStore) {
+	const workers = 20
+
+	blog := create(t, store, 0)
+	var wg sync.WaitGroup
+	results := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			_, err := store.UpdateBlog(ctx, blog.ID, request(n), blog.Version)
+			results <- err
+		}(i + 1)
+	}
+	wg.Wait()
+	close(results)
+
+	var won int
+	for err := range results {
+		if err == nil {
+			won++
+			continue
+		}
+		assert.ErrorIs(t, err, db.ErrPreconditionFailed)
+	}
+	assert.Equal(t, 1, won, "exactly one writer may win a version")
+
+	got, err := store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assert.Equal(t, blog.Version+1, got.Version)
+}
+
+func testRevisions(t *testing.T, store db.BlogStore) {
+	alice := db.WithActor(ctx, "alice")
+	blog, err := store.CreateBlog(alice, request(1))
+	require.NoError(t, err)
+	updated, err := store.UpdateBlog(db.WithActor(ctx, "bob"), blog.ID, request(2), db.AnyVersion)
+	require.NoError(t, err)
+	failed, err := store.UpdateBlog(ctx, blog.ID, request(3), blog.Version)
+	require.ErrorIs(t, err, db.ErrPreconditionFailed)
+	assert.Empty(t, failed)
+	other := create(t, store, 4)
+
+	revisions, err := store.ListRevisions(ctx, blog.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2, "failed writes must not record revisions")
+	for i, want := range []struct {
+		blog   models.Blog
+		req    models.BlogRequestBody
+		author string
+	}{{blog, request(1), "alice"}, {updated, request(2), "bob"}} {
+		rev := revisions[i]
+		assert.Equal(t, blog.ID, rev.BlogID)
+		assert.Equal(t, want.blog.Version, rev.Version)
+		assert.Equal(t, want.req, models.BlogRequestBody{Title: rev.Title, Description: rev.Description, Body: rev.Body})
+		assert.Equal(t, want.author, rev.Author)
+		assert.True(t, want.blog.UpdatedAt.Equal(rev.CreatedAt), "a revision is dated by its write")
+
+		got, err := store.GetRevision(ctx, blog.ID, rev.Version)
+		require.NoError(t, err)
+		assert.Equal(t, rev.Title, got.Title)
+		assert.Equal(t, rev.Author, got.Author)
+	}
+
+	otherRevisions, err := store.ListRevisions(ctx, other.ID)
+	require.NoError(t, err)
+	require.Len(t, otherRevisions, 1)
+	assert.Empty(t, otherRevisions[0].Author, "writes without an actor are anonymous")
+}
+
+func testRevisionsNotFound(t *testing.T, store db.BlogStore) {
+	_, err := store.ListRevisions(ctx, 1000)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	_, err = store.GetRevision(ctx, 1000, 1)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+
+	blog := create(t, store, 1)
+	_, err = store.GetRevision(ctx, blog.ID, 2)
+	var notFound *db.NotFoundError
+	require.ErrorAs(t, err, &notFound)
+	assert.Equal(t, "revision", notFound.Resource)
+
+	require.NoError(t, store.DeleteBlog(ctx, blog.ID, db.AnyVersion))
+	_, err = store.ListRevisions(ctx, blog.ID)
+	assert.ErrorIs(t, err, db.ErrNotFound, "revisions go away with their blog")
+}
+
+func testTrash(t *testing.T, store db.BlogStore) {
+	trashed := create(t, store, 1)
+	live := create(t, store, 2)
+	before := time.Now().Add(-time.Second)
+	require.NoError(t, store.DeleteBlog(ctx, trashed.ID, db.AnyVersion))
+
+	_, err := store.GetBlog(ctx, trashed.ID)
+	assert.ErrorIs(t, err, db.ErrNotFound, "trashed blogs are hidden from GetBlog")
+	_, err = store.UpdateBlog(ctx, trashed.ID, request(3), db.AnyVersion)
+	assert.ErrorIs(t, err, db.ErrNotFound, "trashed blogs cannot be edited")
+
+	page, err := store.GetAllBlogs(ctx, db.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{live.ID}, ids(page.Items))
+	assert.Equal(t, 1, page.Total)
+	results, err := store.SearchBlogs(ctx, "title", db.SearchOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{live.ID}, hitIDs(results.Items), "trashed blogs are not searchable")
+
+	trash, err := store.GetAllBlogs(ctx, db.ListOptions{Deleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, trash.Total)
+	require.Len(t, trash.Items, 1)
+	got := trash.Items[0]
+	assert.Equal(t, trashed.ID, got.ID)
+	require.NotNil(t, got.DeletedAt)
+	assert.WithinRange(t, *got.DeletedAt, before, time.Now().Add(time.Second))
+	assert.Equal(t, trashed.Version+1, got.Version, "trashing bumps the version")
+}
+
+func testRestore(t *testing.T, store db.BlogStore) {
+	blog := create(t, store, 1)
+	_, err := store.RestoreBlog(ctx, blog.ID)
+	assert.ErrorIs(t, err, db.ErrConflict, "live blogs cannot be restored")
+	_, err = store.RestoreBlog(ctx, 1000)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+
+	require.NoError(t, store.DeleteBlog(ctx, blog.ID, db.AnyVersion))
+	restored, err := store.RestoreBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Equal(t, blog.Title, restored.Title)
+	assert.Equal(t, blog.Body, restored.Body)
+	assert.Equal(t, blog.Slug, restored.Slug)
+
+	got, err := store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assertSameBlog(t, restored, got)
+	results, err := store.SearchBlogs(ctx, "title", db.SearchOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{blog.ID}, hitIDs(results.Items), "restored blogs are searchable again")
+	revisions, err := store.ListRevisions(ctx, blog.ID)
+	require.NoError(t, err)
+	assert.Len(t, revisions, 3, "revisions survive the trash")
+}
+
+func testTrashVersions(t *testing.T, store db.BlogStore) {
+	blog := create(t, store, 1)
+	require.NoError(t, store.DeleteBlog(ctx, blog.ID, blog.Version))
+	trash, err := store.GetAllBlogs(ctx, db.ListOptions{Deleted: true})
+	require.NoError(t, err)
+	require.Len(t, trash.Items, 1)
+	trashed := trash.Items[0]
+	assert.Equal(t, blog.Version+1, trashed.Version, "the trashed blog has its own version")
+
+	restored, err := store.RestoreBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assert.Equal(t, trashed.Version+1, restored.Version, "restoring bumps the version")
+	_, err = store.UpdateBlog(ctx, blog.ID, request(2), blog.Version)
+	assert.ErrorIs(t, err, db.ErrPreconditionFailed, "versions from before the trash are stale")
+
+	revisions, err := store.ListRevisions(ctx, blog.ID)
+	require.NoError(t, err)
+	var versions []int64
+	for _, rev := range revisions {
+		versions = append(versions, rev.Version)
+	}
+	assert.Equal(t, []int64{1, 2, 3}, versions, "every version has its revision")
+	latest, err := store.GetRevision(ctx, blog.ID, restored.Version)
+	require.NoError(t, err)
+	assert.Equal(t, restored.Body, latest.Body)
+}
+
+func testPurge(t *testing.T, store db.BlogStore) {
+	blog := create(t, store, 1)
+	assert.ErrorIs(t, store.PurgeBlog(ctx, blog.ID), db.ErrConflict, "live blogs cannot be purged")
+	assert.ErrorIs(t, store.PurgeBlog(ctx, 1000), db.ErrNotFound)
+
+	require.NoError(t, store.DeleteBlog(ctx, blog.ID, db.AnyVersion))
+	require.NoError(t, store.PurgeBlog(ctx, blog.ID))
+	_, err := store.RestoreBlog(ctx, blog.ID)
+	assert.ErrorIs(t, err, db.ErrNotFound, "purged blogs are gone for good")
+	trash, err := store.GetAllBlogs(ctx, db.ListOptions{Deleted: true})
+	require.NoError(t, err)
+	assert.Zero(t, trash.Total)
+
+	next := create(t, store, 2)
+	assert.Greater(t, next.ID, blog.ID, "purged IDs are not reused")
+}
+
+func testPurgeDeleted(t *testing.T, store db.BlogStore) {
+	old := create(t, store, 1)
+	live := create(t, store, 2)
+	require.NoError(t, store.DeleteBlog(ctx, old.ID, db.AnyVersion))
+	time.Sleep(10 * time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	recent := create(t, store, 3)
+	require.NoError(t, store.DeleteBlog(ctx, recent.ID, db.AnyVersion))
+
+	n, err := store.PurgeDeleted(ctx, cutoff)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	trash, err := store.GetAllBlogs(ctx, db.ListOptions{Deleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{recent.ID}, ids(trash.Items), "blogs trashed after the cutoff are kept")
+	_, err = store.GetBlog(ctx, live.ID)
+	assert.NoError(t, err, "live blogs are never purged")
+
+	n, err = store.PurgeDeleted(ctx, cutoff)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func testLifecycle(t *testing.T, store db.BlogStore) {
+	blog := create(t, store, 1)
+	assert.Equal(t, models.StatusDraft, blog.Status, "new blogs start as drafts")
+	assert.Nil(t, blog.PublishAt)
+
+	at := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+	scheduled, err := store.TransitionBlog(ctx, blog.ID, models.StatusScheduled, &at, blog.Version)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusScheduled, scheduled.Status)
+	require.NotNil(t, scheduled.PublishAt)
+	assert.True(t, at.Equal(*scheduled.PublishAt))
+	assert.Equal(t, blog.Version+1, scheduled.Version, "transitions are edits")
+
+	before := time.Now().Add(-time.Second)
+	published, err := store.TransitionBlog(ctx, blog.ID, models.StatusPublished, nil, db.AnyVersion)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusPublished, published.Status)
+	require.NotNil(t, published.PublishAt)
+	assert.WithinRange(t, *published.PublishAt, before, time.Now().Add(time.Second), "publishing records when")
+
+	archived, err := store.TransitionBlog(ctx, blog.ID, models.StatusArchived, nil, db.AnyVersion)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusArchived, archived.Status)
+	require.NotNil(t, archived.PublishAt, "archiving keeps the publish time")
+	assert.True(t, published.PublishAt.Equal(*archived.PublishAt))
+
+	draft, err := store.TransitionBlog(ctx, blog.ID, models.StatusDraft, nil, db.AnyVersion)
+	require.NoError(t, err)
+	assert.Nil(t, draft.PublishAt)
+
+	got, err := store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assertSameBlog(t, draft, got)
+	assert.Equal(t, models.StatusDraft, got.Status)
+
+	revisions, err := store.ListRevisions(ctx, blog.ID)
+	require.NoError(t, err)
+	var statuses []models.Status
+	for _, rev := range revisions {
+		statuses = append(statuses, rev.Status)
+	}
+	assert.Equal(t, []models.Status{
+		models.StatusDraft, models.StatusScheduled, models.StatusPublished, models.StatusArchived, models.StatusDraft,
+	}, statuses, "every transition is recorded")
+
+	updated, err := store.UpdateBlog(ctx, blog.ID, request(2), db.AnyVersion)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusDraft, updated.Status, "updates keep the status")
+}
+
+func testInvalidTransitions(t *testing.T, store db.BlogStore) {
+	blog := create(t, store, 1)
+	_, err := store.TransitionBlog(ctx, blog.ID, models.StatusDraft, nil, db.AnyVersion)
+	assert.ErrorIs(t, err, db.ErrConflict, "draft to draft")
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	for _, test := range []struct {
+		to        models.Status
+		publishAt *time.Time
+		field     string
+	}{
+		{models.StatusScheduled, nil, "publish_at"},
+		{models.StatusScheduled, &past, "publish_at"},
+		{models.StatusPublished, &future, "publish_at"},
+		{"deleted", nil, "status"},
+	} {
+		_, err := store.TransitionBlog(ctx, blog.ID, test.to, test.publishAt, db.AnyVersion)
+		var validationErr *db.ValidationError
+		if assert.ErrorAs(t, err, &validationErr, test.to) {
+			assert.Equal(t, test.field, validationErr.Fields[0].Field)
+		}
+	}
+
+	published, err := store.TransitionBlog(ctx, blog.ID, models.StatusPublished, nil, db.AnyVersion)
+	require.NoError(t, err)
+	_, err = store.TransitionBlog(ctx, blog.ID, models.StatusScheduled, &future, db.AnyVersion)
+	assert.ErrorIs(t, err, db.ErrConflict, "published blogs cannot be rescheduled")
+	_, err = store.TransitionBlog(ctx, blog.ID, models.StatusArchived, nil, blog.Version)
+	assert.ErrorIs(t, err, db.ErrPreconditionFailed)
+	_, err = store.TransitionBlog(ctx, 1000, models.StatusPublished, nil, db.AnyVersion)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+
+	got, err := store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assertSameBlog(t, published, got)
+}
+
+func testStatusFilter(t *testing.T, store db.BlogStore) {
+	draft := create(t, store, 1)
+	published := create(t, store, 2)
+	_, err := store.TransitionBlog(ctx, published.ID, models.StatusPublished, nil, db.AnyVersion)
+	require.NoError(t, err)
+
+	page, err := store.GetAllBlogs(ctx, db.ListOptions{Status: models.StatusPublished})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{published.ID}, ids(page.Items))
+	assert.Equal(t, 1, page.Total)
+	page, err = store.GetAllBlogs(ctx, db.ListOptions{Status: models.StatusDraft})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{draft.ID}, ids(page.Items))
+	page, err = store.GetAllBlogs(ctx, db.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, page.Total, "no status lists every status")
+
+	results, err := store.SearchBlogs(ctx, "title", db.SearchOptions{Status: models.StatusPublished})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{published.ID}, hitIDs(results.Items))
+
+	_, err = store.GetAllBlogs(ctx, db.ListOptions{Status: "bogus"})
+	assert.ErrorIs(t, err, db.ErrValidation)
+	_, err = store.SearchBlogs(ctx, "title", db.SearchOptions{Status: "bogus"})
+	assert.ErrorIs(t, err, db.ErrValidation)
+}
+
+func testPublishDue(t *testing.T, store db.BlogStore) {
+	soon := create(t, store, 1)
+	later := create(t, store, 2)
+	draft := create(t, store, 3)
+	soonAt, laterAt := time.Now().Add(time.Minute), time.Now().Add(time.Hour)
+	_, err := store.TransitionBlog(ctx, soon.ID, models.StatusScheduled, &soonAt, db.AnyVersion)
+	require.NoError(t, err)
+	_, err = store.TransitionBlog(ctx, later.ID, models.StatusScheduled, &laterAt, db.AnyVersion)
+	require.NoError(t, err)
+
+	n, err := store.PublishDue(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, n, "nothing is due yet")
+
+	n, err = store.PublishDue(db.WithActor(ctx, "scheduler"), soonAt.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	for id, want := range map[int64]models.Status{
+		soon.ID:  models.StatusPublished,
+		later.ID: models.StatusScheduled,
+		draft.ID: models.StatusDraft,
+	} {
+		got, err := store.GetBlog(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, want, got.Status, "blog %d", id)
+	}
+	got, err := store.GetBlog(ctx, soon.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.PublishAt)
+	assert.WithinDuration(t, soonAt, *got.PublishAt, time.Millisecond, "blogs go live at their scheduled time")
+	revisions, err := store.ListRevisions(ctx, soon.ID)
+	require.NoError(t, err)
+	last := revisions[len(revisions)-1]
+	assert.Equal(t, got.Version, last.Version)
+	assert.Equal(t, "scheduler", last.Author)
+
+	n, err = store.PublishDue(ctx, soonAt.Add(time.Second))
+	require.NoError(t, err)
+	assert.Zero(t, n, "published blogs are not published again")
+}
+
+func testSlugs(t *testing.T, store db.BlogStore) {
+	first, err := store.CreateBlog(ctx, models.BlogRequestBody{Title: "Héllo, Wörld!", Description: "d", Body: "b"})
+	require.NoError(t, err)
+	assert.Equal(t, "hello-world", first.Slug)
+	second, err := store.CreateBlog(ctx, models.BlogRequestBody{Title: "Hello world", Description: "d", Body: "b"})
+	require.NoError(t, err)
+	assert.Equal(t, "hello-world-2", second.Slug, "generated slugs are made unique")
+	custom, err := store.CreateBlog(ctx, models.BlogRequestBody{Title: "Hello", Description: "d", Body: "b", Slug: "greetings"})
+	require.NoError(t, err)
+	assert.Equal(t, "greetings", custom.Slug)
+
+	for slug, id := range map[string]int64{"hello-world": first.ID, "hello-world-2": second.ID, "greetings": custom.ID} {
+		got, err := store.GetBlogBySlug(ctx, slug)
+		require.NoError(t, err)
+		assert.Equal(t, id, got.ID, slug)
+		assert.Equal(t, slug, got.Slug)
+	}
+	_, err = store.GetBlogBySlug(ctx, "missing")
+	assert.ErrorIs(t, err, db.ErrNotFound)
+
+	same, err := store.UpdateBlog(ctx, custom.ID, models.BlogRequestBody{Title: "Hello", Description: "d2", Body: "b2"}, db.AnyVersion)
+	require.NoError(t, err)
+	assert.Equal(t, "greetings", same.Slug, "the slug is kept while the title stays the same")
+
+	require.NoError(t, store.DeleteBlog(ctx, first.ID, db.AnyVersion))
+	_, err = store.GetBlogBySlug(ctx, "hello-world")
+	assert.ErrorIs(t, err, db.ErrNotFound, "trashed blogs are not found by slug")
+	third, err := store.CreateBlog(ctx, models.BlogRequestBody{Title: "Hello world", Description: "d", Body: "b"})
+	require.NoError(t, err)
+	assert.Equal(t, "hello-world-3", third.Slug, "trashed blogs keep their slug")
+}
+
+func testSlugHistory(t *testing.T, store db.BlogStore) {
+	blog, err := store.CreateBlog(ctx, models.BlogRequestBody{Title: "Old title", Description: "d", Body: "b"})
+	require.NoError(t, err)
+	renamed, err := store.UpdateBlog(ctx, blog.ID, models.BlogRequestBody{Title: "New title", Description: "d", Body: "b"}, db.AnyVersion)
+	require.NoError(t, err)
+	assert.Equal(t, "new-title", renamed.Slug, "a title change derives a new slug")
+
+	got, err := store.GetBlogBySlug(ctx, "old-title")
+	require.NoError(t, err, "old slugs keep resolving")
+	assert.Equal(t, blog.ID, got.ID)
+	assert.Equal(t, "new-title", got.Slug, "lookups by old slug report the current one")
+
+	_, err = store.UpdateBlog(ctx, blog.ID, models.BlogRequestBody{Title: "New title", Description: "d", Body: "b", Slug: "newer"}, db.AnyVersion)
+	require.NoError(t, err)
+	for _, slug := range []string{"old-title", "new-title", "newer"} {
+		got, err := store.GetBlogBySlug(ctx, slug)
+		require.NoError(t, err, slug)
+		assert.Equal(t, "newer", got.Slug)
+	}
+
+	// Another blog may claim a retired slug; it then resolves to that blog.
+	claimer, err := store.CreateBlog(ctx, models.BlogRequestBody{Title: "Old title", Description: "d", Body: "b"})
+	require.NoError(t, err)
+	assert.Equal(t, "old-title", claimer.Slug)
+	got, err = store.GetBlogBySlug(ctx, "old-title")
+	require.NoError(t, err)
+	assert.Equal(t, claimer.ID, got.ID)
+
+	// Moving back to a retired slug makes it current again.
+	back, err := store.UpdateBlog(ctx, blog.ID, models.BlogRequestBody{Title: "New title", Description: "d", Body: "b", Slug: "new-title"}, db.AnyVersion)
+	require.NoError(t, err)
+	assert.Equal(t, "new-title", back.Slug)
+	got, err = store.GetBlogBySlug(ctx, "newer")
+	require.NoError(t, err)
+	assert.Equal(t, "new-title", got.Slug)
+
+	require.NoError(t, store.DeleteBlog(ctx, blog.ID, db.AnyVersion))
+	require.NoError(t, store.PurgeBlog(ctx, blog.ID))
+	_, err = store.GetBlogBySlug(ctx, "newer")
+	assert.ErrorIs(t, err, db.ErrNotFound, "purging forgets the slug history")
+	reuse, err := store.CreateBlog(ctx, models.BlogRequestBody{Title: "New title", Description: "d", Body: "b"})
+	require.NoError(t, err)
+	assert.Equal(t, "new-title", reuse.Slug, "purged slugs are free again")
+}
+
+func testSlugConflicts(t *testing.T, store db.BlogStore) {
+	taken, err := store.CreateBlog(ctx, models.BlogRequestBody{Title: "Taken", Description: "d", Body: "b"})
+	require.NoError(t, err)
+	other := create(t, store, 1)
+
+	_, err = store.CreateBlog(ctx, models.BlogRequestBody{Title: "Other", Description: "d", Body: "b", Slug: "taken"})
+	assert.ErrorIs(t, err, db.ErrConflict, "requested slugs are not silently changed")
+	_, err = store.UpdateBlog(ctx, other.ID, models.BlogRequestBody{Title: "Other", Description: "d", Body: "b", Slug: "taken"}, db.AnyVersion)
+	assert.ErrorIs(t, err, db.ErrConflict)
+	got, err := store.GetBlog(ctx, other.ID)
+	require.NoError(t, err)
+	assertSameBlog(t, other, got)
+
+	_, err = store.CreateBlog(ctx, models.BlogRequestBody{Title: "Other", Description: "d", Body: "b", Slug: "Not A Slug"})
+	var validationErr *db.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "slug", validationErr.Fields[0].Field)
+
+	same, err := store.UpdateBlog(ctx, taken.ID, models.BlogRequestBody{Title: "Taken", Description: "d", Body: "b", Slug: "taken"}, db.AnyVersion)
+	require.NoError(t, err, "a blog may keep its own slug")
+	assert.Equal(t, "taken", same.Slug)
+}
+
+func testBodyFormats(t *testing.T, store db.BlogStore) {
+	blog, err := store.CreateBlog(ctx, models.BlogRequestBody{Title: "Markdown", Description: "d", Body: "Some **bold** text"})
+	require.NoError(t, err)
+	assert.Equal(t, models.FormatMarkdown, blog.BodyFormat, "bodies are Markdown unless told otherwise")
+	assert.Equal(t, "<p>Some <strong>bold</strong> text</p>\n", blog.BodyHTML)
+	got, err := store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assertSameBlog(t, blog, got)
+
+	updated, err := store.UpdateBlog(ctx, blog.ID, models.BlogRequestBody{
+		Title: "Markdown", Description: "d", Body: "<b>bold</b><script>alert(1)</script>", BodyFormat: models.FormatHTML,
+	}, db.AnyVersion)
+	require.NoError(t, err)
+	assert.Equal(t, models.FormatHTML, updated.BodyFormat)
+	assert.Equal(t, "<b>bold</b>", updated.BodyHTML, "stored HTML is sanitized")
+	assert.Equal(t, "<b>bold</b><script>alert(1)</script>", updated.Body, "the source is kept as sent")
+
+	plain, err := store.CreateBlog(ctx, models.BlogRequestBody{Title: "Plain", Description: "d", Body: "a < b", BodyFormat: models.FormatPlaintext})
+	require.NoError(t, err)
+	assert.Equal(t, "<p>a &lt; b</p>\n", plain.BodyHTML)
+
+	revisions, err := store.ListRevisions(ctx, blog.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, models.FormatMarkdown, revisions[0].BodyFormat)
+	assert.Equal(t, blog.BodyHTML, revisions[0].BodyHTML, "revisions keep the HTML of their write")
+	assert.Equal(t, models.FormatHTML, revisions[1].BodyFormat)
+	assert.Equal(t, updated.BodyHTML, revisions[1].BodyHTML)
+
+	_, err = store.CreateBlog(ctx, models.BlogRequestBody{Title: "Other", Description: "d", Body: "b", BodyFormat: "rst"})
+	var validationErr *db.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []models.FieldError{{Field: "body_format", Message: "must be one of markdown, html, plaintext"}}, validationErr.Fields)
+}
+
+// tagged creates a blog with the given tags
+func tagged(t *testing.T, store db.BlogStore, n int, tags ...string) models.Blog {
+	t.Helper()
+	req := request(n)
+	req.Tags = tags
+	blog, err := store.CreateBlog(ctx, req)
+	require.NoError(t, err)
+	return blog
+}
+
+func testTags(t *testing.T, store db.BlogStore) {
+	plain := create(t, store, 1)
+	assert.NotNil(t, plain.Tags, "no tags list as [] rather than null")
+	assert.Empty(t, plain.Tags)
+	assert.NotNil(t, plain.Categories)
+
+	blog := tagged(t, store, 2, "Go", " web development ", "go", "Crème")
+	assert.Equal(t, []string{"creme", "go", "web-development"}, blog.Tags, "tags are normalized, deduplicated and sorted")
+	got, err := store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assertSameBlog(t, blog, got)
+	bySlug, err := store.GetBlogBySlug(ctx, blog.Slug)
+	require.NoError(t, err)
+	assert.Equal(t, blog.Tags, bySlug.Tags)
+
+	req := request(2)
+	req.Tags = []string{"testing"}
+	updated, err := store.UpdateBlog(ctx, blog.ID, req, db.AnyVersion)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testing"}, updated.Tags, "writes replace the tags")
+	published, err := store.TransitionBlog(ctx, blog.ID, models.StatusPublished, nil, db.AnyVersion)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testing"}, published.Tags, "transitions keep the tags")
+	require.NoError(t, store.DeleteBlog(ctx, blog.ID, db.AnyVersion))
+	restored, err := store.RestoreBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testing"}, restored.Tags, "the trash keeps the tags")
+	results, err := store.SearchBlogs(ctx, "title", db.SearchOptions{})
+	require.NoError(t, err)
+	for _, hit := range results.Items {
+		if hit.ID == blog.ID {
+			assert.Equal(t, []string{"testing"}, hit.Tags, "search hits carry their tags")
+		}
+	}
+
+	for _, tags := range [][]string{{"!!!"}, {strings.Repeat("x", db.MaxTagLength+1)}, manyTags(db.MaxTags + 1)} {
+		req.Tags = tags
+		_, err := store.CreateBlog(ctx, req)
+		var validationErr *db.ValidationError
+		require.ErrorAs(t, err, &validationErr, "tags %q", tags)
+		assert.Equal(t, "tags", validationErr.Fields[0].Field)
+	}
+	req.Tags = manyTags(db.MaxTags)
+	_, err = store.CreateBlog(ctx, req)
+	assert.NoError(t, err)
+}
+
+func manyTags(n int) []string {
+	tags := make([]string, n)
+	for i := range tags {
+		tags[i] = fmt.Sprintf("tag-%d", i)
+	}
+	return tags
+}
+
+func testTagFilter(t *testing.T, store db.BlogStore) {
+	first := tagged(t, store, 1, "go", "testing")
+	second := tagged(t, store, 2, "go")
+	tagged(t, store, 3, "rust")
+	trashed := tagged(t, store, 4, "go")
+	require.NoError(t, store.DeleteBlog(ctx, trashed.ID, db.AnyVersion))
+
+	page, err := store.GetAllBlogs(ctx, db.ListOptions{Tag: "Go"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{first.ID, second.ID}, ids(page.Items), "filters are normalized like tags")
+	assert.Equal(t, 2, page.Total)
+	assert.Equal(t, []string{"go", "testing"}, page.Items[0].Tags, "listed blogs carry all their tags")
+
+	page, err = store.GetAllBlogs(ctx, db.ListOptions{Tag: "go", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{first.ID}, ids(page.Items))
+	require.NotEmpty(t, page.NextCursor)
+	page, err = store.GetAllBlogs(ctx, db.ListOptions{Tag: "go", Limit: 1, After: page.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{second.ID}, ids(page.Items))
+	assert.Empty(t, page.NextCursor, "pages stay within the filter")
+
+	page, err = store.GetAllBlogs(ctx, db.ListOptions{Tag: "go", Deleted: true})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{trashed.ID}, ids(page.Items))
+	page, err = store.GetAllBlogs(ctx, db.ListOptions{Tag: "python"})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+	page, err = store.GetAllBlogs(ctx, db.ListOptions{Category: "nowhere"})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items, "unknown categories list nothing")
+
+	_, err = store.GetAllBlogs(ctx, db.ListOptions{Tag: "!!!"})
+	assert.ErrorIs(t, err, db.ErrValidation)
+}
+
+func testTagCounts(t *testing.T, store db.BlogStore) {
+	cloud, err := store.TagCounts(ctx, "")
+	require.NoError(t, err)
+	assert.NotNil(t, cloud)
+	assert.Empty(t, cloud)
+
+	tagged(t, store, 1, "go", "testing")
+	tagged(t, store, 2, "go", "rust")
+	published := tagged(t, store, 3, "go", "testing", "zig")
+	_, err = store.TransitionBlog(ctx, published.ID, models.StatusPublished, nil, db.AnyVersion)
+	require.NoError(t, err)
+	trashed := tagged(t, store, 4, "rust", "zig")
+	require.NoError(t, store.DeleteBlog(ctx, trashed.ID, db.AnyVersion))
+
+	cloud, err = store.TagCounts(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []models.TagCount{
+		{Tag: "go", Count: 3},
+		{Tag: "testing", Count: 2},
+		{Tag: "rust", Count: 1},
+		{Tag: "zig", Count: 1},
+	}, cloud, "most used first, ties by tag; trashed blogs do not count")
+
+	cloud, err = store.TagCounts(ctx, models.StatusPublished)
+	require.NoError(t, err)
+	assert.Equal(t, []models.TagCount{{Tag: "go", Count: 1}, {Tag: "testing", Count: 1}, {Tag: "zig", Count: 1}}, cloud)
+
+	_, err = store.TagCounts(ctx, "bogus")
+	assert.ErrorIs(t, err, db.ErrValidation)
+}
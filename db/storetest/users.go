@@ -0,0 +1,164 @@
+package storetest
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// StoreFactory returns a new, empty store holding both blogs and users. It
+// is called once per sub-test.
+type StoreFactory func(t *testing.T) db.Store
+
+// RunUsers executes the db.UserStore conformance suite, including blog
+// authorship, against stores built by newStore
+func RunUsers(t *testing.T, newStore StoreFactory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, store db.Store)
+	}{
+		{"Register", testRegister},
+		{"Invalid Registrations", testUserValidation},
+		{"Duplicate Usernames", testDuplicateUsernames},
+		{"Authenticate", testAuthenticate},
+		{"User Not Found", testUserNotFound},
+		{"Roles", testRoles},
+		{"Authors", testAuthors},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.fn(t, newStore(t))
+		})
+	}
+}
+
+func register(t *testing.T, store db.UserStore, username string) models.User {
+	t.Helper()
+	user, err := store.CreateUser(ctx, models.RegisterRequest{Username: username, Password: "password " + username})
+	require.NoError(t, err)
+	return user
+}
+
+func testRegister(t *testing.T, store db.Store) {
+	user, err := store.CreateUser(ctx, models.RegisterRequest{
+		Username: "  Alice ",
+		Password: "correct horse",
+		Name:     "Alice Liddell",
+	})
+	require.NoError(t, err)
+	assert.Positive(t, user.ID)
+	assert.Equal(t, "alice", user.Username, "usernames are normalized")
+	assert.Equal(t, "Alice Liddell", user.Name)
+	assert.False(t, user.CreatedAt.IsZero())
+
+	got, err := store.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+	assert.Equal(t, user.Username, got.Username)
+	assert.Equal(t, user.Name, got.Name)
+	assert.True(t, user.CreatedAt.Equal(got.CreatedAt))
+
+	other := register(t, store, "bob")
+	assert.NotEqual(t, user.ID, other.ID)
+}
+
+func testUserValidation(t *testing.T, store db.Store) {
+	_, err := store.CreateUser(ctx, models.RegisterRequest{Username: "a!", Password: "short"})
+	assert.ErrorIs(t, err, db.ErrValidation)
+	var validationErr *db.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	fields := make([]string, len(validationErr.Fields))
+	for i, f := range validationErr.Fields {
+		fields[i] = f.Field
+	}
+	assert.Equal(t, []string{"username", "password"}, fields, "every bad field is reported")
+
+	for _, req := range []models.RegisterRequest{
+		{Username: "ab", Password: "long enough"},
+		{Username: "-alice", Password: "long enough"},
+		{Username: "alice", Password: string(make([]byte, db.MaxPasswordLength+1))},
+	} {
+		_, err := store.CreateUser(ctx, req)
+		assert.ErrorIs(t, err, db.ErrValidation, "%+v", req)
+	}
+}
+
+func testDuplicateUsernames(t *testing.T, store db.Store) {
+	register(t, store, "alice")
+	_, err := store.CreateUser(ctx, models.RegisterRequest{Username: "ALICE", Password: "another password"})
+	assert.ErrorIs(t, err, db.ErrConflict)
+}
+
+func testAuthenticate(t *testing.T, store db.Store) {
+	alice := register(t, store, "alice")
+
+	user, err := store.Authenticate(ctx, "Alice", "password alice")
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, user.ID)
+
+	_, err = store.Authenticate(ctx, "alice", "wrong password")
+	assert.ErrorIs(t, err, db.ErrUnauthorized)
+	_, wrongUserErr := store.Authenticate(ctx, "nobody", "password alice")
+	assert.ErrorIs(t, wrongUserErr, db.ErrUnauthorized)
+	assert.Equal(t, err.Error(), wrongUserErr.Error(), "failures must not reveal which credential was wrong")
+}
+
+func testUserNotFound(t *testing.T, store db.Store) {
+	_, err := store.GetUser(ctx, 9999)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+}
+
+func testRoles(t *testing.T, store db.Store) {
+	alice := register(t, store, "alice")
+	assert.Equal(t, models.RoleReader, alice.Role, "new users are readers")
+
+	updated, err := store.SetUserRole(ctx, alice.ID, models.RoleEditor)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleEditor, updated.Role)
+	got, err := store.GetUser(ctx, alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleEditor, got.Role)
+	authenticated, err := store.Authenticate(ctx, "alice", "password alice")
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleEditor, authenticated.Role)
+
+	_, err = store.SetUserRole(ctx, alice.ID, "overlord")
+	assert.ErrorIs(t, err, db.ErrValidation)
+	_, err = store.SetUserRole(ctx, 9999, models.RoleAdmin)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+}
+
+func testAuthors(t *testing.T, store db.Store) {
+	alice := register(t, store, "alice")
+	anonymous := create(t, store, 1)
+	assert.Nil(t, anonymous.AuthorID)
+
+	blog, err := store.CreateBlog(db.WithAuthor(ctx, alice.ID), request(2))
+	require.NoError(t, err)
+	require.NotNil(t, blog.AuthorID)
+	assert.Equal(t, alice.ID, *blog.AuthorID)
+
+	bob := register(t, store, "bob")
+	updated, err := store.UpdateBlog(db.WithAuthor(ctx, bob.ID), blog.ID, request(3), db.AnyVersion)
+	require.NoError(t, err)
+	require.NotNil(t, updated.AuthorID)
+	assert.Equal(t, alice.ID, *updated.AuthorID, "edits do not change the author")
+
+	got, err := store.GetBlog(ctx, blog.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.AuthorID)
+	assert.Equal(t, alice.ID, *got.AuthorID)
+
+	require.NoError(t, store.DeleteBlog(ctx, blog.ID, db.AnyVersion))
+	author, err := store.BlogAuthor(ctx, blog.ID)
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, author, "trashed blogs keep their author")
+	author, err = store.BlogAuthor(ctx, anonymous.ID)
+	require.NoError(t, err)
+	assert.Zero(t, author)
+	_, err = store.BlogAuthor(ctx, 9999)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+}
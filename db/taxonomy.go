@@ -0,0 +1,106 @@
+package db
+
+import (
+	"blog_post/models"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gosimple/slug"
+)
+
+// Limits on the tags and categories of a blog
+const (
+	MaxTags           = 20
+	MaxTagLength      = 50
+	MaxBlogCategories = 10
+)
+
+// NormalizeTag folds a tag to the form it is stored and filtered in:
+// lowercased and transliterated to ASCII like slugs, so that "Go" and "go"
+// are the same tag. Tags without any letters or digits come back empty.
+func NormalizeTag(tag string) string {
+	return slug.Make(tag)
+}
+
+// normalizeCategory folds a category slug named by a blog or a filter
+func normalizeCategory(category string) string {
+	return strings.ToLower(strings.TrimSpace(category))
+}
+
+// normalizeTerms normalizes the tags and categories of blog in place,
+// sorted and without duplicates, appending what is wrong with them to
+// fields. Whether the categories exist is up to the store.
+func normalizeTerms(blog *models.BlogRequestBody, fields []models.FieldError) []models.FieldError {
+	tags := normalizeList(blog.Tags, NormalizeTag)
+	switch {
+	case slices.Contains(tags, ""):
+		fields = append(fields, models.FieldError{Field: "tags", Message: "must only hold tags with letters or digits"})
+	case slices.ContainsFunc(tags, func(tag string) bool { return len(tag) > MaxTagLength }):
+		fields = append(fields, models.FieldError{Field: "tags", Message: "must only hold tags of at most " + strconv.Itoa(MaxTagLength) + " characters"})
+	case len(tags) > MaxTags:
+		fields = append(fields, models.FieldError{Field: "tags", Message: "must hold at most " + strconv.Itoa(MaxTags) + " tags"})
+	}
+	categories := normalizeList(blog.Categories, normalizeCategory)
+	switch {
+	case slices.Contains(categories, ""):
+		fields = append(fields, models.FieldError{Field: "categories", Message: "must not hold blank slugs"})
+	case len(categories) > MaxBlogCategories:
+		fields = append(fields, models.FieldError{Field: "categories", Message: "must hold at most " + strconv.Itoa(MaxBlogCategories) + " categories"})
+	}
+	blog.Tags, blog.Categories = tags, categories
+	return fields
+}
+
+// normalizeList applies normalize to every value, then sorts the results
+// and drops duplicates. The result is never nil.
+func normalizeList(values []string, normalize func(string) string) []string {
+	normalized := make([]string, len(values))
+	for i, v := range values {
+		normalized[i] = normalize(v)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
+
+// unknownCategories reports the categories a blog named that do not exist
+func unknownCategories(missing []string) error {
+	return &ValidationError{Fields: []models.FieldError{
+		{Field: "categories", Message: "names unknown categories: " + strings.Join(missing, ", ")},
+	}}
+}
+
+// ValidateCategory normalizes req and checks it against the `validate` tags
+// of models.CategoryRequest, reporting every bad field at once
+func ValidateCategory(req *models.CategoryRequest) error {
+	return Validate(req)
+}
+
+// categorySlugFor picks the slug a category write should end up with: the
+// requested one, else the current one unless the name changed, else one
+// derived from the name. Unlike blog slugs, clashes are never resolved by
+// a suffix.
+func categorySlugFor(req models.CategoryRequest, old *models.Category) string {
+	switch {
+	case req.Slug != "":
+		return req.Slug
+	case old != nil && old.Name == req.Name:
+		return old.Slug
+	}
+	return Slugify(req.Name)
+}
+
+// sortTagCounts orders a tag cloud most used first, then by tag
+func sortTagCounts(counts map[string]int) []models.TagCount {
+	cloud := make([]models.TagCount, 0, len(counts))
+	for tag, n := range counts {
+		cloud = append(cloud, models.TagCount{Tag: tag, Count: n})
+	}
+	slices.SortFunc(cloud, func(a, b models.TagCount) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Tag, b.Tag)
+	})
+	return cloud
+}
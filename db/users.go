@@ -0,0 +1,77 @@
+package db
+
+import (
+	"blog_post/models"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MaxPasswordLength is the most bytes bcrypt hashes; longer passwords are
+// rejected so that no two passwords share a hash by truncation. It is the
+// maxbytes rule on models.RegisterRequest.
+const MaxPasswordLength = 72
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{2,31}$`)
+
+// NormalizeUsername folds a username to the form it is stored and looked up
+// in, making usernames case-insensitive
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// ValidateUser normalizes the username of a registration request and checks
+// the request against the `validate` tags of models.RegisterRequest,
+// reporting every bad field at once
+func ValidateUser(req *models.RegisterRequest) error {
+	req.Username = NormalizeUsername(req.Username)
+	return Validate(req)
+}
+
+// ValidateLogin checks a login request against the `validate` tags of
+// models.LoginRequest
+func ValidateLogin(req *models.LoginRequest) error {
+	return Validate(req)
+}
+
+// ValidRole reports whether role is one of the models.Role constants
+func ValidRole(role models.Role) bool {
+	switch role {
+	case models.RoleReader, models.RoleAuthor, models.RoleEditor, models.RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// roleFieldError describes an unknown role
+var roleFieldError = models.FieldError{Field: "role", Message: "must be one of reader, author, editor, admin"}
+
+// hashPassword returns the bcrypt hash stored in place of a password
+func hashPassword(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+}
+
+// dummyHash is compared against when a username does not exist, so that
+// failed logins take as long for unknown users as for wrong passwords. It
+// is hashed on the first login, whoever it is for, so that processes which
+// never authenticate do not pay for it.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := hashPassword("not a real password")
+	return hash
+})
+
+// checkPassword returns errBadCredentials unless password matches hash; a
+// nil hash stands for an unknown user and never matches
+func checkPassword(hash []byte, password string) error {
+	dummy := dummyHash()
+	if hash == nil {
+		bcrypt.CompareHashAndPassword(dummy, []byte(password))
+		return errBadCredentials
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return errBadCredentials
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"blog_post/models"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Validate checks the string fields of the struct v points to against their
+// `validate` tags, reporting every bad field at once as a ValidationError.
+// Fields are named by their json tag and report the first rule they break.
+// The rules, applied in the order they are listed, are
+//
+//	trim        strip surrounding whitespace from the field itself
+//	required    must not be blank
+//	min=N       at least N characters
+//	max=N       at most N characters
+//	minbytes=N  at least N bytes
+//	maxbytes=N  at most N bytes
+//	oneof=A B   one of the space-separated values
+//	chars=line  no control characters
+//	chars=text  no control characters other than tabs and newlines
+//	slug        lowercase letters, digits and single hyphens (see Slugify)
+//	username    3-32 lowercase letters, digits, '.', '_' or '-', starting
+//	            with a letter or digit
+//	url         an absolute http or https URL
+//
+// Every rule but required accepts an empty field. A malformed tag panics the
+// first time its type is validated.
+func Validate(v any) error {
+	value := reflect.ValueOf(v).Elem()
+	var fields []models.FieldError
+	for _, field := range rulesFor(value.Type()) {
+		target := value.Field(field.index)
+		for _, r := range field.rules {
+			if r.name == "trim" {
+				target.SetString(strings.TrimSpace(target.String()))
+				continue
+			}
+			if message := r.check(target.String()); message != "" {
+				fields = append(fields, models.FieldError{Field: field.name, Message: message})
+				break
+			}
+		}
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// fieldRules are the parsed `validate` tag of one struct field
+type fieldRules struct {
+	index int
+	name  string
+	rules []rule
+}
+
+type rule struct {
+	name string
+	arg  string
+	n    int // arg of min and max
+}
+
+// parsedRules caches rulesFor by reflect.Type
+var parsedRules sync.Map
+
+// rulesFor parses the `validate` tags of struct type t
+func rulesFor(t reflect.Type) []fieldRules {
+	if cached, ok := parsedRules.Load(t); ok {
+		return cached.([]fieldRules)
+	}
+	var parsed []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+		if f.Type.Kind() != reflect.String {
+			panic(fmt.Sprintf("validate: %s.%s is not a string", t.Name(), f.Name))
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" {
+			name = f.Name
+		}
+		field := fieldRules{index: i, name: name}
+		for _, spec := range strings.Split(tag, ",") {
+			field.rules = append(field.rules, parseRule(t, f.Name, spec))
+		}
+		parsed = append(parsed, field)
+	}
+	parsedRules.Store(t, parsed)
+	return parsed
+}
+
+func parseRule(t reflect.Type, field, spec string) rule {
+	name, arg, _ := strings.Cut(spec, "=")
+	r := rule{name: name, arg: arg}
+	var err error
+	switch name {
+	case "trim", "required", "slug", "username", "url":
+		if arg != "" {
+			err = fmt.Errorf("takes no argument")
+		}
+	case "min", "max", "minbytes", "maxbytes":
+		r.n, err = strconv.Atoi(arg)
+	case "oneof":
+		if strings.TrimSpace(arg) == "" {
+			err = fmt.Errorf("needs values")
+		}
+	case "chars":
+		if arg != "line" && arg != "text" {
+			err = fmt.Errorf("must be line or text")
+		}
+	default:
+		err = fmt.Errorf("unknown rule")
+	}
+	if err != nil {
+		panic(fmt.Sprintf("validate: %s.%s: %q %v", t.Name(), field, spec, err))
+	}
+	return r
+}
+
+// check returns why s breaks the rule, or "" if it does not
+func (r rule) check(s string) string {
+	if r.name == "required" {
+		if strings.TrimSpace(s) == "" {
+			return "is required"
+		}
+		return ""
+	}
+	if s == "" {
+		return ""
+	}
+	switch r.name {
+	case "min":
+		if utf8.RuneCountInString(s) < r.n {
+			return fmt.Sprintf("must be at least %d characters", r.n)
+		}
+	case "max":
+		if utf8.RuneCountInString(s) > r.n {
+			return fmt.Sprintf("must be at most %d characters", r.n)
+		}
+	case "minbytes":
+		if len(s) < r.n {
+			return fmt.Sprintf("must be at least %d bytes long", r.n)
+		}
+	case "maxbytes":
+		if len(s) > r.n {
+			return fmt.Sprintf("must be at most %d bytes long", r.n)
+		}
+	case "oneof":
+		values := strings.Fields(r.arg)
+		if !slices.Contains(values, s) {
+			return "must be one of " + strings.Join(values, ", ")
+		}
+	case "chars":
+		allowed := func(ch rune) bool { return !unicode.IsControl(ch) }
+		message := "must not contain control characters or line breaks"
+		if r.arg == "text" {
+			allowed = func(ch rune) bool { return !unicode.IsControl(ch) || ch == '\t' || ch == '\n' || ch == '\r' }
+			message = "must not contain control characters other than tabs and line breaks"
+		}
+		if !utf8.ValidString(s) || strings.IndexFunc(s, func(ch rune) bool { return !allowed(ch) }) >= 0 {
+			return message
+		}
+	case "slug":
+		if !validSlug(s) {
+			return slugFieldError.Message
+		}
+	case "url":
+		u, err := url.Parse(s)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "must be an absolute http or https URL"
+		}
+	case "username":
+		if !usernamePattern.MatchString(s) {
+			return "must be 3-32 lowercase letters, digits, '.', '_' or '-', starting with a letter or digit"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"blog_post/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	type request struct {
+		Name     string            `json:"name" validate:"trim,required,min=3,max=5,chars=line"`
+		Notes    string            `json:"notes,omitempty" validate:"chars=text"`
+		Homepage string            `json:"homepage" validate:"url"`
+		Login    string            `json:"login" validate:"username"`
+		Secret   string            `json:"secret" validate:"minbytes=2,maxbytes=4"`
+		Slug     string            `validate:"slug"`
+		Format   models.BodyFormat `json:"format" validate:"oneof=markdown html"`
+		Ignored  string            `json:"ignored"`
+	}
+	tests := []struct {
+		description string
+		req         request
+		errors      []models.FieldError
+	}{
+		{"valid", request{Name: "alice", Notes: "line one\n\tline two", Homepage: "https://example.com/a", Login: "alice.l", Secret: "pass", Slug: "a-b"}, nil},
+		{"optional fields left empty", request{Name: "bob"}, nil},
+		{"trimmed before the other rules", request{Name: "  alice  "}, nil},
+		{"blank", request{Name: " \t"}, []models.FieldError{{Field: "name", Message: "is required"}}},
+		{"too short", request{Name: "al"}, []models.FieldError{{Field: "name", Message: "must be at least 3 characters"}}},
+		{"too long", request{Name: "alice!"}, []models.FieldError{{Field: "name", Message: "must be at most 5 characters"}}},
+		{"length in characters", request{Name: "élève"}, nil},
+		{"line break in a line", request{Name: "al\nce"}, []models.FieldError{{Field: "name", Message: "must not contain control characters or line breaks"}}},
+		{"control character in text", request{Name: "alice", Notes: "bell\a"}, []models.FieldError{{Field: "notes", Message: "must not contain control characters other than tabs and line breaks"}}},
+		{"invalid UTF-8", request{Name: "alice", Notes: "\xff"}, []models.FieldError{{Field: "notes", Message: "must not contain control characters other than tabs and line breaks"}}},
+		{"relative URL", request{Name: "alice", Homepage: "/about"}, []models.FieldError{{Field: "homepage", Message: "must be an absolute http or https URL"}}},
+		{"other scheme", request{Name: "alice", Homepage: "javascript:alert(1)"}, []models.FieldError{{Field: "homepage", Message: "must be an absolute http or https URL"}}},
+		{"bad username", request{Name: "alice", Login: "-alice"}, []models.FieldError{{Field: "login", Message: "must be 3-32 lowercase letters, digits, '.', '_' or '-', starting with a letter or digit"}}},
+		{"too few bytes", request{Name: "alice", Secret: "p"}, []models.FieldError{{Field: "secret", Message: "must be at least 2 bytes long"}}},
+		{"length in bytes", request{Name: "alice", Secret: "éé!"}, []models.FieldError{{Field: "secret", Message: "must be at most 4 bytes long"}}},
+		{"one of", request{Name: "alice", Format: models.FormatHTML}, nil},
+		{"none of", request{Name: "alice", Format: models.FormatPlaintext}, []models.FieldError{{Field: "format", Message: "must be one of markdown, html"}}},
+		{"field without a json name", request{Name: "alice", Slug: "A B"}, []models.FieldError{{Field: "Slug", Message: slugFieldError.Message}}},
+		{"every bad field", request{Homepage: "nope", Login: "A", Slug: "-"}, []models.FieldError{
+			{Field: "name", Message: "is required"},
+			{Field: "homepage", Message: "must be an absolute http or https URL"},
+			{Field: "login", Message: "must be 3-32 lowercase letters, digits, '.', '_' or '-', starting with a letter or digit"},
+			{Field: "Slug", Message: slugFieldError.Message},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := Validate(&test.req)
+			if test.errors == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, test.errors, validationErr.Fields)
+		})
+	}
+
+	req := request{Name: " alice "}
+	require.NoError(t, Validate(&req))
+	assert.Equal(t, "alice", req.Name, "trim rewrites the field")
+}
+
+func TestValidateBadTags(t *testing.T) {
+	assert.Panics(t, func() {
+		Validate(&struct {
+			Name string `validate:"requird"`
+		}{})
+	})
+	assert.Panics(t, func() {
+		Validate(&struct {
+			Name string `validate:"max=ten"`
+		}{})
+	})
+	assert.Panics(t, func() {
+		Validate(&struct {
+			Name string `validate:"oneof="`
+		}{})
+	})
+	assert.Panics(t, func() {
+		Validate(&struct {
+			Count int `validate:"required"`
+		}{})
+	})
+}
+
+func TestValidateBlog(t *testing.T) {
+	blog := models.BlogRequestBody{
+		Title:       strings.Repeat("t", 201),
+		Description: "line\nbreak",
+		Body:        strings.Repeat("b", 100001),
+		Slug:        "Not A Slug",
+	}
+	err := ValidateBlog(&blog)
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	fields := make([]string, len(validationErr.Fields))
+	for i, f := range validationErr.Fields {
+		fields[i] = f.Field
+	}
+	assert.Equal(t, []string{"title", "description", "body", "slug"}, fields)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"blog_post/db"
+	"context"
+	"time"
+
+	m "blog_post/middlewares"
+
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// every runs job right away and then once per interval until ctx is done
+func every(ctx context.Context, interval time.Duration, job func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runPurger permanently removes blogs that have been in the trash for
+// longer than retention, checking every interval until ctx is done. A
+// non-positive retention or interval disables purging.
+func runPurger(ctx context.Context, store db.BlogStore, retention, interval time.Duration) {
+	if retention <= 0 || interval <= 0 {
+		log.Info("Trash purging is disabled")
+		return
+	}
+	every(ctx, interval, func() { purgeTrash(ctx, store, retention) })
+}
+
+// purgeTrash runs a single purge pass, logging rather than failing so that
+// one bad pass does not stop the purger
+func purgeTrash(ctx context.Context, store db.BlogStore, retention time.Duration) int {
+	n, err := store.PurgeDeleted(ctx, time.Now().Add(-retention))
+	if err != nil {
+		log.Errorf("Purging trash failed: %v", err)
+		return 0
+	}
+	if n > 0 {
+		log.Infof("Purged %d blog(s) from the trash", n)
+	}
+	return n
+}
+
+// runScheduler publishes scheduled blogs once their publish_at has passed,
+// checking every interval until ctx is done. A non-positive interval
+// disables the scheduler.
+func runScheduler(ctx context.Context, store db.BlogStore, interval time.Duration) {
+	if interval <= 0 {
+		log.Info("Scheduled publishing is disabled")
+		return
+	}
+	ctx = db.WithActor(ctx, "scheduler")
+	every(ctx, interval, func() { publishDue(ctx, store) })
+}
+
+// publishDue runs a single scheduler pass, logging rather than failing
+func publishDue(ctx context.Context, store db.BlogStore) int {
+	n, err := store.PublishDue(ctx, time.Now())
+	if err != nil {
+		log.Errorf("Publishing scheduled blogs failed: %v", err)
+		return 0
+	}
+	if n > 0 {
+		log.Infof("Published %d scheduled blog(s)", n)
+	}
+	return n
+}
+
+// refreshKeys reloads the JWT key set every interval until ctx is done, so
+// that keys the issuer retires stop being accepted. A non-positive interval
+// disables it.
+func refreshKeys(ctx context.Context, keys *m.KeySet, interval time.Duration) {
+	if interval <= 0 {
+		log.Info("JWT key refreshing is disabled")
+		return
+	}
+	every(ctx, interval, func() {
+		if err := keys.Refresh(ctx); err != nil {
+			log.Errorf("Refreshing JWT keys failed: %v", err)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurger(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewRepo()
+	blog, err := store.CreateBlog(ctx, models.BlogRequestBody{Title: "Title", Description: "Description", Body: "Body"})
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteBlog(ctx, blog.ID, db.AnyVersion))
+
+	t.Run("Keeps blogs within retention", func(t *testing.T) {
+		assert.Zero(t, purgeTrash(ctx, store, time.Hour))
+	})
+	t.Run("Purges expired blogs", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			runPurger(ctx, store, time.Nanosecond, time.Millisecond)
+			close(done)
+		}()
+		assert.Eventually(t, func() bool {
+			trash, err := store.GetAllBlogs(ctx, db.ListOptions{Deleted: true})
+			return err == nil && trash.Total == 0
+		}, time.Second, 5*time.Millisecond)
+		cancel()
+		<-done
+	})
+	t.Run("Disabled", func(t *testing.T) {
+		// returns immediately instead of blocking
+		runPurger(ctx, store, 0, time.Millisecond)
+	})
+}
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewRepo()
+	blog, err := store.CreateBlog(ctx, models.BlogRequestBody{Title: "Title", Description: "Description", Body: "Body"})
+	require.NoError(t, err)
+	at := time.Now().Add(20 * time.Millisecond)
+	_, err = store.TransitionBlog(ctx, blog.ID, models.StatusScheduled, &at, db.AnyVersion)
+	require.NoError(t, err)
+
+	t.Run("Publishes due blogs", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			runScheduler(ctx, store, time.Millisecond)
+			close(done)
+		}()
+		assert.Eventually(t, func() bool {
+			got, err := store.GetBlog(ctx, blog.ID)
+			return err == nil && got.Status == models.StatusPublished
+		}, time.Second, 5*time.Millisecond)
+		cancel()
+		<-done
+
+		revisions, err := store.ListRevisions(ctx, blog.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "scheduler", revisions[len(revisions)-1].Author)
+	})
+	t.Run("Nothing due", func(t *testing.T) {
+		assert.Zero(t, publishDue(ctx, store))
+	})
+	t.Run("Disabled", func(t *testing.T) {
+		runScheduler(ctx, store, 0)
+	})
+}
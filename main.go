@@ -2,6 +2,10 @@ package main
 
 import (
 	"blog_post/api"
+	"blog_post/db"
+	"context"
+	"fmt"
+	"os"
 
 	_ "blog_post/docs"
 	m "blog_post/middlewares"
@@ -16,13 +20,37 @@ import (
 //	@title	Blog API
 
 // @version		1.0
+// @description	Failed requests return an RFC 7807 application/problem+json body (models.Problem).
+// @description	Write endpoints require HTTP Basic credentials of a registered user, a bearer JWT or an API key,
+// @description	and a role the access policy allows: authors write their own blogs, editors any blog and categories, admins everything.
 // @contact.name	Ayush Shukla
 // @contact.email	ayush.shukla8797@gmail.com
 // @host			quartiz-blog-post.onrender.com
 // @BasePath		/api/v1
 // @Schemes https
+// @securityDefinitions.basic BasicAuth
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT as "Bearer <token>"
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key of a machine client
 func main() {
 	initConfig()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatalf("migrate failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "grant" {
+		if err := runGrant(os.Args[2:]); err != nil {
+			log.Fatalf("grant failed: %v", err)
+		}
+		return
+	}
 	siteURL := viper.GetString("SITE_URL")
 	if siteURL == "" {
 		log.Error("SITE_URL is not set. Please configure it in .env file or environment variables.")
@@ -32,7 +60,34 @@ func main() {
 		port = "8080"
 	}
 
-	app := setup()
+	store, err := newStore()
+	if err != nil {
+		log.Fatalf("Error opening store: %v", err)
+	}
+	if viper.GetBool("DB_AUTO_MIGRATE") {
+		if err := autoMigrate(context.Background(), store); err != nil {
+			log.Fatalf("Error migrating store: %v", err)
+		}
+	}
+
+	go runPurger(context.Background(), store,
+		viper.GetDuration("TRASH_RETENTION"), viper.GetDuration("TRASH_PURGE_INTERVAL"))
+	go runScheduler(context.Background(), store, viper.GetDuration("PUBLISH_INTERVAL"))
+
+	jwtCfg, err := jwtConfig(context.Background())
+	if err != nil {
+		log.Fatalf("Error configuring JWT authentication: %v", err)
+	}
+	if jwtCfg.Keys != nil {
+		go refreshKeys(context.Background(), jwtCfg.Keys, viper.GetDuration("JWT_JWKS_REFRESH"))
+	}
+
+	policy, err := loadPolicy()
+	if err != nil {
+		log.Fatalf("Error loading access policy: %v", err)
+	}
+
+	app := setup(store, jwtCfg, policy, viper.GetInt("MAX_BODY_SIZE"))
 	log.Info("Listening at port: " + siteURL + port)
 	log.Fatal(app.Listen(":" + port))
 }
@@ -40,26 +95,99 @@ func main() {
 func initConfig() {
 	viper.SetConfigFile(".env")
 	viper.AutomaticEnv()
+	viper.SetDefault("DB_AUTO_MIGRATE", true)
+	viper.SetDefault("TRASH_RETENTION", "720h")
+	viper.SetDefault("TRASH_PURGE_INTERVAL", "1h")
+	viper.SetDefault("PUBLISH_INTERVAL", "1m")
+	viper.SetDefault("JWT_LEEWAY", "30s")
+	viper.SetDefault("JWT_TOKEN_TTL", "1h")
+	viper.SetDefault("JWT_JWKS_REFRESH", "1h")
+	viper.SetDefault("MAX_BODY_SIZE", api.DefaultBodyLimit)
 
 	if err := viper.ReadInConfig(); err != nil {
 		log.Error("Error reading config file: %v", err)
 	}
 }
 
-func setup() *fiber.App {
-	app := fiber.New()
+// newStore builds the Store selected by DB_DRIVER, defaulting to the
+// in-memory repo
+func newStore() (db.Store, error) {
+	switch driver := viper.GetString("DB_DRIVER"); driver {
+	case "", "memory":
+		return db.NewRepo(), nil
+	case "sqlite":
+		path := viper.GetString("DB_PATH")
+		if path == "" {
+			path = "blog.db"
+		}
+		return db.NewSQLiteRepo(path)
+	case "postgres":
+		dsn := viper.GetString("DATABASE_URL")
+		if dsn == "" {
+			return nil, fmt.Errorf("DATABASE_URL is required when DB_DRIVER=postgres")
+		}
+		return db.NewPostgresRepo(dsn)
+	default:
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q", driver)
+	}
+}
+
+func setup(store db.Store, jwtCfg m.JWTConfig, policy m.Policy, bodyLimit int) *fiber.App {
+	authorizer := m.NewAuthorizer(policy, store)
+	h := api.NewHandler(store)
+	h.Visibility = authorizer
+	h.Tokens = jwtCfg
+	can := authorizer.Require
+	app := fiber.New(fiber.Config{
+		ErrorHandler: api.ErrorHandler,
+		BodyLimit:    bodyLimit,
+	})
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
 		AllowMethods: "GET,POST,PUT,DELETE,PATCH",
 		AllowHeaders: "*",
+		// Let browsers read the pagination links, the ETag to send back in
+		// If-Match and where created or moved resources live
+		ExposeHeaders: "ETag,Link,Location",
 	}))
-	router := app.Group("/api/v1")
+	router := app.Group("/api/v1", m.BasicAuth(store), m.JWT(jwtCfg, store), m.APIKeyAuth(store))
 	app.Get("/swagger/*", swagger.HandlerDefault) // default
-	router.Get("/blog-posts", api.GetAllBlogs)
-	router.Post("/blog-post", m.VerifyBlogFields, api.CreateBlog)
-	router.Get("/blog-post/:id<min(1)>", api.GetBlog)
-	router.Put("/blog-post/:id<min(1)>", api.UpdateBlog)
-	router.Delete("/blog-post/:id<min(1)>", api.DeleteBlog)
+	router.Post("/users", h.Register)
+	router.Get("/users/:id<min(1)>", h.GetUser)
+	router.Put("/users/:id<min(1)>/role", can(m.ActionManageUsers), h.SetUserRole)
+	router.Post("/api-keys", can(m.ActionManageKeys), h.CreateAPIKey)
+	router.Get("/api-keys", can(m.ActionManageKeys), h.ListAPIKeys)
+	router.Delete("/api-keys/:id<min(1)>", can(m.ActionManageKeys), h.RevokeAPIKey)
+	router.Post("/login", h.Login)
+	router.Get("/blog-posts", h.GetAllBlogs)
+	router.Get("/tags", h.TagCloud)
+	router.Get("/categories", h.ListCategories)
+	router.Get("/categories/:id<min(1)>", h.GetCategory)
+	router.Post("/categories", can(m.ActionManageCategories), h.CreateCategory)
+	router.Put("/categories/:id<min(1)>", can(m.ActionManageCategories), h.UpdateCategory)
+	router.Delete("/categories/:id<min(1)>", can(m.ActionManageCategories), h.DeleteCategory)
+	router.Post("/blog-post", can(m.ActionCreateBlog), m.VerifyBlogFields, h.CreateBlog)
+	router.Get("/blog-post/by-slug/:slug", h.GetBlogBySlug)
+	router.Get("/blog-post/:id<min(1)>", h.GetBlog)
+	router.Put("/blog-post/:id<min(1)>", can(m.ActionUpdateBlog), m.VerifyBlogFields, h.UpdateBlog)
+	router.Patch("/blog-post/:id<min(1)>", can(m.ActionUpdateBlog), h.PatchBlog)
+	router.Delete("/blog-post/:id<min(1)>", can(m.ActionDeleteBlog), h.DeleteBlog)
+	router.Post("/blog-post/:id<min(1)>/publish", can(m.ActionPublishBlog), h.PublishBlog)
+	router.Post("/blog-post/:id<min(1)>/schedule", can(m.ActionPublishBlog), h.ScheduleBlog)
+	router.Post("/blog-post/:id<min(1)>/unpublish", can(m.ActionPublishBlog), h.UnpublishBlog)
+	router.Post("/blog-post/:id<min(1)>/archive", can(m.ActionPublishBlog), h.ArchiveBlog)
+	router.Post("/blog-post/:id<min(1)>/restore", can(m.ActionRestoreBlog), h.RestoreBlog)
+	router.Delete("/blog-post/:id<min(1)>/purge", can(m.ActionPurgeBlog), h.PurgeBlog)
+	router.Get("/blog-post/:id<min(1)>/revisions", h.ListRevisions)
+	router.Get("/blog-post/:id<min(1)>/revisions/diff", h.DiffRevisions)
+	router.Get("/blog-post/:id<min(1)>/revisions/:version<min(1)>", h.GetRevision)
+	router.Post("/blog-post/:id<min(1)>/revisions/:version<min(1)>/restore", can(m.ActionUpdateBlog), h.RestoreRevision)
+	router.Get("/blog-post/:id<min(1)>/comments", h.ListComments)
+	router.Post("/blog-post/:id<min(1)>/comments", can(m.ActionCreateComment), h.CreateComment)
+	router.Put("/blog-post/:id<min(1)>/comments/:commentId<min(1)>", can(m.ActionUpdateComment), h.UpdateComment)
+	router.Delete("/blog-post/:id<min(1)>/comments/:commentId<min(1)>", can(m.ActionDeleteComment), h.DeleteComment)
+	router.Get("/comments", can(m.ActionModerateComments), h.ModerationQueue)
+	router.Put("/comments/:commentId<min(1)>/status", can(m.ActionModerateComments), h.ModerateComment)
 
 	return app
 }
@@ -1,11 +1,24 @@
 package main
 
 import (
+	"blog_post/api"
+	"blog_post/db"
+	"blog_post/models"
+	"context"
+	"encoding/json"
 	"io"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	m "blog_post/middlewares"
+
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMain1(t *testing.T) {
@@ -20,7 +33,7 @@ func TestMain1(t *testing.T) {
 			description:   "get all blogs",
 			route:         "/api/v1/blog-posts",
 			expectedError: false,
-			expectedCode:  500,
+			expectedCode:  200,
 			expectedBody:  "",
 		},
 		{
@@ -28,11 +41,11 @@ func TestMain1(t *testing.T) {
 			route:         "/i-dont-exist",
 			expectedError: false,
 			expectedCode:  404,
-			expectedBody:  "Cannot GET /i-dont-exist",
+			expectedBody:  `{"type":"about:blank","title":"Not Found","status":404,"detail":"Cannot GET /i-dont-exist","instance":"/i-dont-exist"}`,
 		},
 	}
 
-	app := setup()
+	app := setup(db.NewRepo(), m.JWTConfig{}, m.DefaultPolicy, api.DefaultBodyLimit)
 
 	for _, test := range tests {
 		req, _ := http.NewRequest(http.MethodGet, test.route, nil)
@@ -45,3 +58,308 @@ func TestMain1(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteRoutesRequireAuth(t *testing.T) {
+	store := db.NewRepo()
+	alice, err := store.CreateUser(context.Background(), models.RegisterRequest{Username: "alice", Password: "correct horse"})
+	require.NoError(t, err)
+	_, err = store.SetUserRole(context.Background(), alice.ID, models.RoleAuthor)
+	require.NoError(t, err)
+	secret := []byte("test secret")
+	app := setup(store, m.JWTConfig{Secret: secret}, m.DefaultPolicy, api.DefaultBodyLimit)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   strconv.FormatInt(alice.ID, 10),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}).SignedString(secret)
+	require.NoError(t, err)
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(`{"username":"alice","password":"correct horse"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var login models.LoginResponse
+	json.NewDecoder(resp.Body).Decode(&login)
+
+	create := func(authorize func(*http.Request)) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/blog-post",
+			strings.NewReader(`{"title":"Title","description":"Description","body":"Body"}`))
+		req.Header.Set("Content-Type", "application/json")
+		authorize(req)
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp = create(func(*http.Request) {})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	for name, authorize := range map[string]func(*http.Request){
+		"basic":  func(req *http.Request) { req.SetBasicAuth("alice", "correct horse") },
+		"bearer": func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+token) },
+		"login":  func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+login.AccessToken) },
+	} {
+		resp := create(authorize)
+		require.Equal(t, http.StatusCreated, resp.StatusCode, name)
+		var blog models.Blog
+		json.NewDecoder(resp.Body).Decode(&blog)
+		if assert.NotNil(t, blog.AuthorID, name) {
+			assert.Equal(t, alice.ID, *blog.AuthorID, name)
+		}
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/v1/blog-posts", nil)
+	resp, err = app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "reads stay public")
+}
+
+func TestWriteRoutesEnforcePolicy(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewRepo()
+	users := map[string]models.Role{
+		"alice": models.RoleAuthor,
+		"bob":   models.RoleAuthor,
+		"carol": models.RoleEditor,
+		"dave":  models.RoleReader,
+		"erin":  models.RoleAdmin,
+	}
+	ids := make(map[string]int64)
+	for username, role := range users {
+		user, err := store.CreateUser(ctx, models.RegisterRequest{Username: username, Password: "password " + username})
+		require.NoError(t, err)
+		_, err = store.SetUserRole(ctx, user.ID, role)
+		require.NoError(t, err)
+		ids[username] = user.ID
+	}
+	app := setup(store, m.JWTConfig{}, m.DefaultPolicy, api.DefaultBodyLimit)
+	call := func(username, method, route, body string) *http.Response {
+		req, _ := http.NewRequest(method, route, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth(username, "password "+username)
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		return resp
+	}
+	const blogBody = `{"title":"Title","description":"Description","body":"Body"}`
+
+	resp := call("dave", http.MethodPost, "/api/v1/blog-post", blogBody)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "readers cannot write")
+
+	resp = call("alice", http.MethodPost, "/api/v1/blog-post", blogBody)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var blog models.Blog
+	json.NewDecoder(resp.Body).Decode(&blog)
+	route := "/api/v1/blog-post/" + strconv.FormatInt(blog.ID, 10)
+
+	resp = call("bob", http.MethodPut, route, blogBody)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "authors cannot edit others' blogs")
+	resp = call("alice", http.MethodPut, route, blogBody)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = call("carol", http.MethodPost, route+"/publish", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "editors may publish any blog")
+	resp = call("alice", http.MethodDelete, route, "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = call("carol", http.MethodDelete, route+"/purge", "")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "only admins purge")
+
+	roleRoute := "/api/v1/users/" + strconv.FormatInt(ids["dave"], 10) + "/role"
+	resp = call("carol", http.MethodPut, roleRoute, `{"role":"author"}`)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = call("erin", http.MethodPut, roleRoute, `{"role":"author"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var user models.User
+	json.NewDecoder(resp.Body).Decode(&user)
+	assert.Equal(t, models.RoleAuthor, user.Role)
+	resp = call("dave", http.MethodPost, "/api/v1/blog-post", blogBody)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode, "the new role applies immediately")
+
+	resp = call("alice", http.MethodPost, "/api/v1/categories", `{"name":"News"}`)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "authors cannot manage categories")
+	resp = call("carol", http.MethodPost, "/api/v1/categories", `{"name":"News"}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode, "editors may")
+	resp = call("carol", http.MethodDelete, "/api/v1/categories/1", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	published, err := store.CreateBlog(ctx, models.BlogRequestBody{Title: "Open", Description: "Description", Body: "Body"})
+	require.NoError(t, err)
+	_, err = store.TransitionBlog(ctx, published.ID, models.StatusPublished, nil, db.AnyVersion)
+	require.NoError(t, err)
+	comments := "/api/v1/blog-post/" + strconv.FormatInt(published.ID, 10) + "/comments"
+	resp = call("dave", http.MethodPost, comments, `{"body":"Nice"}`)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, "every user may comment")
+	var comment models.Comment
+	json.NewDecoder(resp.Body).Decode(&comment)
+	commentRoute := comments + "/" + strconv.FormatInt(comment.ID, 10)
+	resp = call("bob", http.MethodPut, commentRoute, `{"body":"Hijacked"}`)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "users only edit their own comments")
+	resp = call("dave", http.MethodPut, commentRoute, `{"body":"Nicer"}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = call("dave", http.MethodGet, "/api/v1/comments", "")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "only moderators see the queue")
+	resp = call("carol", http.MethodPut, "/api/v1/comments/"+strconv.FormatInt(comment.ID, 10)+"/status", `{"status":"spam"}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = call("bob", http.MethodDelete, commentRoute, "")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = call("carol", http.MethodDelete, commentRoute, "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "editors may delete any comment")
+}
+
+func TestReadRoutesHideContent(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewRepo()
+	ids := make(map[string]int64)
+	for username, role := range map[string]models.Role{
+		"alice": models.RoleAuthor,
+		"bob":   models.RoleAuthor,
+		"carol": models.RoleEditor,
+		"dave":  models.RoleReader,
+	} {
+		user, err := store.CreateUser(ctx, models.RegisterRequest{Username: username, Password: "password " + username})
+		require.NoError(t, err)
+		_, err = store.SetUserRole(ctx, user.ID, role)
+		require.NoError(t, err)
+		ids[username] = user.ID
+	}
+	app := setup(store, m.JWTConfig{}, m.DefaultPolicy, api.DefaultBodyLimit)
+	call := func(username, route string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, route, nil)
+		if username != "" {
+			req.SetBasicAuth(username, "password "+username)
+		}
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		return resp
+	}
+	trashed, err := store.CreateBlog(ctx, models.BlogRequestBody{Title: "Trashed", Description: "Description", Body: "Body"})
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteBlog(ctx, trashed.ID, db.AnyVersion))
+
+	resp := call("", "/api/v1/blog-posts?deleted=true")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "the trash is not public")
+	resp = call("dave", "/api/v1/blog-posts?deleted=true")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "readers cannot list the trash")
+	resp = call("carol", "/api/v1/blog-posts?deleted=true")
+	require.Equal(t, http.StatusOK, resp.StatusCode, "editors may")
+	var trash models.BlogList
+	json.NewDecoder(resp.Body).Decode(&trash)
+	assert.Len(t, trash.Items, 1)
+
+	draft, err := store.CreateBlog(db.WithAuthor(ctx, ids["alice"]), models.BlogRequestBody{Title: "Draft", Description: "Description", Body: "Body"})
+	require.NoError(t, err)
+	route := "/api/v1/blog-post/" + strconv.FormatInt(draft.ID, 10)
+	for _, target := range []string{route, "/api/v1/blog-post/by-slug/" + draft.Slug, route + "/revisions", route + "/revisions/1", route + "/revisions/diff?from=1", route + "/comments"} {
+		for _, username := range []string{"", "dave", "bob"} {
+			resp = call(username, target)
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode, "%s as %q", target, username)
+		}
+		for _, username := range []string{"alice", "carol"} {
+			resp = call(username, target)
+			assert.Equal(t, http.StatusOK, resp.StatusCode, "%s as %q", target, username)
+		}
+	}
+
+	for _, query := range []string{"status=draft", "status=all", "q=draft&status=draft"} {
+		resp = call("", "/api/v1/blog-posts?"+query)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, query)
+		resp = call("dave", "/api/v1/blog-posts?"+query)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, query)
+		resp = call("alice", "/api/v1/blog-posts?"+query)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, "%s: listings span every author", query)
+		resp = call("carol", "/api/v1/blog-posts?"+query)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, query)
+	}
+	resp = call("", "/api/v1/blog-posts?status=published")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = call("", "/api/v1/tags?status=all")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "tag counts do not reveal drafts")
+	resp = call("carol", "/api/v1/tags?status=all")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestAPIKeyClients(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewRepo()
+	admin, err := store.CreateUser(ctx, models.RegisterRequest{Username: "erin", Password: "password erin"})
+	require.NoError(t, err)
+	_, err = store.SetUserRole(ctx, admin.ID, models.RoleAdmin)
+	require.NoError(t, err)
+	app := setup(store, m.JWTConfig{}, m.DefaultPolicy, api.DefaultBodyLimit)
+	call := func(authorize func(*http.Request), method, route, body string, out any) *http.Response {
+		req, _ := http.NewRequest(method, route, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		authorize(req)
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		if out != nil {
+			json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp
+	}
+	asAdmin := func(req *http.Request) { req.SetBasicAuth("erin", "password erin") }
+	withKey := func(key string) func(*http.Request) {
+		return func(req *http.Request) { req.Header.Set(m.HeaderAPIKey, key) }
+	}
+	const blogBody = `{"title":"Title","description":"Description","body":"Body"}`
+
+	var writeKey, readKey models.NewAPIKey
+	resp := call(asAdmin, http.MethodPost, "/api/v1/api-keys", `{"name":"ci-importer","scope":"write"}`, &writeKey)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp = call(asAdmin, http.MethodPost, "/api/v1/api-keys", `{"name":"dashboard","scope":"read"}`, &readKey)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var blog models.Blog
+	resp = call(withKey(writeKey.Key), http.MethodPost, "/api/v1/blog-post", blogBody, &blog)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Nil(t, blog.AuthorID, "keys are not users")
+	resp = call(withKey(writeKey.Key), http.MethodPost, "/api/v1/blog-post/"+strconv.FormatInt(blog.ID, 10)+"/publish", "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var revisions models.RevisionList
+	call(withKey(writeKey.Key), http.MethodGet, "/api/v1/blog-post/"+strconv.FormatInt(blog.ID, 10)+"/revisions", "", &revisions)
+	if assert.NotEmpty(t, revisions.Items) {
+		assert.Equal(t, "apikey:ci-importer", revisions.Items[0].Author)
+	}
+
+	resp = call(withKey(readKey.Key), http.MethodPost, "/api/v1/blog-post", blogBody, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "read keys cannot write")
+	resp = call(withKey(writeKey.Key), http.MethodGet, "/api/v1/api-keys", "", nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "only admins manage keys")
+
+	resp = call(asAdmin, http.MethodDelete, "/api/v1/api-keys/"+strconv.FormatInt(writeKey.ID, 10), "", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = call(withKey(writeKey.Key), http.MethodPost, "/api/v1/blog-post", blogBody, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "revoked keys stop working")
+
+	var keys []models.APIKey
+	call(asAdmin, http.MethodGet, "/api/v1/api-keys", "", &keys)
+	require.Len(t, keys, 2)
+	assert.NotNil(t, keys[0].LastUsedAt)
+	assert.NotNil(t, keys[0].RevokedAt)
+}
+
+func TestBodyLimit(t *testing.T) {
+	app := setup(db.NewRepo(), m.JWTConfig{}, m.DefaultPolicy, 64)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+
+	resp, err := http.Post("http://"+ln.Addr().String()+"/api/v1/users", "application/json",
+		strings.NewReader(`{"username":"alice","password":"`+strings.Repeat("p", 100)+`"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	var problem models.Problem
+	json.NewDecoder(resp.Body).Decode(&problem)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, problem.Status)
+}
+
+func TestCORSExposesHeaders(t *testing.T) {
+	app := setup(db.NewRepo(), m.JWTConfig{}, m.DefaultPolicy, api.DefaultBodyLimit)
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/blog-posts", nil)
+	req.Header.Set("Origin", "https://blog.example.com")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, "*", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "ETag,Link,Location", resp.Header.Get("Access-Control-Expose-Headers"),
+		"cross-origin clients read pagination links and ETags")
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HeaderAPIKey carries the API key of a machine client
+const HeaderAPIKey = "X-API-Key"
+
+// scopeRoles is the role each API key scope acts with. A write key may
+// edit any blog, since a key has no blogs of its own.
+var scopeRoles = map[models.APIKeyScope]models.Role{
+	models.ScopeRead:  models.RoleReader,
+	models.ScopeWrite: models.RoleEditor,
+	models.ScopeAdmin: models.RoleAdmin,
+}
+
+// APIKeyAuth authenticates requests carrying an X-API-Key header against
+// keys. The key's name, prefixed with "apikey:", becomes the request's
+// actor, and its scope decides its role. Requests without the header pass
+// through; unknown, expired or revoked keys, and keys sent along with
+// other credentials, are rejected with 401.
+func APIKeyAuth(keys db.APIKeyStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		secret := c.Get(HeaderAPIKey)
+		if secret == "" {
+			return c.Next()
+		}
+		if _, ok := PrincipalFrom(c); ok {
+			return &db.UnauthorizedError{Reason: "send either an API key or Authorization credentials, not both"}
+		}
+		key, err := keys.AuthenticateAPIKey(c.UserContext(), secret)
+		if err != nil {
+			return err
+		}
+		setPrincipal(c, models.Principal{Name: "apikey:" + key.Name, Role: scopeRoles[key.Scope], Method: PrincipalAPIKey})
+		return c.Next()
+	}
+}
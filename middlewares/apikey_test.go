@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"blog_post/api"
+	"blog_post/db"
+	"blog_post/models"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	ctx := context.Background()
+	store := db.NewRepo()
+	_, err := store.CreateUser(ctx, models.RegisterRequest{Username: "alice", Password: "correct horse"})
+	require.NoError(t, err)
+	newKey := func(scope models.APIKeyScope) string {
+		key, err := store.CreateAPIKey(ctx, models.APIKeyRequest{Name: "ci-" + string(scope), Scope: scope})
+		require.NoError(t, err)
+		return key.Key
+	}
+	readKey, writeKey, adminKey := newKey(models.ScopeRead), newKey(models.ScopeWrite), newKey(models.ScopeAdmin)
+	revoked, err := store.CreateAPIKey(ctx, models.APIKeyRequest{Name: "old", Scope: models.ScopeWrite})
+	require.NoError(t, err)
+	_, err = store.RevokeAPIKey(ctx, revoked.ID)
+	require.NoError(t, err)
+
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Get("/", BasicAuth(store), APIKeyAuth(store), func(c *fiber.Ctx) error {
+		principal, _ := PrincipalFrom(c)
+		return c.SendString(db.ActorFrom(c.UserContext()) + " " + string(principal.Role) + " " + principal.Method)
+	})
+
+	tests := []struct {
+		description   string
+		key           string
+		authorization string
+		expectedCode  int
+		expectedBody  string
+	}{
+		{"anonymous", "", "", http.StatusOK, "  "},
+		{"read key", readKey, "", http.StatusOK, "apikey:ci-read reader api_key"},
+		{"write key", writeKey, "", http.StatusOK, "apikey:ci-write editor api_key"},
+		{"admin key", adminKey, "", http.StatusOK, "apikey:ci-admin admin api_key"},
+		{"unknown key", "bpk_nope", "", http.StatusUnauthorized, ""},
+		{"revoked key", revoked.Key, "", http.StatusUnauthorized, ""},
+		{"key and credentials", writeKey, "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:correct horse")), http.StatusUnauthorized, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.key != "" {
+				req.Header.Set(HeaderAPIKey, test.key)
+			}
+			if test.authorization != "" {
+				req.Header.Set(fiber.HeaderAuthorization, test.authorization)
+			}
+			resp, err := app.Test(req, -1)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			if test.expectedCode == http.StatusOK {
+				body, _ := io.ReadAll(resp.Body)
+				assert.Equal(t, test.expectedBody, string(body))
+			}
+		})
+	}
+}
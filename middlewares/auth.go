@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LocalPrincipal is the fiber.Ctx locals key under which the auth
+// middlewares store the models.Principal of an authenticated request
+const LocalPrincipal = "principal"
+
+// Values of models.Principal.Method
+const (
+	PrincipalBasic  = "basic"
+	PrincipalJWT    = "jwt"
+	PrincipalAPIKey = "api_key"
+)
+
+// PrincipalFrom returns the principal an auth middleware stored in c; ok is
+// false for anonymous requests
+func PrincipalFrom(c *fiber.Ctx) (principal models.Principal, ok bool) {
+	principal, ok = c.Locals(LocalPrincipal).(models.Principal)
+	return principal, ok
+}
+
+// setPrincipal marks a request as made by principal: handlers find it in
+// the locals, and the store records it as the actor of revisions and, for
+// users, as the author of created blogs
+func setPrincipal(c *fiber.Ctx, principal models.Principal) {
+	c.Locals(LocalPrincipal, principal)
+	ctx := db.WithActor(c.UserContext(), principal.Name)
+	if principal.UserID != 0 {
+		ctx = db.WithAuthor(ctx, principal.UserID)
+	}
+	c.SetUserContext(ctx)
+}
+
+// BasicAuth authenticates requests carrying HTTP Basic credentials against
+// users. The user becomes the request's actor and the author of the blogs
+// it creates. Requests without Basic credentials pass through anonymously;
+// wrong credentials are rejected with 401.
+func BasicAuth(users db.UserStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scheme, credentials, found := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+		if !found || !strings.EqualFold(scheme, "Basic") {
+			return c.Next()
+		}
+		username, password, ok := parseBasic(credentials)
+		if !ok {
+			return fiber.NewError(http.StatusUnauthorized, "malformed Basic credentials")
+		}
+		user, err := users.Authenticate(c.UserContext(), username, password)
+		if err != nil {
+			return err
+		}
+		setPrincipal(c, models.Principal{Name: user.Username, UserID: user.ID, Role: user.Role, Method: PrincipalBasic})
+		return c.Next()
+	}
+}
+
+// parseBasic decodes the base64 "username:password" of Basic credentials
+func parseBasic(credentials string) (username, password string, ok bool) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(credentials))
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"blog_post/api"
+	"blog_post/db"
+	"blog_post/models"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasicAuth(t *testing.T) {
+	users := db.NewRepo()
+	alice, err := users.CreateUser(context.Background(), models.RegisterRequest{Username: "alice", Password: "correct horse"})
+	require.NoError(t, err)
+
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Get("/", BasicAuth(users), func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		author, ok := db.AuthorFrom(ctx)
+		return c.SendString(fmt.Sprintf("%s %d %t", db.ActorFrom(ctx), author, ok))
+	})
+	basic := func(credentials string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+
+	tests := []struct {
+		description   string
+		authorization string
+		expectedCode  int
+		expectedBody  string
+	}{
+		{"anonymous", "", http.StatusOK, " 0 false"},
+		{"other scheme", "Bearer token", http.StatusOK, " 0 false"},
+		{"valid credentials", basic("Alice:correct horse"), http.StatusOK, fmt.Sprintf("alice %d true", alice.ID)},
+		{"wrong password", basic("alice:wrong horse"), http.StatusUnauthorized, ""},
+		{"unknown user", basic("nobody:correct horse"), http.StatusUnauthorized, ""},
+		{"malformed", "Basic not-base64!", http.StatusUnauthorized, ""},
+		{"missing colon", basic("alice"), http.StatusUnauthorized, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.authorization != "" {
+				req.Header.Set(fiber.HeaderAuthorization, test.authorization)
+			}
+			resp, err := app.Test(req, -1)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			if test.expectedCode == http.StatusOK {
+				body, _ := io.ReadAll(resp.Body)
+				assert.Equal(t, test.expectedBody, string(body))
+			} else {
+				assert.Equal(t, api.AuthChallenge, resp.Header.Get(fiber.HeaderWWWAuthenticate))
+			}
+		})
+	}
+}
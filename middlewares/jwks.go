@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// KeySet is a JSON Web Key Set (RFC 7517) the JWT middleware verifies
+// signatures with. Keys are looked up by their "kid"; an unknown kid makes
+// the set reload its source, at most once per MinRefresh, so that keys
+// rotated in by the issuer are picked up without a restart.
+type KeySet struct {
+	// MinRefresh rate-limits reloads triggered by unknown key IDs
+	MinRefresh time.Duration
+
+	fetch   func(ctx context.Context) ([]byte, error)
+	mu      sync.RWMutex
+	keys    map[string]jsonWebKey
+	fetched time.Time
+}
+
+// jsonWebKey is a parsed verification key
+type jsonWebKey struct {
+	alg string // algorithm the key is restricted to; "" allows any that fits
+	key any    // []byte, *rsa.PublicKey or ed25519.PublicKey
+}
+
+// DefaultMinRefresh is how often a KeySet may reload for unknown key IDs
+const DefaultMinRefresh = time.Minute
+
+// NewFileKeySet loads a key set from a JWKS file, re-reading it when a token
+// names a key the file did not have
+func NewFileKeySet(ctx context.Context, path string) (*KeySet, error) {
+	return newKeySet(ctx, func(context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	})
+}
+
+// NewURLKeySet loads a key set from a JWKS URL, typically an identity
+// provider's jwks_uri. A nil client means http.DefaultClient.
+func NewURLKeySet(ctx context.Context, url string, client *http.Client) (*KeySet, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return newKeySet(ctx, func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	})
+}
+
+func newKeySet(ctx context.Context, fetch func(ctx context.Context) ([]byte, error)) (*KeySet, error) {
+	s := &KeySet{MinRefresh: DefaultMinRefresh, fetch: fetch}
+	if err := s.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Refresh reloads the key set from its source. On failure the keys loaded
+// before are kept.
+func (s *KeySet) Refresh(ctx context.Context) error {
+	data, err := s.fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("loading JWKS: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("loading JWKS: %w", err)
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.fetched = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// Key returns the key with the given ID, reloading the set first if it is
+// unknown and no reload was attempted within MinRefresh
+func (s *KeySet) Key(ctx context.Context, kid string) (key any, alg string, err error) {
+	jwk, found := s.lookup(kid)
+	if !found && s.claimRefresh() {
+		if err := s.Refresh(ctx); err != nil {
+			return nil, "", err
+		}
+		jwk, found = s.lookup(kid)
+	}
+	if !found {
+		return nil, "", fmt.Errorf("unknown key %q", kid)
+	}
+	return jwk.key, jwk.alg, nil
+}
+
+func (s *KeySet) lookup(kid string) (jsonWebKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	jwk, found := s.keys[kid]
+	return jwk, found
+}
+
+// claimRefresh reports whether the caller may reload the set now, and if so
+// holds other callers off for MinRefresh, even if the reload fails
+func (s *KeySet) claimRefresh() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.fetched) < s.MinRefresh {
+		return false
+	}
+	s.fetched = time.Now()
+	return true
+}
+
+// parseJWKS decodes the verification keys of a JWKS document. Keys of
+// unsupported types or meant for encryption are skipped.
+func parseJWKS(data []byte) (map[string]jsonWebKey, error) {
+	var doc struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]jsonWebKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key any
+		var err error
+		switch {
+		case k.Kty == "RSA":
+			key, err = rsaKey(k.N, k.E)
+		case k.Kty == "OKP" && k.Crv == "Ed25519":
+			key, err = ed25519Key(k.X)
+		case k.Kty == "oct":
+			key, err = base64.RawURLEncoding.DecodeString(k.K)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = jsonWebKey{alg: k.Alg, key: key}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no usable signing keys")
+	}
+	return keys, nil
+}
+
+func rsaKey(n, e string) (*rsa.PublicKey, error) {
+	modulus, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	exponent, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(new(big.Int).SetBytes(exponent).Int64())}
+	if key.N.BitLen() < 2048 || key.E < 3 {
+		return nil, errors.New("RSA key is under 2048 bits or has a bad exponent")
+	}
+	return key, nil
+}
+
+func ed25519Key(x string) (ed25519.PublicKey, error) {
+	key, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("bad Ed25519 key size")
+	}
+	return ed25519.PublicKey(key), nil
+}
@@ -0,0 +1,219 @@
+package middleware
+
+import (
+	"blog_post/db"
+	"blog_post/models"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultJWTAlgorithms are the signing algorithms accepted unless
+// JWTConfig.Algorithms says otherwise
+var DefaultJWTAlgorithms = []string{"HS256", "RS256", "EdDSA"}
+
+// JWTConfig configures how the JWT middleware verifies bearer tokens
+type JWTConfig struct {
+	// Secret verifies HS256 tokens that carry no "kid" header
+	Secret []byte
+	// Keys verifies tokens by their "kid" header
+	Keys *KeySet
+	// Issuer, when set, must equal the "iss" claim
+	Issuer string
+	// Audience, when set, must be one of the "aud" claims
+	Audience string
+	// Leeway tolerated on "exp", "nbf" and "iat" for clock skew
+	Leeway time.Duration
+	// Algorithms accepted; empty means DefaultJWTAlgorithms
+	Algorithms []string
+	// TokenTTL is how long tokens from IssueToken last; zero means
+	// DefaultTokenTTL
+	TokenTTL time.Duration
+}
+
+// DefaultTokenTTL is how long login tokens last unless JWTConfig.TokenTTL
+// says otherwise
+const DefaultTokenTTL = time.Hour
+
+// tokenClaims are the claims the JWT middleware reads
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	// Role of a subject that is not a user; users act with their own role
+	Role models.Role `json:"role,omitempty"`
+}
+
+// JWT authenticates requests carrying an "Authorization: Bearer" token.
+// Tokens must be signed with one of the accepted algorithms by Secret or a
+// key of Keys, must not be expired, and must name a subject. A subject that
+// is the ID of one of users makes that user, with its stored role, the
+// principal; any other subject is a principal of its own, named "jwt:"
+// followed by the subject so that it cannot pass for a user, that authors
+// nothing and acts with the token's "role" claim. Requests without a bearer
+// token pass through anonymously; bad tokens, including ones claiming a
+// role that does not exist, are rejected with 401.
+func JWT(cfg JWTConfig, users db.UserStore) fiber.Handler {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(cfg.algorithms()),
+		jwt.WithLeeway(cfg.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+	parser := jwt.NewParser(options...)
+
+	return func(c *fiber.Ctx) error {
+		scheme, token, found := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			return c.Next()
+		}
+		var claims tokenClaims
+		if _, err := parser.ParseWithClaims(strings.TrimSpace(token), &claims, cfg.keyfunc(c.UserContext())); err != nil {
+			return fiber.NewError(http.StatusUnauthorized, "invalid token: "+tokenError(err))
+		}
+		if claims.Subject == "" {
+			return fiber.NewError(http.StatusUnauthorized, "invalid token: no subject")
+		}
+		if claims.Role != "" && !db.ValidRole(claims.Role) {
+			return fiber.NewError(http.StatusUnauthorized, "invalid token: unknown role")
+		}
+		principal, err := subjectPrincipal(c.UserContext(), users, claims)
+		if err != nil {
+			return err
+		}
+		setPrincipal(c, principal)
+		return c.Next()
+	}
+}
+
+// IssueToken signs an HS256 token with Secret whose subject is user, which
+// JWT then accepts as that user until TokenTTL has passed. It fails with 501
+// when there is no Secret or HS256 tokens are not accepted.
+func (cfg JWTConfig) IssueToken(user models.User) (models.LoginResponse, error) {
+	if cfg.Secret == nil || !slices.Contains(cfg.algorithms(), jwt.SigningMethodHS256.Alg()) {
+		return models.LoginResponse{}, fiber.NewError(http.StatusNotImplemented, "no secret to sign login tokens with")
+	}
+	ttl := cfg.TokenTTL
+	if ttl <= 0 {
+		ttl = DefaultTokenTTL
+	}
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Subject:   strconv.FormatInt(user.ID, 10),
+		Issuer:    cfg.Issuer,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+	if cfg.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{cfg.Audience}
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(cfg.Secret)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+	return models.LoginResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresAt:   claims.ExpiresAt.Time,
+		User:        user,
+	}, nil
+}
+
+// algorithms returns the accepted signing algorithms
+func (cfg JWTConfig) algorithms() []string {
+	if len(cfg.Algorithms) == 0 {
+		return DefaultJWTAlgorithms
+	}
+	return cfg.Algorithms
+}
+
+// keyfunc picks the key a token claims to be signed with. The key's type
+// has to fit the token's algorithm, so a public key can never be abused as
+// an HMAC secret.
+func (cfg JWTConfig) keyfunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (any, error) {
+		alg := token.Method.Alg()
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			if alg == jwt.SigningMethodHS256.Alg() && cfg.Secret != nil {
+				return cfg.Secret, nil
+			}
+			return nil, keyError{errors.New("no key ID")}
+		}
+		if cfg.Keys == nil {
+			return nil, keyError{fmt.Errorf("unknown key %q", kid)}
+		}
+		key, keyAlg, err := cfg.Keys.Key(ctx, kid)
+		if err != nil {
+			return nil, keyError{err}
+		}
+		if keyAlg != "" && keyAlg != alg {
+			return nil, keyError{fmt.Errorf("key %q is not for %s", kid, alg)}
+		}
+		return key, nil
+	}
+}
+
+// keyError is why no key could be found for a token
+type keyError struct {
+	err error
+}
+
+func (e keyError) Error() string {
+	return e.err.Error()
+}
+
+// tokenError describes why a token was rejected without echoing it
+func tokenError(err error) string {
+	var keyErr keyError
+	if errors.As(err, &keyErr) {
+		return keyErr.Error()
+	}
+	for _, known := range []error{
+		jwt.ErrTokenMalformed,
+		jwt.ErrTokenSignatureInvalid,
+		jwt.ErrTokenExpired,
+		jwt.ErrTokenNotValidYet,
+		jwt.ErrTokenUsedBeforeIssued,
+		jwt.ErrTokenInvalidIssuer,
+		jwt.ErrTokenInvalidAudience,
+		jwt.ErrTokenRequiredClaimMissing,
+	} {
+		if errors.Is(err, known) {
+			return known.Error()
+		}
+	}
+	return err.Error()
+}
+
+// subjectPrincipal resolves the subject of a verified token
+func subjectPrincipal(ctx context.Context, users db.UserStore, claims tokenClaims) (models.Principal, error) {
+	principal := models.Principal{Name: "jwt:" + claims.Subject, Role: claims.Role, Method: PrincipalJWT}
+	id, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return principal, nil
+	}
+	user, err := users.GetUser(ctx, id)
+	if errors.Is(err, db.ErrNotFound) {
+		return principal, nil
+	}
+	if err != nil {
+		return models.Principal{}, err
+	}
+	principal.Name = user.Username
+	principal.UserID = user.ID
+	principal.Role = user.Role
+	return principal, nil
+}
@@ -0,0 +1,255 @@
+package middleware
+
+import (
+	"blog_post/api"
+	"blog_post/db"
+	"blog_post/middlewares/jwttest"
+	"blog_post/models"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWT(t *testing.T) {
+	ctx := context.Background()
+	users := db.NewRepo()
+	alice, err := users.CreateUser(ctx, models.RegisterRequest{Username: "alice", Password: "correct horse"})
+	require.NoError(t, err)
+
+	issuer := jwttest.NewIssuer(t)
+	keys, err := NewURLKeySet(ctx, issuer.JWKSURL(), nil)
+	require.NoError(t, err)
+	keys.MinRefresh = 0
+	secret := []byte("shared secret for HS256 tokens")
+	cfg := JWTConfig{
+		Secret:   secret,
+		Keys:     keys,
+		Issuer:   issuer.URL,
+		Audience: jwttest.Audience,
+		Leeway:   30 * time.Second,
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Get("/", JWT(cfg, users), func(c *fiber.Ctx) error {
+		principal, ok := PrincipalFrom(c)
+		if !ok {
+			return c.SendStatus(http.StatusNoContent)
+		}
+		author, _ := db.AuthorFrom(c.UserContext())
+		return c.JSON(fiber.Map{"principal": principal, "actor": db.ActorFrom(c.UserContext()), "author": author})
+	})
+	call := func(authorization string) (*http.Response, models.Principal) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if authorization != "" {
+			req.Header.Set(fiber.HeaderAuthorization, authorization)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var body struct {
+			Principal models.Principal `json:"principal"`
+			Actor     string           `json:"actor"`
+			Author    int64            `json:"author"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		if resp.StatusCode == http.StatusOK {
+			assert.Equal(t, body.Principal.Name, body.Actor)
+			assert.Equal(t, body.Principal.UserID, body.Author)
+		}
+		return resp, body.Principal
+	}
+	bearer := func(token string) string { return "Bearer " + token }
+	hs256 := func(claims jwt.Claims) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+		require.NoError(t, err)
+		return signed
+	}
+	userSubject := strconv.FormatInt(alice.ID, 10)
+
+	t.Run("Anonymous", func(t *testing.T) {
+		resp, _ := call("")
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+	t.Run("Algorithms", func(t *testing.T) {
+		for alg, token := range map[string]string{
+			"RS256": issuer.Sign(t, "RS256", issuer.Claims(userSubject)),
+			"EdDSA": issuer.Sign(t, "EdDSA", issuer.Claims(userSubject)),
+			"HS256": hs256(issuer.Claims(userSubject)),
+		} {
+			resp, principal := call(bearer(token))
+			require.Equal(t, http.StatusOK, resp.StatusCode, alg)
+			assert.Equal(t, models.Principal{Name: "alice", UserID: alice.ID, Role: models.RoleReader, Method: PrincipalJWT}, principal, alg)
+		}
+	})
+	t.Run("Issued tokens", func(t *testing.T) {
+		login, err := cfg.IssueToken(alice)
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer", login.TokenType)
+		assert.Equal(t, alice, login.User)
+		assert.WithinDuration(t, time.Now().Add(DefaultTokenTTL), login.ExpiresAt, 2*time.Second)
+		resp, principal := call(bearer(login.AccessToken))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, alice.ID, principal.UserID)
+
+		for description, cfg := range map[string]JWTConfig{
+			"no secret":    {Keys: keys},
+			"HS256 barred": {Secret: secret, Algorithms: []string{"RS256"}},
+		} {
+			_, err := cfg.IssueToken(alice)
+			var fiberErr *fiber.Error
+			require.ErrorAs(t, err, &fiberErr, description)
+			assert.Equal(t, http.StatusNotImplemented, fiberErr.Code, description)
+		}
+	})
+	t.Run("Subject that is not a user", func(t *testing.T) {
+		resp, principal := call(bearer(issuer.Token(t, "ci-importer")))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, models.Principal{Name: "jwt:ci-importer", Method: PrincipalJWT}, principal)
+
+		resp, principal = call(bearer(issuer.Token(t, "alice")))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "jwt:alice", principal.Name, "subjects cannot pass for usernames")
+		assert.Zero(t, principal.UserID)
+	})
+	t.Run("Role claim", func(t *testing.T) {
+		withRole := func(subject string, role models.Role) jwt.Claims {
+			return struct {
+				jwt.RegisteredClaims
+				Role models.Role `json:"role"`
+			}{issuer.Claims(subject), role}
+		}
+		resp, principal := call(bearer(issuer.Sign(t, "RS256", withRole("ci-importer", models.RoleAdmin))))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, models.RoleAdmin, principal.Role)
+		resp, principal = call(bearer(issuer.Sign(t, "RS256", withRole(userSubject, models.RoleAdmin))))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, models.RoleReader, principal.Role, "users cannot claim another role")
+		resp, _ = call(bearer(issuer.Sign(t, "RS256", withRole("ci-importer", "superuser"))))
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "roles the policy does not know are rejected")
+	})
+	t.Run("Clock skew", func(t *testing.T) {
+		claims := issuer.Claims(userSubject)
+		claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-10 * time.Second))
+		resp, _ := call(bearer(issuer.Token(t, userSubject)))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		resp, _ = call(bearer(issuer.Sign(t, "RS256", claims)))
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "expiry within the leeway is tolerated")
+	})
+	t.Run("Key rotation", func(t *testing.T) {
+		old := issuer.Token(t, userSubject)
+		fetches := issuer.Fetches()
+		issuer.Rotate(t, "RS256")
+		resp, _ := call(bearer(issuer.Token(t, userSubject)))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, fetches+1, issuer.Fetches(), "an unknown kid reloads the key set")
+		resp, _ = call(bearer(old))
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "keys rotated out of signing stay valid")
+	})
+
+	rejected := []struct {
+		description string
+		token       func() string
+		detail      string
+	}{
+		{"malformed", func() string { return "not.a.token" }, "invalid token: token is malformed"},
+		{"expired", func() string {
+			claims := issuer.Claims(userSubject)
+			claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+			return issuer.Sign(t, "RS256", claims)
+		}, "invalid token: token is expired"},
+		{"not yet valid", func() string {
+			claims := issuer.Claims(userSubject)
+			claims.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Minute))
+			return issuer.Sign(t, "RS256", claims)
+		}, "invalid token: token is not valid yet"},
+		{"no expiry", func() string {
+			claims := issuer.Claims(userSubject)
+			claims.ExpiresAt = nil
+			return issuer.Sign(t, "RS256", claims)
+		}, "invalid token: token is missing required claim"},
+		{"wrong issuer", func() string {
+			claims := issuer.Claims(userSubject)
+			claims.Issuer = "https://evil.example"
+			return issuer.Sign(t, "RS256", claims)
+		}, "invalid token: token has invalid issuer"},
+		{"wrong audience", func() string {
+			claims := issuer.Claims(userSubject)
+			claims.Audience = jwt.ClaimStrings{"another-api"}
+			return issuer.Sign(t, "RS256", claims)
+		}, "invalid token: token has invalid audience"},
+		{"no subject", func() string {
+			return issuer.Sign(t, "RS256", issuer.Claims(""))
+		}, "invalid token: no subject"},
+		{"wrong secret", func() string {
+			signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, issuer.Claims(userSubject)).SignedString([]byte("guessed"))
+			return signed
+		}, "invalid token: token signature is invalid"},
+		{"unknown key", func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, issuer.Claims(userSubject))
+			token.Header["kid"] = "nope"
+			signed, _ := token.SignedString(secret)
+			return signed
+		}, `invalid token: unknown key "nope"`},
+		{"unsigned", func() string {
+			signed, _ := jwt.NewWithClaims(jwt.SigningMethodNone, issuer.Claims(userSubject)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			return signed
+		}, "invalid token: token signature is invalid"},
+	}
+	for _, test := range rejected {
+		t.Run("Rejects "+test.description, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(fiber.HeaderAuthorization, bearer(test.token()))
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+			var problem models.Problem
+			json.NewDecoder(resp.Body).Decode(&problem)
+			assert.Equal(t, test.detail, problem.Detail)
+		})
+	}
+}
+
+func TestFileKeySet(t *testing.T) {
+	ctx := context.Background()
+	issuer := jwttest.NewIssuer(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS := func() {
+		resp, err := http.Get(issuer.JWKSURL())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var jwks json.RawMessage
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&jwks))
+		require.NoError(t, os.WriteFile(path, jwks, 0o600))
+	}
+	writeJWKS()
+	keys, err := NewFileKeySet(ctx, path)
+	require.NoError(t, err)
+
+	kid := issuer.Rotate(t, "EdDSA")
+	_, _, err = keys.Key(ctx, kid)
+	assert.Error(t, err, "the file does not have the new key yet")
+
+	writeJWKS()
+	_, _, err = keys.Key(ctx, kid)
+	assert.Error(t, err, "reloads are rate-limited")
+	keys.MinRefresh = 0
+	key, alg, err := keys.Key(ctx, kid)
+	require.NoError(t, err)
+	assert.Equal(t, "EdDSA", alg)
+	assert.NotNil(t, key)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"keys":[]}`), 0o600))
+	assert.Error(t, keys.Refresh(ctx))
+	_, _, err = keys.Key(ctx, kid)
+	assert.NoError(t, err, "a failed refresh keeps the previous keys")
+}
@@ -0,0 +1,174 @@
+// Package jwttest is a local stand-in for a JWT identity provider: it mints
+// signed tokens and serves its public keys as a JWKS over HTTP, so that the
+// JWT middleware can be tested without a real issuer:
+//
+//	issuer := jwttest.NewIssuer(t)
+//	keys, _ := middleware.NewURLKeySet(ctx, issuer.JWKSURL(), nil)
+//	app.Use(middleware.JWT(middleware.JWTConfig{Keys: keys, Issuer: issuer.URL, Audience: jwttest.Audience}, users))
+//	req.Header.Set("Authorization", "Bearer "+issuer.Token(t, "42"))
+package jwttest
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Audience is the "aud" claim of the tokens minted by Token
+const Audience = "blog-api"
+
+// Issuer signs tokens with RS256 and EdDSA keys and publishes them at
+// JWKSURL. Rotate replaces the signing key of an algorithm while keeping the
+// old one published, as identity providers do.
+type Issuer struct {
+	// URL is the issuer's base URL, used as the "iss" claim
+	URL string
+
+	server  *httptest.Server
+	mu      sync.Mutex
+	keys    []signingKey // every published key, oldest first
+	current map[string]signingKey
+	fetches int
+}
+
+type signingKey struct {
+	kid    string
+	method jwt.SigningMethod
+	key    crypto.Signer
+}
+
+// NewIssuer starts an issuer with one key per algorithm; it is shut down
+// when the test ends
+func NewIssuer(t testing.TB) *Issuer {
+	t.Helper()
+	issuer := &Issuer{current: make(map[string]signingKey)}
+	issuer.server = httptest.NewServer(http.HandlerFunc(issuer.serveJWKS))
+	t.Cleanup(issuer.server.Close)
+	issuer.URL = issuer.server.URL
+	issuer.Rotate(t, jwt.SigningMethodRS256.Alg())
+	issuer.Rotate(t, jwt.SigningMethodEdDSA.Alg())
+	return issuer
+}
+
+// JWKSURL is where the issuer serves its public keys
+func (i *Issuer) JWKSURL() string {
+	return i.URL + "/.well-known/jwks.json"
+}
+
+// Fetches counts the requests made for the JWKS so far
+func (i *Issuer) Fetches() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.fetches
+}
+
+// Rotate generates a new signing key for alg ("RS256" or "EdDSA") and
+// returns its key ID
+func (i *Issuer) Rotate(t testing.TB, alg string) string {
+	t.Helper()
+	var key crypto.Signer
+	var err error
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwt.SigningMethodEdDSA.Alg():
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		t.Fatalf("jwttest: unsupported algorithm %q", alg)
+	}
+	if err != nil {
+		t.Fatalf("jwttest: generating %s key: %v", alg, err)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	signer := signingKey{
+		kid:    fmt.Sprintf("%s-%d", alg, len(i.keys)+1),
+		method: jwt.GetSigningMethod(alg),
+		key:    key,
+	}
+	i.keys = append(i.keys, signer)
+	i.current[alg] = signer
+	return signer.kid
+}
+
+// Claims returns valid claims for subject: issued by i for Audience, now,
+// and expiring in an hour
+func (i *Issuer) Claims(subject string) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		Issuer:    i.URL,
+		Subject:   subject,
+		Audience:  jwt.ClaimStrings{Audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+}
+
+// Token returns an RS256 token with the default Claims for subject
+func (i *Issuer) Token(t testing.TB, subject string) string {
+	t.Helper()
+	return i.Sign(t, jwt.SigningMethodRS256.Alg(), i.Claims(subject))
+}
+
+// Sign returns a token with claims, signed with the current key for alg
+func (i *Issuer) Sign(t testing.TB, alg string, claims jwt.Claims) string {
+	t.Helper()
+	i.mu.Lock()
+	signer, ok := i.current[alg]
+	i.mu.Unlock()
+	if !ok {
+		t.Fatalf("jwttest: no key for %q", alg)
+	}
+	token := jwt.NewWithClaims(signer.method, claims)
+	token.Header["kid"] = signer.kid
+	signed, err := token.SignedString(signer.key)
+	if err != nil {
+		t.Fatalf("jwttest: signing: %v", err)
+	}
+	return signed
+}
+
+func (i *Issuer) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/.well-known/jwks.json" {
+		http.NotFound(w, r)
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.fetches++
+	keys := make([]map[string]string, len(i.keys))
+	for n, k := range i.keys {
+		keys[n] = publicJWK(k)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+}
+
+func publicJWK(k signingKey) map[string]string {
+	encode := base64.RawURLEncoding.EncodeToString
+	jwk := map[string]string{"kid": k.kid, "alg": k.method.Alg(), "use": "sig"}
+	switch public := k.key.Public().(type) {
+	case *rsa.PublicKey:
+		jwk["kty"] = "RSA"
+		jwk["n"] = encode(public.N.Bytes())
+		jwk["e"] = encode(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk["kty"] = "OKP"
+		jwk["crv"] = "Ed25519"
+		jwk["x"] = encode(public)
+	}
+	return jwk
+}
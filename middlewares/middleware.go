@@ -1,27 +1,25 @@
 package middleware
 
 import (
+	"blog_post/api"
+	"blog_post/db"
 	"blog_post/models"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/log"
 )
 
-// VerifyBlogFields checks if required fields are present in the request body
+// VerifyBlogFields decodes a blog request body with api.JSONBody and
+// validates it against the rules tagged on models.BlogRequestBody before the
+// handler runs, so that every field error is reported at once. The handler
+// gets the decoded, normalized body from the same locals. Failures are
+// returned as errors for the app's ErrorHandler to render.
 func VerifyBlogFields(c *fiber.Ctx) error {
-	var reqBody models.BlogRequestBody
-	if err := c.BodyParser(&reqBody); err != nil {
-		log.Errorf("VerifyBlogFields failed: %v", err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid JSON format",
-		})
+	reqBody, err := api.JSONBody[models.BlogRequestBody](c)
+	if err != nil {
+		return err
 	}
-	if reqBody.Body == "" || reqBody.Title == "" || reqBody.Description == "" {
-		log.Error("Missing field")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Missing required field",
-		})
+	if err := db.ValidateBlog(reqBody); err != nil {
+		return err
 	}
-
 	return c.Next()
 }
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"blog_post/api"
 	"blog_post/models"
 	"bytes"
 	"encoding/json"
@@ -13,7 +14,7 @@ import (
 )
 
 func TestVerifyBlogFields(t *testing.T) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	t.Run("Valid request body", func(t *testing.T) {
 		reqBody := models.BlogRequestBody{
 			Title:       "Test Title",
@@ -42,9 +43,9 @@ func TestVerifyBlogFields(t *testing.T) {
 		resp, _ := app.Test(req)
 		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
 		respBody, _ := io.ReadAll(resp.Body)
-		var res map[string]any
+		var res models.Problem
 		json.Unmarshal(respBody, &res)
-		assert.Equal(t, "Invalid JSON format", res["error"])
+		assert.Equal(t, "Invalid JSON format", res.Detail)
 	})
 	t.Run("Missing title", func(t *testing.T) {
 		reqBody := models.BlogRequestBody{
@@ -62,9 +63,46 @@ func TestVerifyBlogFields(t *testing.T) {
 		resp, _ := app.Test(req)
 		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
 		respBody, _ := io.ReadAll(resp.Body)
-		var res map[string]any
+		var res models.Problem
 		json.Unmarshal(respBody, &res)
-		assert.Equal(t, "Missing required field", res["error"])
+		assert.Equal(t, api.ProblemTypeValidation, res.Type)
+		assert.Equal(t, []models.FieldError{{Field: "title", Message: "is required"}}, res.Errors)
+	})
+	t.Run("Every bad field at once", func(t *testing.T) {
+		body := []byte(`{"title":"  a ","description":"two\nlines","body":" ","slug":"Bad Slug"}`)
+		req := httptest.NewRequest("PUT", "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		app.Put("/", VerifyBlogFields, func(c *fiber.Ctx) error {
+			return c.SendString("OK")
+		})
+
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+		var res models.Problem
+		json.NewDecoder(resp.Body).Decode(&res)
+		fields := make([]string, len(res.Errors))
+		for i, f := range res.Errors {
+			fields[i] = f.Field
+		}
+		assert.Equal(t, []string{"title", "description", "body", "slug"}, fields)
+		assert.Equal(t, "must be at least 3 characters", res.Errors[0].Message, "checked after trimming")
+	})
+	t.Run("Handler reuses the validated body", func(t *testing.T) {
+		req := httptest.NewRequest("PATCH", "/", bytes.NewReader([]byte(`{"title":"  Padded  ","description":"D","body":"B"}`)))
+		req.Header.Set("Content-Type", "application/json")
+
+		app.Patch("/", VerifyBlogFields, func(c *fiber.Ctx) error {
+			reqBody, err := api.JSONBody[models.BlogRequestBody](c)
+			if err != nil {
+				return err
+			}
+			return c.SendString(reqBody.Title)
+		})
 
+		resp, _ := app.Test(req)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		respBody, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, "Padded", string(respBody))
 	})
 }
@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"blog_post/api"
+	"blog_post/db"
+	"blog_post/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Actions an Authorizer guards
+const (
+	ActionCreateBlog       = "blog:create"
+	ActionUpdateBlog       = "blog:update"  // PUT, PATCH and restoring a revision
+	ActionDeleteBlog       = "blog:delete"  // moving a blog to the trash
+	ActionPublishBlog      = "blog:publish" // every lifecycle transition
+	ActionRestoreBlog      = "blog:restore" // taking a blog out of the trash
+	ActionPurgeBlog        = "blog:purge"
+	ActionManageUsers      = "user:manage"
+	ActionManageKeys       = "apikey:manage"
+	ActionManageCategories = "category:manage" // creating, updating and deleting categories
+	ActionCreateComment    = "comment:create"  // commenting on a blog and replying to comments
+	ActionUpdateComment    = "comment:update"
+	ActionDeleteComment    = "comment:delete"
+	ActionModerateComments = "comment:moderate" // the moderation queue and comment statuses
+)
+
+// Rule lists the roles allowed to perform an action: on any resource, or
+// only on blogs or comments they author
+type Rule struct {
+	Any []models.Role `json:"any"`
+	Own []models.Role `json:"own,omitempty"`
+}
+
+// Policy maps every action to its Rule; actions without one are denied
+type Policy map[string]Rule
+
+// DefaultPolicy lets authors write their own blogs, editors write any blog,
+// manage categories and moderate comments, and admins do everything.
+// Every user may comment and edit or delete their own comments. Reading
+// published blogs needs no role; blogs in other statuses are seen by whoever
+// may update them.
+var DefaultPolicy = Policy{
+	ActionCreateBlog:       {Any: []models.Role{models.RoleAuthor, models.RoleEditor, models.RoleAdmin}},
+	ActionUpdateBlog:       {Any: []models.Role{models.RoleEditor, models.RoleAdmin}, Own: []models.Role{models.RoleAuthor}},
+	ActionDeleteBlog:       {Any: []models.Role{models.RoleEditor, models.RoleAdmin}, Own: []models.Role{models.RoleAuthor}},
+	ActionPublishBlog:      {Any: []models.Role{models.RoleEditor, models.RoleAdmin}, Own: []models.Role{models.RoleAuthor}},
+	ActionRestoreBlog:      {Any: []models.Role{models.RoleEditor, models.RoleAdmin}, Own: []models.Role{models.RoleAuthor}},
+	ActionPurgeBlog:        {Any: []models.Role{models.RoleAdmin}},
+	ActionManageUsers:      {Any: []models.Role{models.RoleAdmin}},
+	ActionManageKeys:       {Any: []models.Role{models.RoleAdmin}},
+	ActionManageCategories: {Any: []models.Role{models.RoleEditor, models.RoleAdmin}},
+	ActionCreateComment:    {Any: []models.Role{models.RoleReader, models.RoleAuthor, models.RoleEditor, models.RoleAdmin}},
+	ActionUpdateComment:    {Own: []models.Role{models.RoleReader, models.RoleAuthor, models.RoleEditor, models.RoleAdmin}},
+	ActionDeleteComment:    {Any: []models.Role{models.RoleEditor, models.RoleAdmin}, Own: []models.Role{models.RoleReader, models.RoleAuthor}},
+	ActionModerateComments: {Any: []models.Role{models.RoleEditor, models.RoleAdmin}},
+}
+
+// ParsePolicy reads a JSON object of rules by action, such as
+//
+//	{"blog:purge": {"any": ["editor", "admin"]}}
+//
+// and returns DefaultPolicy with those rules replaced
+func ParsePolicy(data []byte) (Policy, error) {
+	var overrides Policy
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("parsing policy: %w", err)
+	}
+	policy := make(Policy, len(DefaultPolicy))
+	for action, rule := range DefaultPolicy {
+		policy[action] = rule
+	}
+	for action, rule := range overrides {
+		if _, known := DefaultPolicy[action]; !known {
+			return nil, fmt.Errorf("parsing policy: unknown action %q", action)
+		}
+		if ownedBy(action) == "" && len(rule.Own) > 0 {
+			return nil, fmt.Errorf("parsing policy: %s: own rules only apply to blog and comment actions", action)
+		}
+		for _, role := range slices.Concat(rule.Any, rule.Own) {
+			if !db.ValidRole(role) {
+				return nil, fmt.Errorf("parsing policy: %s: unknown role %q", action, role)
+			}
+		}
+		policy[action] = rule
+	}
+	return policy, nil
+}
+
+// Authorizer enforces a Policy, looking up authors in Blogs and Comments
+// for rules that only allow roles on what they wrote
+type Authorizer struct {
+	Policy   Policy
+	Blogs    db.BlogStore
+	Comments db.CommentStore
+}
+
+var _ api.Visibility = (*Authorizer)(nil)
+
+// NewAuthorizer returns an Authorizer enforcing policy on store
+func NewAuthorizer(policy Policy, store db.Store) *Authorizer {
+	return &Authorizer{Policy: policy, Blogs: store, Comments: store}
+}
+
+// Require returns a middleware that lets a request through only if its
+// principal's role may perform action; anonymous requests get 401 and
+// everyone else who may not gets 403. When the role is only allowed on
+// what it wrote, that is the blog in the route's :id parameter for blog
+// actions and the comment in its :commentId parameter for comment actions.
+func (a *Authorizer) Require(action string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		principal, ok := PrincipalFrom(c)
+		if !ok {
+			return &db.UnauthorizedError{Reason: "authentication required"}
+		}
+		rule := a.Policy[action]
+		if slices.Contains(rule.Any, principal.Role) {
+			return c.Next()
+		}
+		if principal.UserID != 0 && slices.Contains(rule.Own, principal.Role) {
+			own, err := a.owns(c, action, principal)
+			if err != nil {
+				return err
+			}
+			if own {
+				return c.Next()
+			}
+			return &db.ForbiddenError{Reason: fmt.Sprintf("%s may only %s their own %ss", roleName(principal.Role), action, ownedBy(action))}
+		}
+		return &db.ForbiddenError{Reason: fmt.Sprintf("%s may not %s", roleName(principal.Role), action)}
+	}
+}
+
+// AllowTrash lets a request list the trash if its principal's role may
+// restore or delete any blog; own rules are not enough, since the listing
+// spans every author
+func (a *Authorizer) AllowTrash(c *fiber.Ctx) error {
+	return a.allowAny(c, "list the trash", ActionRestoreBlog, ActionDeleteBlog)
+}
+
+// AllowUnpublished lets a request list blogs in other statuses than
+// published if its principal's role may update any blog
+func (a *Authorizer) AllowUnpublished(c *fiber.Ctx) error {
+	return a.allowAny(c, "list unpublished blogs", ActionUpdateBlog)
+}
+
+// CanRead reports whether the request's principal may update blog, and so
+// see it before it is published or after it is archived
+func (a *Authorizer) CanRead(c *fiber.Ctx, blog models.Blog) bool {
+	principal, ok := PrincipalFrom(c)
+	if !ok {
+		return false
+	}
+	rule := a.Policy[ActionUpdateBlog]
+	if slices.Contains(rule.Any, principal.Role) {
+		return true
+	}
+	return principal.UserID != 0 && slices.Contains(rule.Own, principal.Role) &&
+		blog.AuthorID != nil && *blog.AuthorID == principal.UserID
+}
+
+// allowAny returns nil if the request's principal may perform one of
+// actions on any resource, and an error naming what it tried otherwise
+func (a *Authorizer) allowAny(c *fiber.Ctx, what string, actions ...string) error {
+	principal, ok := PrincipalFrom(c)
+	if !ok {
+		return &db.UnauthorizedError{Reason: "authentication required"}
+	}
+	for _, action := range actions {
+		if slices.Contains(a.Policy[action].Any, principal.Role) {
+			return nil
+		}
+	}
+	return &db.ForbiddenError{Reason: fmt.Sprintf("%s may not %s", roleName(principal.Role), what)}
+}
+
+// owns reports whether principal authored what action is performed on:
+// the blog in the :id parameter or the comment in :commentId
+func (a *Authorizer) owns(c *fiber.Ctx, action string, principal models.Principal) (bool, error) {
+	lookup, param := a.Blogs.BlogAuthor, "id"
+	if ownedBy(action) == "comment" {
+		lookup, param = a.Comments.CommentAuthor, "commentId"
+	}
+	id, err := strconv.ParseInt(c.Params(param), 10, 64)
+	if err != nil {
+		return false, fiber.NewError(http.StatusBadRequest, err.Error())
+	}
+	author, err := lookup(c.UserContext(), id)
+	if err != nil {
+		return false, err
+	}
+	return author == principal.UserID, nil
+}
+
+// ownedBy names the kind of resource whose author own rules on action
+// check, or returns "" if own rules do not apply to it
+func ownedBy(action string) string {
+	switch kind, _, _ := strings.Cut(action, ":"); kind {
+	case "blog", "comment":
+		return kind
+	}
+	return ""
+}
+
+func roleName(role models.Role) string {
+	if role == "" {
+		return "callers without a role"
+	}
+	return string(role) + "s"
+}
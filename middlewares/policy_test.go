@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"blog_post/api"
+	"blog_post/db"
+	"blog_post/models"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorizer(t *testing.T) {
+	store := db.NewRepo()
+	blog, err := store.CreateBlog(db.WithAuthor(context.Background(), 1), models.BlogRequestBody{
+		Title: "Title", Description: "Description", Body: "Body",
+	})
+	require.NoError(t, err)
+	_, err = store.TransitionBlog(context.Background(), blog.ID, models.StatusPublished, nil, db.AnyVersion)
+	require.NoError(t, err)
+	comment, err := store.CreateComment(db.WithAuthor(context.Background(), 4), blog.ID, models.CommentRequest{Body: "Nice"})
+	require.NoError(t, err)
+
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	authorizer := NewAuthorizer(DefaultPolicy, store)
+	var principal *models.Principal
+	authenticate := func(c *fiber.Ctx) error {
+		if principal != nil {
+			setPrincipal(c, *principal)
+		}
+		return c.Next()
+	}
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(http.StatusNoContent) }
+	app.Post("/blogs", authenticate, authorizer.Require(ActionCreateBlog), ok)
+	app.Put("/blogs/:id", authenticate, authorizer.Require(ActionUpdateBlog), ok)
+	app.Delete("/blogs/:id/purge", authenticate, authorizer.Require(ActionPurgeBlog), ok)
+	app.Put("/blogs/:id/comments/:commentId", authenticate, authorizer.Require(ActionUpdateComment), ok)
+	app.Get("/trash", authenticate, func(c *fiber.Ctx) error {
+		if err := authorizer.AllowTrash(c); err != nil {
+			return err
+		}
+		return ok(c)
+	})
+
+	blogRoute := "/blogs/" + strconv.FormatInt(blog.ID, 10)
+	commentRoute := blogRoute + "/comments/" + strconv.FormatInt(comment.ID, 10)
+	tests := []struct {
+		description  string
+		principal    *models.Principal
+		method       string
+		route        string
+		expectedCode int
+		detail       string
+	}{
+		{"anonymous", nil, http.MethodPost, "/blogs", http.StatusUnauthorized, "authentication required"},
+		{"reader", &models.Principal{Name: "dave", UserID: 4, Role: models.RoleReader}, http.MethodPost, "/blogs", http.StatusForbidden, "readers may not blog:create"},
+		{"author creates", &models.Principal{Name: "alice", UserID: 1, Role: models.RoleAuthor}, http.MethodPost, "/blogs", http.StatusNoContent, ""},
+		{"author edits own blog", &models.Principal{Name: "alice", UserID: 1, Role: models.RoleAuthor}, http.MethodPut, blogRoute, http.StatusNoContent, ""},
+		{"author edits another's blog", &models.Principal{Name: "bob", UserID: 2, Role: models.RoleAuthor}, http.MethodPut, blogRoute, http.StatusForbidden, "authors may only blog:update their own blogs"},
+		{"author edits a missing blog", &models.Principal{Name: "bob", UserID: 2, Role: models.RoleAuthor}, http.MethodPut, "/blogs/9999", http.StatusNotFound, ""},
+		{"editor edits any blog", &models.Principal{Name: "carol", UserID: 3, Role: models.RoleEditor}, http.MethodPut, blogRoute, http.StatusNoContent, ""},
+		{"editor purges", &models.Principal{Name: "carol", UserID: 3, Role: models.RoleEditor}, http.MethodDelete, blogRoute + "/purge", http.StatusForbidden, "editors may not blog:purge"},
+		{"admin purges", &models.Principal{Name: "erin", UserID: 5, Role: models.RoleAdmin}, http.MethodDelete, blogRoute + "/purge", http.StatusNoContent, ""},
+		{"reader edits own comment", &models.Principal{Name: "dave", UserID: 4, Role: models.RoleReader}, http.MethodPut, commentRoute, http.StatusNoContent, ""},
+		{"reader edits another's comment", &models.Principal{Name: "frank", UserID: 6, Role: models.RoleReader}, http.MethodPut, commentRoute, http.StatusForbidden, "readers may only comment:update their own comments"},
+		{"editor edits another's comment", &models.Principal{Name: "carol", UserID: 3, Role: models.RoleEditor}, http.MethodPut, commentRoute, http.StatusForbidden, "editors may only comment:update their own comments"},
+		{"reader edits a missing comment", &models.Principal{Name: "dave", UserID: 4, Role: models.RoleReader}, http.MethodPut, blogRoute + "/comments/9999", http.StatusNotFound, ""},
+		{"anonymous lists the trash", nil, http.MethodGet, "/trash", http.StatusUnauthorized, "authentication required"},
+		{"reader lists the trash", &models.Principal{Name: "dave", UserID: 4, Role: models.RoleReader}, http.MethodGet, "/trash", http.StatusForbidden, "readers may not list the trash"},
+		{"author lists the trash", &models.Principal{Name: "alice", UserID: 1, Role: models.RoleAuthor}, http.MethodGet, "/trash", http.StatusForbidden, "authors may not list the trash"},
+		{"editor lists the trash", &models.Principal{Name: "carol", UserID: 3, Role: models.RoleEditor}, http.MethodGet, "/trash", http.StatusNoContent, ""},
+		{"token without a role", &models.Principal{Name: "ci-importer"}, http.MethodPost, "/blogs", http.StatusForbidden, "callers without a role may not blog:create"},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			principal = test.principal
+			resp, err := app.Test(httptest.NewRequest(test.method, test.route, nil))
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedCode, resp.StatusCode)
+			if test.detail != "" {
+				var problem models.Problem
+				json.NewDecoder(resp.Body).Decode(&problem)
+				assert.Equal(t, test.detail, problem.Detail)
+			}
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy([]byte(`{"blog:purge": {"any": ["editor", "admin"]}}`))
+	require.NoError(t, err)
+	assert.Equal(t, []models.Role{models.RoleEditor, models.RoleAdmin}, policy[ActionPurgeBlog].Any)
+	assert.Equal(t, DefaultPolicy[ActionCreateBlog], policy[ActionCreateBlog], "other actions keep their defaults")
+	assert.Equal(t, []models.Role{models.RoleAdmin}, DefaultPolicy[ActionPurgeBlog].Any, "the defaults are not modified")
+
+	policy, err = ParsePolicy([]byte(`{"comment:delete": {"own": ["reader", "author", "editor"]}}`))
+	require.NoError(t, err, "own rules apply to comment actions")
+	assert.Empty(t, policy[ActionDeleteComment].Any)
+
+	for _, data := range []string{
+		`not json`,
+		`{"blog:frobnicate": {"any": ["admin"]}}`,
+		`{"blog:create": {"any": ["overlord"]}}`,
+		`{"user:manage": {"own": ["author"]}}`,
+	} {
+		_, err := ParsePolicy([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
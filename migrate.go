@@ -0,0 +1,97 @@
+package main
+
+import (
+	"blog_post/db"
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/spf13/viper"
+)
+
+// migratable is implemented by the SQL-backed stores
+type migratable interface {
+	Migrator() (*db.Migrator, error)
+}
+
+// autoMigrate applies pending migrations at startup; stores without a
+// schema (the in-memory repo) are left alone
+func autoMigrate(ctx context.Context, store db.BlogStore) error {
+	target, ok := store.(migratable)
+	if !ok {
+		return nil
+	}
+	migrator, err := target.Migrator()
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+	if applied > 0 {
+		log.Infof("Applied %d migration(s)", applied)
+	}
+	return nil
+}
+
+// runMigrate implements the `migrate up|down|status` subcommand against the
+// store configured by DB_DRIVER
+func runMigrate(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: blog_post migrate up|down|status")
+	}
+	store, err := newStore()
+	if err != nil {
+		return err
+	}
+	target, ok := store.(migratable)
+	if !ok {
+		return fmt.Errorf("DB_DRIVER %q has no migrations", viper.GetString("DB_DRIVER"))
+	}
+	migrator, err := target.Migrator()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migration(s)\n", applied)
+	case "down":
+		rolledBack, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if !rolledBack {
+			fmt.Println("no migrations to roll back")
+			return nil
+		}
+		fmt.Println("rolled back 1 migration")
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q; want up, down or status", args[0])
+	}
+	return nil
+}
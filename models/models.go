@@ -4,24 +4,364 @@ import (
 	"time"
 )
 
+// Status is the stage of a blog in its publishing lifecycle
+type Status string
+
+const (
+	StatusDraft     Status = "draft"
+	StatusScheduled Status = "scheduled"
+	StatusPublished Status = "published"
+	StatusArchived  Status = "archived"
+)
+
+// BodyFormat is the markup a blog body is written in
+type BodyFormat string
+
+const (
+	FormatMarkdown  BodyFormat = "markdown" // CommonMark with GitHub Flavored Markdown extensions
+	FormatHTML      BodyFormat = "html"
+	FormatPlaintext BodyFormat = "plaintext"
+)
+
 // Blog struct represents a blog post
 type Blog struct {
-	ID          int64     `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Body        string    `json:"body"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int64      `json:"id"`
+	Slug        string     `json:"slug" example:"my-first-post"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Body        string     `json:"body"`
+	BodyFormat  BodyFormat `json:"body_format" enums:"markdown,html,plaintext" example:"markdown"`
+	// Body rendered to sanitized HTML, safe to embed in a page
+	BodyHTML  string    `json:"body_html" example:"<p>Hello, <em>world</em></p>"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Incremented on every write; also served as the ETag
+	Version int64  `json:"version" example:"1"`
+	Status  Status `json:"status" enums:"draft,scheduled,published,archived" example:"published"`
+	// When a scheduled blog goes live, or when a published one did
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	// Set while the blog is in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// User who created the blog; omitted for anonymous posts
+	AuthorID *int64 `json:"author_id,omitempty" example:"7"`
+	// Normalized tags, sorted
+	Tags []string `json:"tags" example:"go,testing"`
+	// Slugs of the categories the blog is filed under, sorted
+	Categories []string `json:"categories" example:"tutorials"`
+}
+
+// BlogList is the envelope returned by listing endpoints
+type BlogList struct {
+	Items []Blog `json:"items"`
+	// Number of blogs in the whole collection, not just this page
+	Total int `json:"total" example:"1"`
+	// Opaque cursor for the next page; omitted on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Opaque cursor for the previous page; omitted on the first page
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// SearchHit is a blog matched by a full-text search
+type SearchHit struct {
+	Blog
+	// Relevance of the match; higher is better
+	Score float64 `json:"score" example:"2.7"`
+	// HTML-escaped snippet per matching field with matches wrapped in <mark>
+	Highlights map[string]string `json:"highlights"`
+}
+
+// SearchResults is the envelope returned by searches, best match first
+type SearchResults struct {
+	Items []SearchHit `json:"items"`
+	// Number of blogs matching the query
+	Total int `json:"total" example:"1"`
+	// Opaque cursor for the next page; omitted on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Revision is an immutable snapshot of a blog recorded by every create and
+// update
+type Revision struct {
+	BlogID int64 `json:"blog_id" example:"42"`
+	// Blog version this revision produced
+	Version     int64      `json:"version" example:"1"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Body        string     `json:"body"`
+	BodyFormat  BodyFormat `json:"body_format" enums:"markdown,html,plaintext" example:"markdown"`
+	// Body rendered to sanitized HTML when the revision was written
+	BodyHTML string `json:"body_html"`
+	// Lifecycle status the blog had after this revision
+	Status Status `json:"status" enums:"draft,scheduled,published,archived" example:"draft"`
+	// Who made the change; empty for anonymous writes
+	Author    string    `json:"author,omitempty" example:"alice"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RevisionList is the envelope returned when listing revisions, oldest first
+type RevisionList struct {
+	Items []Revision `json:"items"`
+	Total int        `json:"total" example:"1"`
+}
+
+// RevisionDiff describes what changed between two revisions of a blog
+type RevisionDiff struct {
+	BlogID int64 `json:"blog_id" example:"42"`
+	From   int64 `json:"from" example:"1"`
+	To     int64 `json:"to" example:"2"`
+	// One entry per changed field; unchanged fields are left out
+	Changes []FieldDiff `json:"changes"`
+}
+
+// FieldDiff is the line-by-line difference of one field
+type FieldDiff struct {
+	Field string     `json:"field" example:"body"`
+	Lines []DiffLine `json:"lines"`
+}
+
+// DiffLine is one line of a diff: kept, removed from the old text or added
+// in the new one
+type DiffLine struct {
+	Op   string `json:"op" enums:"equal,delete,insert" example:"insert"`
+	Text string `json:"text"`
 }
 
+// ScheduleRequest is the body of a request to schedule a blog
+type ScheduleRequest struct {
+	// Future time at which the blog is published
+	PublishAt time.Time `json:"publish_at" example:"2030-01-01T09:00:00Z"`
+}
+
+// BlogRequestBody is the body of a request to create or replace a blog. Its
+// `validate` tags are enforced by db.ValidateBlog on every write.
 type BlogRequestBody struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Body        string `json:"body"`
+	// Surrounding whitespace is trimmed
+	Title string `json:"title" validate:"trim,required,min=3,max=200,chars=line" minLength:"3" maxLength:"200"`
+	// Surrounding whitespace is trimmed
+	Description string `json:"description" validate:"trim,required,max=500,chars=line" maxLength:"500"`
+	Body        string `json:"body" validate:"required,max=100000,chars=text" maxLength:"100000"`
+	// Optional; markdown when empty
+	BodyFormat BodyFormat `json:"body_format,omitempty" validate:"oneof=markdown html plaintext" enums:"markdown,html,plaintext"`
+	// Optional; derived from the title when empty
+	Slug string `json:"slug,omitempty" validate:"trim,slug" example:"my-first-post" maxLength:"80"`
+	// Free-form; lowercased and transliterated like slugs, duplicates dropped
+	Tags []string `json:"tags" example:"Go,testing" maxItems:"20"`
+	// Slugs of existing categories
+	Categories []string `json:"categories" example:"tutorials" maxItems:"10"`
+}
+
+// Category is a topic blogs are filed under. Unlike tags, categories are
+// managed: blogs may only name ones that exist.
+type Category struct {
+	ID          int64     `json:"id" example:"2"`
+	Slug        string    `json:"slug" example:"tutorials"`
+	Name        string    `json:"name" example:"Tutorials"`
+	Description string    `json:"description,omitempty" example:"Step-by-step guides"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CategoryRequest is the body of a request to create or replace a category.
+// Its `validate` tags are enforced by db.ValidateCategory.
+type CategoryRequest struct {
+	// Surrounding whitespace is trimmed
+	Name string `json:"name" validate:"trim,required,max=100,chars=line" example:"Tutorials" maxLength:"100"`
+	// Optional; derived from the name when empty
+	Slug        string `json:"slug,omitempty" validate:"trim,slug" example:"tutorials" maxLength:"80"`
+	Description string `json:"description,omitempty" validate:"trim,max=500,chars=line" example:"Step-by-step guides" maxLength:"500"`
+}
+
+// TagCount is how many blogs carry a tag, for tag clouds
+type TagCount struct {
+	Tag   string `json:"tag" example:"go"`
+	Count int    `json:"count" example:"12"`
+}
+
+// CommentStatus is where a comment stands in moderation
+type CommentStatus string
+
+const (
+	CommentPending  CommentStatus = "pending"
+	CommentApproved CommentStatus = "approved"
+	CommentSpam     CommentStatus = "spam"
+)
+
+// Comment is a user's comment on a blog, or a reply to another comment
+type Comment struct {
+	ID     int64 `json:"id" example:"12"`
+	BlogID int64 `json:"blog_id" example:"1"`
+	// Comment this one replies to; omitted for top-level comments
+	ParentID *int64 `json:"parent_id,omitempty" example:"11"`
+	// User who wrote the comment
+	AuthorID int64 `json:"author_id" example:"7"`
+	// Username of the author when the comment was written
+	Author string `json:"author" example:"alice"`
+	Body   string `json:"body" example:"Thanks, this helped!"`
+	// Only approved comments are shown on their blog
+	Status    CommentStatus `json:"status" enums:"pending,approved,spam" example:"approved"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	// Approved replies, oldest first; only filled in by thread listings
+	Replies []Comment `json:"replies,omitempty"`
+}
+
+// CommentList is the envelope returned by comment listings
+type CommentList struct {
+	Items []Comment `json:"items"`
+	// Number of comments (threads, for a blog's comments) in the whole
+	// listing, not just this page
+	Total int `json:"total" example:"1"`
+	// Opaque cursor for the next page; omitted on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// CommentRequest is the body of a request to comment on a blog. Its
+// `validate` tags are enforced by db.ValidateComment.
+type CommentRequest struct {
+	Body string `json:"body" validate:"trim,required,max=5000,chars=text" example:"Thanks, this helped!" maxLength:"5000"`
+	// Optional; the approved comment on the same blog this one replies to
+	ParentID *int64 `json:"parent_id,omitempty" example:"11"`
+}
+
+// CommentEditRequest is the body of a request to edit a comment
+type CommentEditRequest struct {
+	Body string `json:"body" validate:"trim,required,max=5000,chars=text" example:"Thanks, this helped a lot!" maxLength:"5000"`
+}
+
+// CommentStatusRequest is the body of a request to moderate a comment
+type CommentStatusRequest struct {
+	Status CommentStatus `json:"status" enums:"pending,approved,spam" example:"approved"`
+}
+
+// Role decides what a user may do; see middlewares.Policy
+type Role string
+
+const (
+	RoleReader Role = "reader"
+	RoleAuthor Role = "author"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+// User is an account that can sign in and author blogs
+type User struct {
+	ID       int64  `json:"id" example:"7"`
+	Username string `json:"username" example:"alice"`
+	// Display name; optional
+	Name string `json:"name,omitempty" example:"Alice Liddell"`
+	// New accounts are readers until an admin grants them more
+	Role      Role      `json:"role" enums:"reader,author,editor,admin" example:"author"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RoleRequest is the body of a request to change a user's role
+type RoleRequest struct {
+	Role Role `json:"role" enums:"reader,author,editor,admin" example:"editor"`
+}
+
+// RegisterRequest is the body of a request to create a user account. Its
+// `validate` tags are enforced by db.ValidateUser.
+type RegisterRequest struct {
+	// 3-32 lowercase letters, digits, '.', '_' or '-'; case-insensitive
+	Username string `json:"username" validate:"required,username" example:"alice" minLength:"3" maxLength:"32"`
+	// 8-72 bytes
+	Password string `json:"password" validate:"required,minbytes=8,maxbytes=72" example:"correct horse battery staple"`
+	Name     string `json:"name,omitempty" validate:"max=100" example:"Alice Liddell" maxLength:"100"`
+}
+
+// LoginRequest is the body of a request to check a user's credentials. Its
+// `validate` tags are enforced by db.ValidateLogin.
+type LoginRequest struct {
+	Username string `json:"username" validate:"required" example:"alice"`
+	Password string `json:"password" validate:"required" example:"correct horse battery staple"`
+}
+
+// LoginResponse is the bearer token a successful login hands out. It is
+// sent back as "Authorization: Bearer <access_token>" until it expires.
+type LoginResponse struct {
+	AccessToken string    `json:"access_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	TokenType   string    `json:"token_type" example:"Bearer"`
+	ExpiresAt   time.Time `json:"expires_at" example:"2024-01-01T13:00:00Z"`
+	User        User      `json:"user"`
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	// Username of the caller; "jwt:" and the token subject, or "apikey:" and
+	// the key name, for callers that are not users
+	Name string `json:"name" example:"alice"`
+	// ID of the user behind the caller; 0 if it is not a user of this service
+	UserID int64 `json:"user_id,omitempty" example:"7"`
+	// Role the caller acts with; empty grants nothing
+	Role Role `json:"role,omitempty" enums:"reader,author,editor,admin" example:"author"`
+	// How the caller authenticated
+	Method string `json:"method" enums:"basic,jwt,api_key" example:"jwt"`
+}
+
+// APIKeyScope limits what an API key may do: read maps to the reader role,
+// write to editor and admin to admin
+type APIKeyScope string
+
+const (
+	ScopeRead  APIKeyScope = "read"
+	ScopeWrite APIKeyScope = "write"
+	ScopeAdmin APIKeyScope = "admin"
+)
+
+// APIKey is a credential for machine clients such as CI jobs. The key
+// itself is only shown once, when it is created.
+type APIKey struct {
+	ID int64 `json:"id" example:"3"`
+	// What the key is for; it names the caller in revisions
+	Name string `json:"name" example:"ci-importer"`
+	// First characters of the key, to tell keys apart
+	Prefix string      `json:"prefix" example:"bpk_3q2xW9"`
+	Scope  APIKeyScope `json:"scope" enums:"read,write,admin" example:"write"`
+	// User who created the key, if it was a user
+	CreatedBy  *int64     `json:"created_by,omitempty" example:"7"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// NewAPIKey is a just-created APIKey together with its secret
+type NewAPIKey struct {
+	APIKey
+	// Send as the X-API-Key header; it cannot be retrieved again
+	Key string `json:"key" example:"bpk_3q2xW9c1Yy0iV4mRrT7Zp5HnKd8sLqAe2bGfJwXuOo0"`
+}
+
+// APIKeyRequest is the body of a request to create an API key. Its
+// `validate` tags are enforced by db.ValidateAPIKey.
+type APIKeyRequest struct {
+	// Surrounding whitespace is trimmed
+	Name  string      `json:"name" validate:"trim,required,max=100,chars=line" example:"ci-importer" maxLength:"100"`
+	Scope APIKeyScope `json:"scope" validate:"required,oneof=read write admin" enums:"read,write,admin" example:"write"`
+	// Optional; the key never expires without it
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Problem is an RFC 7807 problem details document, served as
+// application/problem+json for every failed request
+type Problem struct {
+	// URI reference identifying the problem type
+	Type string `json:"type" example:"/problems/not-found"`
+	// Short, human-readable summary of the problem type
+	Title string `json:"title" example:"Resource not found"`
+	// HTTP status code
+	Status int `json:"status" example:"404"`
+	// Explanation specific to this occurrence of the problem
+	Detail string `json:"detail,omitempty" example:"blog not found"`
+	// URI reference of the request that failed
+	Instance string `json:"instance,omitempty" example:"/api/v1/blog-post/42"`
+	// Field-level failures, present for validation problems
+	Errors []FieldError `json:"errors,omitempty"`
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
+// FieldError describes why a single request field was rejected
+type FieldError struct {
+	Field   string `json:"field" example:"title"`
+	Message string `json:"message" example:"is required"`
 }
 
 type SuccessResponse struct {
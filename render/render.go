@@ -0,0 +1,73 @@
+// Package render turns blog bodies into HTML that is safe to embed in a
+// page, whatever markup they were written in
+package render
+
+import (
+	"blog_post/models"
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// markdown renders CommonMark with the GitHub Flavored Markdown extensions
+// (tables, strikethrough, autolinks and task lists). Raw HTML is passed
+// through, to be sanitized with the rest of the output.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+)
+
+// policy is the allowlist every rendered body is sanitized against: the
+// formatting, links and images of user-generated content, without scripts,
+// styles, event handlers or javascript: URLs, plus the classes and task
+// list checkboxes Markdown produces
+var policy = sync.OnceValue(func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^$`)).OnElements("input")
+	return p
+})
+
+// HTML renders body, written in format, to sanitized HTML. Plain text is
+// escaped, with blank lines separating paragraphs and single line breaks
+// kept.
+func HTML(format models.BodyFormat, body string) (string, error) {
+	switch format {
+	case models.FormatMarkdown:
+		var buf bytes.Buffer
+		if err := markdown.Convert([]byte(body), &buf); err != nil {
+			return "", err
+		}
+		return policy().Sanitize(buf.String()), nil
+	case models.FormatHTML:
+		return policy().Sanitize(body), nil
+	case models.FormatPlaintext:
+		return plaintext(body), nil
+	}
+	return "", fmt.Errorf("unknown body format %q", format)
+}
+
+var paragraphBreak = regexp.MustCompile(`\n[ \t]*\n\s*`)
+
+func plaintext(body string) string {
+	body = strings.TrimSpace(strings.ReplaceAll(body, "\r\n", "\n"))
+	if body == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, paragraph := range paragraphBreak.Split(body, -1) {
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>\n"))
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,44 @@
+package render
+
+import (
+	"blog_post/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		description string
+		format      models.BodyFormat
+		body        string
+		want        string
+	}{
+		{"markdown", models.FormatMarkdown, "# Title\n\nSome *text*", "<h1>Title</h1>\n<p>Some <em>text</em></p>\n"},
+		{"strikethrough", models.FormatMarkdown, "~~gone~~", "<p><del>gone</del></p>\n"},
+		{"tables", models.FormatMarkdown, "| a |\n|---|\n| 1 |",
+			"<table>\n<thead>\n<tr>\n<th>a</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>1</td>\n</tr>\n</tbody>\n</table>\n"},
+		{"task lists", models.FormatMarkdown, "- [x] done",
+			`<ul>` + "\n" + `<li><input checked="" disabled="" type="checkbox"> done</li>` + "\n" + `</ul>` + "\n"},
+		{"code languages", models.FormatMarkdown, "```go\nx := 1\n```", `<pre><code class="language-go">x := 1` + "\n" + `</code></pre>` + "\n"},
+		{"autolinks", models.FormatMarkdown, "see https://example.com",
+			`<p>see <a href="https://example.com" rel="nofollow">https://example.com</a></p>` + "\n"},
+		{"raw html in markdown", models.FormatMarkdown, "<script>alert(1)</script>\n\n<b>ok</b>", "\n<p><b>ok</b></p>\n"},
+		{"javascript links", models.FormatMarkdown, "[click](javascript:alert(1))", "<p>click</p>\n"},
+		{"event handlers", models.FormatHTML, `<img src="https://example.com/a.png" onerror="alert(1)">`, `<img src="https://example.com/a.png">`},
+		{"styles", models.FormatHTML, `<p style="color:red">hi</p><style>p{}</style>`, "<p>hi</p>"},
+		{"plaintext", models.FormatPlaintext, "a <b> & c\nsame paragraph\n\n\nnext", "<p>a &lt;b&gt; &amp; c<br>\nsame paragraph</p>\n<p>next</p>\n"},
+		{"blank plaintext", models.FormatPlaintext, " \n ", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got, err := HTML(test.format, test.body)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+
+	_, err := HTML("rst", "body")
+	assert.Error(t, err)
+}